@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+)
+
+// newFlagSet creates a flag set for the given command that reports parse
+// errors to the caller instead of exiting.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	return flags
+}
+
+// parseArgs parses the given arguments and returns the positional ones.
+// Unlike flag.Parse, flags may follow positional arguments.
+func parseArgs(flags *flag.FlagSet, args []string, positional int) ([]string, error) {
+	values := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, fmt.Errorf("%s: %s", flags.Name(), err.Error())
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+
+		values = append(values, args[0])
+		args = args[1:]
+	}
+
+	if len(values) != positional {
+		return nil, fmt.Errorf("%s: expected %d argument(s), got %d", flags.Name(), positional, len(values))
+	}
+
+	return values, nil
+}
+
+// requireURL returns an error if the --url flag was not supplied.
+func requireURL(flags *flag.FlagSet, url string) error {
+	if url == "" {
+		return fmt.Errorf("%s: --url is required", flags.Name())
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type FlagsSuite struct{}
+
+func (s *FlagsSuite) TestParseArgs(t sweet.T) {
+	for _, test := range []struct {
+		args       []string
+		positional int
+		values     []string
+		url        string
+		clear      bool
+	}{
+		{args: []string{}, positional: 0, values: []string{}},
+		{args: []string{"a.yaml"}, positional: 1, values: []string{"a.yaml"}},
+		{args: []string{"--url", "http://x", "a.yaml"}, positional: 1, values: []string{"a.yaml"}, url: "http://x"},
+		{args: []string{"a.yaml", "--url=http://x"}, positional: 1, values: []string{"a.yaml"}, url: "http://x"},
+		{args: []string{"a.yaml", "--clear", "b.yaml"}, positional: 2, values: []string{"a.yaml", "b.yaml"}, clear: true},
+		{args: []string{"--url", "http://x", "--", "--clear"}, positional: 1, values: []string{"--clear"}, url: "http://x"},
+	} {
+		flags := newFlagSet("test")
+		url := flags.String("url", "", "")
+		clear := flags.Bool("clear", false, "")
+
+		values, err := parseArgs(flags, test.args, test.positional)
+		Expect(err).To(BeNil())
+		Expect(values).To(Equal(test.values))
+		Expect(*url).To(Equal(test.url))
+		Expect(*clear).To(Equal(test.clear))
+	}
+}
+
+func (s *FlagsSuite) TestParseArgsErrors(t sweet.T) {
+	for _, test := range []struct {
+		args       []string
+		positional int
+		message    string
+	}{
+		{args: []string{}, positional: 1, message: "test: expected 1 argument(s), got 0"},
+		{args: []string{"a", "b"}, positional: 1, message: "test: expected 1 argument(s), got 2"},
+		{args: []string{"--bogus"}, positional: 0, message: "test: flag provided but not defined: -bogus"},
+		{args: []string{"--url"}, positional: 0, message: "test: flag needs an argument: -url"},
+	} {
+		flags := newFlagSet("test")
+		flags.String("url", "", "")
+
+		_, err := parseArgs(flags, test.args, test.positional)
+		Expect(err).To(MatchError(test.message))
+	}
+}
+
+func (s *FlagsSuite) TestRequireURL(t sweet.T) {
+	flags := newFlagSet("test")
+	Expect(requireURL(flags, "http://x")).To(BeNil())
+	Expect(requireURL(flags, "")).To(MatchError("test: --url is required"))
+}
@@ -1,10 +1,35 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/efritz/derision/internal/server"
 	"github.com/efritz/nacelle"
 )
 
+type command func(args []string) error
+
+var commands = map[string]command{
+	"validate": validate,
+	"register": register,
+	"tail":     tail,
+	"requests": requests,
+}
+
+const usage = `usage: derision [command]
+
+With no command, the server is started (configured by the environment).
+
+commands:
+  validate <dir> [--schema PATH]            validate a configuration directory
+  register <file> --url URL                 register the expectations of a file
+  tail --url URL [--method M] [--path RE]   print requests as they are received
+       [--status N] [--json]
+  requests --url URL [--format json|har|curl] [--clear]
+                                            print the request log
+`
+
 func setup(processes nacelle.ProcessContainer, services nacelle.ServiceContainer) error {
 	processes.RegisterProcess(
 		server.NewServer(),
@@ -15,5 +40,25 @@ func setup(processes nacelle.ProcessContainer, services nacelle.ServiceContainer
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+			fmt.Print(usage)
+			return
+		}
+
+		command, ok := commands[os.Args[1]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown command %s\n\n%s", os.Args[1], usage)
+			os.Exit(2)
+		}
+
+		if err := command(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	nacelle.NewBootstrapper("derision", setup).BootAndExit()
 }
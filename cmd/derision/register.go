@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/efritz/derision/pkg/client"
+	"github.com/ghodss/yaml"
+)
+
+// register adds the expectations of a file (in any format accepted by the
+// /import endpoint) to a running server. A file containing a single payload
+// object (as accepted by the /register endpoint) is also accepted.
+func register(args []string) error {
+	flags := newFlagSet("register")
+	url := flags.String("url", "", "the base URL of the server")
+
+	values, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if err := requireURL(flags, *url); err != nil {
+		return err
+	}
+
+	content, err := ioutil.ReadFile(values[0])
+	if err != nil {
+		return fmt.Errorf("failed to read %s (%s)", values[0], err.Error())
+	}
+
+	data, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s (%s)", values[0], err.Error())
+	}
+
+	if isPayload(data) {
+		data = append(append([]byte("["), data...), ']')
+	}
+
+	return client.NewClient(*url).Import(context.Background(), data)
+}
+
+// isPayload determines if the given JSON document is a single /register
+// payload rather than a list or a third-party document.
+func isPayload(data []byte) bool {
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return false
+	}
+
+	_, hasRequest := payload["request"]
+	_, hasResponse := payload["response"]
+	return hasRequest || hasResponse
+}
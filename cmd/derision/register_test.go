@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type RegisterSuite struct{}
+
+func (s *RegisterSuite) TestIsPayload(t sweet.T) {
+	for data, expected := range map[string]bool{
+		`{"request": {"path": "/a"}, "response": {}}`: true,
+		`{"request": {}}`:                      true,
+		`{"response": {"status_code": "200"}}`: true,
+		`{}`:                                   false,
+		`[{"request": {}, "response": {}}]`:    false,
+		`{"openapi": "3.0.0", "paths": {}}`:    false,
+		`{"log": {"entries": []}}`:             false,
+		`"request"`:                            false,
+		`not json`:                             false,
+	} {
+		Expect(isPayload([]byte(data))).To(Equal(expected), data)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/efritz/derision/pkg/client"
+)
+
+// requests prints the request log of a running server as JSON, as a HAR
+// document, or as a list of curl commands that replay each request.
+func requests(args []string) error {
+	flags := newFlagSet("requests")
+	baseURL := flags.String("url", "", "the base URL of the server")
+	format := flags.String("format", "json", "the output format (json, har, or curl)")
+	clear := flags.Bool("clear", false, "truncate the request log")
+
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	if err := requireURL(flags, *baseURL); err != nil {
+		return err
+	}
+
+	c := client.NewClient(*baseURL)
+
+	switch *format {
+	case "json", "har":
+		content, err := c.Export(context.Background(), *format, *clear)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(content))
+		return nil
+
+	case "curl":
+		logged, err := c.Requests(context.Background(), *clear)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range logged {
+			fmt.Println(formatCurl(*baseURL, r))
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("requests: illegal format %s (expected json, har, or curl)", *format)
+}
+
+// formatCurl returns a curl command that replays the given request. The
+// request is sent to the host that received it, or to the given base URL
+// if the host was not recorded. A body that was recorded after removing its
+// content encoding is sent without one. A binary body is embedded in base64
+// and decoded into the standard input of curl, as it cannot be quoted.
+func formatCurl(baseURL string, r *client.Request) string {
+	target := strings.TrimSuffix(baseURL, "/")
+	if r.Host != "" {
+		target = "http://" + r.Host
+	}
+
+	target += r.Path
+	if len(r.Query) > 0 {
+		target += "?" + url.Values(r.Query).Encode()
+	}
+
+	parts := []string{"curl", "-X", r.Method, shellQuote(target)}
+
+	for _, name := range sortedKeys(r.Headers) {
+		// curl computes the length of the body it sends
+		if name == "Content-Length" || (name == "Content-Encoding" && r.Encoding != "") {
+			continue
+		}
+
+		for _, value := range r.Headers[name] {
+			parts = append(parts, "-H", shellQuote(name+": "+value))
+		}
+	}
+
+	if r.Body == "" {
+		return strings.Join(parts, " ")
+	}
+
+	if !r.Binary && utf8.ValidString(r.Body) {
+		parts = append(parts, "--data-binary", shellQuote(r.Body))
+		return strings.Join(parts, " ")
+	}
+
+	rawBody := r.RawBody
+	if rawBody == "" {
+		rawBody = base64.StdEncoding.EncodeToString([]byte(r.Body))
+	}
+
+	parts = append(parts, "--data-binary", "@-")
+	return fmt.Sprintf("printf %%s %s | base64 -d | %s", shellQuote(rawBody), strings.Join(parts, " "))
+}
+
+// shellQuote quotes the given text for a POSIX shell.
+func shellQuote(text string) string {
+	return "'" + strings.Replace(text, "'", `'"'"'`, -1) + "'"
+}
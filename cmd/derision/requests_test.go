@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os/exec"
+
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/pkg/client"
+	. "github.com/onsi/gomega"
+)
+
+type RequestsSuite struct{}
+
+func (s *RequestsSuite) TestShellQuote(t sweet.T) {
+	for text, expected := range map[string]string{
+		"":              `''`,
+		"plain":         `'plain'`,
+		"two words":     `'two words'`,
+		"it's":          `'it'"'"'s'`,
+		`"double"`:      `'"double"'`,
+		"a\nb":          "'a\nb'",
+		"$HOME `id` \\": "'$HOME `id` \\'",
+	} {
+		Expect(shellQuote(text)).To(Equal(expected), text)
+	}
+}
+
+func (s *RequestsSuite) TestShellQuoteRoundTrip(t sweet.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell available")
+	}
+
+	for _, text := range []string{"plain", "it's", `"double"`, "a\nb\n", "$HOME `id` \\", "'''"} {
+		output, err := exec.Command(sh, "-c", "printf %s "+shellQuote(text)).Output()
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(text))
+	}
+}
+
+func (s *RequestsSuite) TestFormatCurl(t sweet.T) {
+	for _, test := range []struct {
+		request  *client.Request
+		expected string
+	}{
+		{
+			request:  &client.Request{Method: "GET", Path: "/a"},
+			expected: `curl -X GET 'http://localhost:5000/a'`,
+		},
+		{
+			request: &client.Request{
+				Method: "POST",
+				Host:   "api.test:8080",
+				Path:   "/users",
+				Query:  map[string][]string{"q": {"a b"}, "x": {"1", "2"}},
+				Headers: map[string][]string{
+					"Content-Type":   {"application/json"},
+					"Content-Length": {"17"},
+					"X-Quote":        {`it's "quoted"`},
+				},
+				Body: "{\"name\": \"o'neil\"}\n",
+			},
+			expected: `curl -X POST 'http://api.test:8080/users?q=a+b&x=1&x=2'` +
+				` -H 'Content-Type: application/json'` +
+				` -H 'X-Quote: it'"'"'s "quoted"'` +
+				" --data-binary '{\"name\": \"o'\"'\"'neil\"}\n'",
+		},
+		{
+			request: &client.Request{
+				Method:   "POST",
+				Path:     "/gzip",
+				Headers:  map[string][]string{"Content-Encoding": {"gzip"}, "Content-Type": {"text/plain"}},
+				Body:     "decoded",
+				Encoding: "gzip",
+			},
+			expected: `curl -X POST 'http://localhost:5000/gzip' -H 'Content-Type: text/plain' --data-binary 'decoded'`,
+		},
+		{
+			request: &client.Request{
+				Method:  "POST",
+				Path:    "/image",
+				Headers: map[string][]string{"Content-Type": {"image/jpeg"}},
+				Body:    "\ufffd\ufffd\ufffd",
+				RawBody: "/9j/",
+				Binary:  true,
+			},
+			expected: `printf %s '/9j/' | base64 -d | curl -X POST 'http://localhost:5000/image' -H 'Content-Type: image/jpeg' --data-binary @-`,
+		},
+		{
+			request:  &client.Request{Method: "POST", Path: "/raw", Body: "\xff\xd8\xff"},
+			expected: `printf %s '/9j/' | base64 -d | curl -X POST 'http://localhost:5000/raw' --data-binary @-`,
+		},
+	} {
+		Expect(formatCurl("http://localhost:5000/", test.request)).To(Equal(test.expected))
+	}
+}
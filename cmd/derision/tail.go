@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/efritz/derision/pkg/client"
+)
+
+type requestFilter struct {
+	method string
+	path   *regexp.Regexp
+	status int
+}
+
+// tail prints each request received by a running server until interrupted.
+func tail(args []string) error {
+	flags := newFlagSet("tail")
+	url := flags.String("url", "", "the base URL of the server")
+	method := flags.String("method", "", "only print requests with this method")
+	path := flags.String("path", "", "only print requests with a path matching this regex")
+	status := flags.Int("status", 0, "only print requests answered with this status code")
+	raw := flags.Bool("json", false, "print each request as JSON")
+
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	if err := requireURL(flags, *url); err != nil {
+		return err
+	}
+
+	pathPattern, err := regexp.Compile(*path)
+	if err != nil {
+		return fmt.Errorf("illegal path regex (%s)", err.Error())
+	}
+
+	filter := &requestFilter{
+		method: strings.ToUpper(*method),
+		path:   pathPattern,
+		status: *status,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	ch, err := client.NewClient(*url).Subscribe(ctx)
+	if err != nil {
+		return err
+	}
+
+	for r := range ch {
+		if !filter.matches(r) {
+			continue
+		}
+
+		if *raw {
+			serialized, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(serialized))
+			continue
+		}
+
+		fmt.Print(formatRequest(r))
+	}
+
+	return nil
+}
+
+func (f *requestFilter) matches(r *client.Request) bool {
+	if f.method != "" && r.Method != f.method {
+		return false
+	}
+
+	if !f.path.MatchString(r.Path) {
+		return false
+	}
+
+	if f.status != 0 && (r.Response == nil || r.Response.StatusCode != f.status) {
+		return false
+	}
+
+	return true
+}
+
+// formatRequest returns a multi-line, human-readable description of the
+// given request and its response.
+func formatRequest(r *client.Request) string {
+	lines := []string{}
+
+	summary := fmt.Sprintf("%s %s %s", r.Timestamp.Format("15:04:05.000"), r.Method, requestURI(r))
+	if r.Response != nil {
+		summary += fmt.Sprintf(" -> %d (%.1fms)", r.Response.StatusCode, r.Response.ElapsedMs)
+	}
+
+	lines = append(lines, summary)
+
+	for _, name := range sortedKeys(r.Headers) {
+		for _, value := range r.Headers[name] {
+			lines = append(lines, fmt.Sprintf("    %s: %s", name, value))
+		}
+	}
+
+	if r.Body != "" {
+		lines = append(lines, indent(r.Body))
+	}
+
+	if r.Expectation != nil && len(r.Expectation.Tags) > 0 {
+		lines = append(lines, fmt.Sprintf("    matched expectation tagged %s", strings.Join(r.Expectation.Tags, ", ")))
+	}
+
+	for _, violation := range r.Violations {
+		lines = append(lines, fmt.Sprintf("    violation: %s", violation))
+	}
+
+	return strings.Join(lines, "\n") + "\n\n"
+}
+
+// requestURI returns the path of the request along with its query string.
+func requestURI(r *client.Request) string {
+	if len(r.Query) == 0 {
+		return r.Path
+	}
+
+	parts := []string{}
+	for _, key := range sortedKeys(r.Query) {
+		for _, value := range r.Query[key] {
+			parts = append(parts, key+"="+value)
+		}
+	}
+
+	return r.Path + "?" + strings.Join(parts, "&")
+}
+
+func indent(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = "    " + line
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/pkg/client"
+	. "github.com/onsi/gomega"
+)
+
+type TailSuite struct{}
+
+func (s *TailSuite) TestFilter(t sweet.T) {
+	r := &client.Request{
+		Method:   "POST",
+		Path:     "/users/1",
+		Response: &client.Response{StatusCode: 201},
+	}
+
+	for _, test := range []struct {
+		filter   *requestFilter
+		expected bool
+	}{
+		{&requestFilter{path: regexp.MustCompile("")}, true},
+		{&requestFilter{method: "POST", path: regexp.MustCompile("")}, true},
+		{&requestFilter{method: "GET", path: regexp.MustCompile("")}, false},
+		{&requestFilter{path: regexp.MustCompile(`^/users/\d+$`)}, true},
+		{&requestFilter{path: regexp.MustCompile(`^/orders`)}, false},
+		{&requestFilter{path: regexp.MustCompile(""), status: 201}, true},
+		{&requestFilter{path: regexp.MustCompile(""), status: 404}, false},
+	} {
+		Expect(test.filter.matches(r)).To(Equal(test.expected))
+	}
+
+	// A request without a response does not match a status filter
+	Expect((&requestFilter{path: regexp.MustCompile(""), status: 201}).matches(&client.Request{})).To(BeFalse())
+}
+
+func (s *TailSuite) TestRequestURI(t sweet.T) {
+	Expect(requestURI(&client.Request{Path: "/a"})).To(Equal("/a"))
+	Expect(requestURI(&client.Request{Path: "/a", Query: map[string][]string{"b": {"2"}, "a": {"1", "3"}}})).To(Equal("/a?a=1&a=3&b=2"))
+}
+
+func (s *TailSuite) TestFormatRequest(t sweet.T) {
+	r := &client.Request{
+		Method:      "POST",
+		Path:        "/users",
+		Headers:     map[string][]string{"X-B": {"2"}, "X-A": {"1"}},
+		Body:        "line 1\nline 2\n",
+		Timestamp:   time.Date(2019, 1, 2, 3, 4, 5, 6000000, time.UTC),
+		Expectation: &client.MatchedExpectation{Tags: []string{"users", "write"}},
+		Violations:  []string{"missing field"},
+		Response:    &client.Response{StatusCode: 201, ElapsedMs: 1.25},
+	}
+
+	Expect(formatRequest(r)).To(Equal("" +
+		"03:04:05.006 POST /users -> 201 (1.2ms)\n" +
+		"    X-A: 1\n" +
+		"    X-B: 2\n" +
+		"    line 1\n" +
+		"    line 2\n" +
+		"    matched expectation tagged users, write\n" +
+		"    violation: missing field\n\n"))
+}
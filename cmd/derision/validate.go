@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/efritz/derision/internal/server"
+)
+
+// validate checks each file of a configuration directory and prints each
+// problem found. An error is returned if any problem is found.
+func validate(args []string) error {
+	flags := newFlagSet("validate")
+	schemaPath := flags.String("schema", "", "the path of a handler schema to use instead of the built-in schema")
+
+	values, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	problems, err := server.ValidateDir(*schemaPath, values[0])
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s is invalid (%d problem(s))", values[0], len(problems))
+	}
+
+	return nil
+}
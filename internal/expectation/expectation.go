@@ -1,7 +1,10 @@
 package expectation
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"regexp"
+	"strings"
 
 	"github.com/efritz/derision/internal/request"
 )
@@ -9,28 +12,72 @@ import (
 type (
 	Expectation interface {
 		Matches(r *request.Request) *Match
+		MatchesMethod(method string) bool
+		PathPrefix() string
 	}
 
 	Match struct {
 		MethodGroups []string
 		PathGroups   []string
+		QueryGroups  map[string][]string
 		HeaderGroups map[string][]string
 		BodyGroups   []string
+
+		// HeaderValueGroups holds the groups captured from each value of a
+		// header that was matched against the header's pattern. HeaderGroups
+		// holds the groups of the first of these values.
+		HeaderValueGroups map[string][][]string
 	}
 
 	expectation struct {
-		method  *regexp.Regexp
-		path    *regexp.Regexp
-		headers map[string]*regexp.Regexp
-		body    *regexp.Regexp
+		method     *regexp.Regexp
+		path       *regexp.Regexp
+		pathPrefix string
+		query      []*queryMatcher
+		headers    []*headerMatcher
+		body       *regexp.Regexp
+		encodeBody func(body []byte) string
+	}
+
+	// queryMatcher constrains the first value of a single query parameter.
+	queryMatcher struct {
+		name  string
+		regex *regexp.Regexp
 	}
 
+	// headerMatcher constrains the values of a single (canonical) header.
+	headerMatcher struct {
+		name  string
+		regex *regexp.Regexp
+		mode  headerMode
+		index int
+	}
+
+	headerMode int
+
 	matcher func(*request.Request, *Match) *Match
 )
 
+const (
+	headerFirst headerMode = iota
+	headerAny
+	headerAll
+	headerIndex
+	headerAbsent
+)
+
+// bodyEncoders maps the supported values of body_encoding to the function
+// that encodes a request body before it is matched. Encoding a binary body
+// allows its bytes to be matched by a pattern.
+var bodyEncoders = map[string]func([]byte) string{
+	"":       nil,
+	"hex":    hex.EncodeToString,
+	"base64": base64.StdEncoding.EncodeToString,
+}
+
 func (e *expectation) Matches(r *request.Request) *Match {
 	match := &Match{}
-	for _, m := range []matcher{e.matchMethod, e.matchPath, e.matchHeaders, e.matchBody} {
+	for _, m := range []matcher{e.matchMethod, e.matchPath, e.matchQuery, e.matchHeaders, e.matchBody} {
 		match = m(r, match)
 
 		if match == nil {
@@ -41,6 +88,19 @@ func (e *expectation) Matches(r *request.Request) *Match {
 	return match
 }
 
+// MatchesMethod determines if the expectation's method pattern accepts
+// the given method. This is used to index expectations by method.
+func (e *expectation) MatchesMethod(method string) bool {
+	return e.method == nil || e.method.MatchString(method)
+}
+
+// PathPrefix returns a literal string with which every matching request
+// path must begin. An empty string is returned if the pattern is not
+// anchored to the start of the path.
+func (e *expectation) PathPrefix() string {
+	return e.pathPrefix
+}
+
 func (e *expectation) matchMethod(r *request.Request, m *Match) *Match {
 	if match, groups := matchRegex(e.method, r.Method); match {
 		m.MethodGroups = groups
@@ -59,24 +119,98 @@ func (e *expectation) matchPath(r *request.Request, m *Match) *Match {
 	return nil
 }
 
+func (e *expectation) matchQuery(r *request.Request, m *Match) *Match {
+	queryGroups := map[string][]string{}
+
+	for _, q := range e.query {
+		// A missing parameter is matched as an empty value, as with headers
+		value := ""
+		if values := r.Query[q.name]; len(values) > 0 {
+			value = values[0]
+		}
+
+		match, groups := matchRegex(q.regex, value)
+		if !match {
+			return nil
+		}
+
+		queryGroups[q.name] = groups
+	}
+
+	m.QueryGroups = queryGroups
+	return m
+}
+
 func (e *expectation) matchHeaders(r *request.Request, m *Match) *Match {
 	headerGroups := map[string][]string{}
+	valueGroups := map[string][][]string{}
 
-	for k, re := range e.headers {
-		if match, groups := matchRegex(re, getFirst(r.Headers, k)); match {
-			headerGroups[k] = groups
-			continue
+	for _, h := range e.headers {
+		groups, ok := h.match(lookupHeader(r.Headers, h.name))
+		if !ok {
+			return nil
 		}
 
-		return nil
+		if h.regex != nil && len(groups) > 0 {
+			headerGroups[h.name] = groups[0]
+			valueGroups[h.name] = groups
+		}
 	}
 
 	m.HeaderGroups = headerGroups
+	m.HeaderValueGroups = valueGroups
 	return m
 }
 
+// match determines if the given values of the header satisfy the matcher and
+// returns the groups captured from each value matched against the pattern.
+func (h *headerMatcher) match(values []string) ([][]string, bool) {
+	switch h.mode {
+	case headerAbsent:
+		return nil, len(values) == 0
+
+	case headerAny, headerAll:
+		if len(values) == 0 {
+			return nil, false
+		}
+
+		groups := [][]string{}
+		for _, value := range values {
+			if match, valueGroups := matchRegex(h.regex, value); match {
+				groups = append(groups, valueGroups)
+			} else if h.mode == headerAll {
+				return nil, false
+			}
+		}
+
+		return groups, len(groups) > 0
+
+	case headerIndex:
+		if h.index >= len(values) {
+			return nil, false
+		}
+
+		match, groups := matchRegex(h.regex, values[h.index])
+		return [][]string{groups}, match
+	}
+
+	// A missing header is matched as an empty value for compatibility
+	value := ""
+	if len(values) > 0 {
+		value = values[0]
+	}
+
+	match, groups := matchRegex(h.regex, value)
+	return [][]string{groups}, match
+}
+
 func (e *expectation) matchBody(r *request.Request, m *Match) *Match {
-	if match, groups := matchRegex(e.body, r.Body); match {
+	body := r.Body
+	if e.body != nil && e.encodeBody != nil {
+		body = e.encodeBody([]byte(body))
+	}
+
+	if match, groups := matchRegex(e.body, body); match {
 		m.BodyGroups = groups
 		return m
 	}
@@ -96,10 +230,19 @@ func matchRegex(re *regexp.Regexp, val string) (bool, []string) {
 	return true, re.FindStringSubmatch(val)
 }
 
-func getFirst(headers map[string][]string, k string) string {
-	if vals, ok := headers[k]; ok && len(vals) > 0 {
-		return vals[0]
+// lookupHeader returns the values of the given canonical header name. The
+// headers of a request received by the server are already canonical, but
+// other keys are compared case-insensitively.
+func lookupHeader(headers map[string][]string, name string) []string {
+	if values, ok := headers[name]; ok {
+		return values
 	}
 
-	return ""
+	for key, values := range headers {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+
+	return nil
 }
@@ -50,13 +50,39 @@ func (s *ExpectationSuite) TestMatchPath(t sweet.T) {
 	Expect(match).To(BeNil())
 }
 
+func (s *ExpectationSuite) TestMatchQuery(t sweet.T) {
+	var match *Match
+	e := &expectation{query: []*queryMatcher{{name: "page", regex: regexp.MustCompile("^(\\d+)$")}}}
+
+	// With groups (only the first value is matched)
+	match = e.Matches(&request.Request{Query: map[string][]string{
+		"page": []string{"2", "x"},
+	}})
+
+	Expect(match).NotTo(BeNil())
+	Expect(match.QueryGroups).To(Equal(map[string][]string{
+		"page": []string{"2", "2"},
+	}))
+
+	// No match (bad value)
+	match = e.Matches(&request.Request{Query: map[string][]string{
+		"page": []string{"x"},
+	}})
+
+	Expect(match).To(BeNil())
+
+	// No match (missing parameter)
+	match = e.Matches(&request.Request{Query: map[string][]string{}})
+	Expect(match).To(BeNil())
+}
+
 func (s *ExpectationSuite) TestMatchHeader(t sweet.T) {
 	r1 := regexp.MustCompile("\\d{4}-\\d{4}")
 	r2 := regexp.MustCompile("\\d{4}-(\\d{4})")
 
 	var match *Match
-	e1 := &expectation{headers: map[string]*regexp.Regexp{"X-Test": r1}}
-	e2 := &expectation{headers: map[string]*regexp.Regexp{"X-Test": r2}}
+	e1 := &expectation{headers: []*headerMatcher{{name: "X-Test", regex: r1}}}
+	e2 := &expectation{headers: []*headerMatcher{{name: "X-Test", regex: r2}}}
 
 	// Without groups
 	match = e1.Matches(&request.Request{Headers: map[string][]string{
@@ -93,6 +119,66 @@ func (s *ExpectationSuite) TestMatchHeader(t sweet.T) {
 	Expect(match).To(BeNil())
 }
 
+func (s *ExpectationSuite) TestMatchHeaderModes(t sweet.T) {
+	r := &request.Request{Headers: map[string][]string{
+		"X-Test": []string{"a-1", "b-2", "a-3"},
+	}}
+
+	var match *Match
+	pattern := regexp.MustCompile("a-(\\d)")
+
+	// First value
+	match = (&expectation{headers: []*headerMatcher{{name: "X-Test", regex: pattern}}}).Matches(r)
+	Expect(match).NotTo(BeNil())
+	Expect(match.HeaderValueGroups).To(Equal(map[string][][]string{"X-Test": {{"a-1", "1"}}}))
+
+	// Any value
+	match = (&expectation{headers: []*headerMatcher{{name: "X-Test", regex: pattern, mode: headerAny}}}).Matches(r)
+	Expect(match).NotTo(BeNil())
+	Expect(match.HeaderGroups).To(Equal(map[string][]string{"X-Test": {"a-1", "1"}}))
+	Expect(match.HeaderValueGroups).To(Equal(map[string][][]string{"X-Test": {{"a-1", "1"}, {"a-3", "3"}}}))
+
+	// All values
+	match = (&expectation{headers: []*headerMatcher{{name: "X-Test", regex: pattern, mode: headerAll}}}).Matches(r)
+	Expect(match).To(BeNil())
+
+	match = (&expectation{headers: []*headerMatcher{{name: "X-Test", regex: regexp.MustCompile("-(\\d)"), mode: headerAll}}}).Matches(r)
+	Expect(match).NotTo(BeNil())
+	Expect(match.HeaderValueGroups["X-Test"]).To(HaveLen(3))
+
+	// Specific index
+	match = (&expectation{headers: []*headerMatcher{{name: "X-Test", regex: pattern, mode: headerIndex, index: 2}}}).Matches(r)
+	Expect(match).NotTo(BeNil())
+	Expect(match.HeaderGroups).To(Equal(map[string][]string{"X-Test": {"a-3", "3"}}))
+
+	match = (&expectation{headers: []*headerMatcher{{name: "X-Test", regex: pattern, mode: headerIndex, index: 1}}}).Matches(r)
+	Expect(match).To(BeNil())
+
+	match = (&expectation{headers: []*headerMatcher{{name: "X-Test", regex: pattern, mode: headerIndex, index: 3}}}).Matches(r)
+	Expect(match).To(BeNil())
+
+	// Absent
+	match = (&expectation{headers: []*headerMatcher{{name: "X-Other", mode: headerAbsent}}}).Matches(r)
+	Expect(match).NotTo(BeNil())
+
+	match = (&expectation{headers: []*headerMatcher{{name: "X-Test", mode: headerAbsent}}}).Matches(r)
+	Expect(match).To(BeNil())
+
+	// Missing header
+	match = (&expectation{headers: []*headerMatcher{{name: "X-Other", mode: headerAny}}}).Matches(r)
+	Expect(match).To(BeNil())
+}
+
+func (s *ExpectationSuite) TestMatchHeaderCaseInsensitive(t sweet.T) {
+	e := &expectation{headers: []*headerMatcher{{name: "Content-Type", regex: regexp.MustCompile("json")}}}
+
+	match := e.Matches(&request.Request{Headers: map[string][]string{
+		"content-type": []string{"application/json"},
+	}})
+
+	Expect(match).NotTo(BeNil())
+}
+
 func (s *ExpectationSuite) TestMatchBody(t sweet.T) {
 	var match *Match
 	e1 := &expectation{body: regexp.MustCompile("foo: bar")}
@@ -112,3 +198,22 @@ func (s *ExpectationSuite) TestMatchBody(t sweet.T) {
 	match = e1.Matches(&request.Request{Body: "bar: foo"})
 	Expect(match).To(BeNil())
 }
+
+func (s *ExpectationSuite) TestMatchBodyEncoding(t sweet.T) {
+	var match *Match
+	e1 := &expectation{body: regexp.MustCompile("^0aff(..)"), encodeBody: bodyEncoders["hex"]}
+	e2 := &expectation{body: regexp.MustCompile("^Cv8A$"), encodeBody: bodyEncoders["base64"]}
+
+	// Hex
+	match = e1.Matches(&request.Request{Body: "\x0a\xff\x00"})
+	Expect(match).NotTo(BeNil())
+	Expect(match.BodyGroups).To(Equal([]string{"0aff00", "00"}))
+
+	// Base64
+	match = e2.Matches(&request.Request{Body: "\x0a\xff\x00"})
+	Expect(match).NotTo(BeNil())
+
+	// No match
+	match = e1.Matches(&request.Request{Body: "\x0a\xfe\x00"})
+	Expect(match).To(BeNil())
+}
@@ -3,57 +3,160 @@ package expectation
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"regexp"
+	"regexp/syntax"
+	"sort"
+
+	"github.com/efritz/derision/internal/payload"
+	"github.com/efritz/derision/internal/request"
 )
 
 type jsonExpectation struct {
-	Method  string            `json:"method"`
-	Path    string            `json:"path"`
-	Headers map[string]string `json:"headers"`
-	Body    string            `json:"body"`
+	Method  string                           `json:"method"`
+	Path    string                           `json:"path"`
+	Query   map[string]string                `json:"query"`
+	Headers map[string]request.HeaderPattern `json:"headers"`
+	Body    string                           `json:"body"`
+
+	// BodyEncoding is the encoding applied to the request body before it is
+	// matched against the body pattern: hex, base64, or empty for none.
+	BodyEncoding string `json:"body_encoding"`
+}
+
+// CompileError describes a pattern of an expectation that does not compile.
+type CompileError struct {
+	// Pointer is the JSON pointer of the pattern within the expectation.
+	Pointer string
+	Message string
+	Err     error
 }
 
-func Unmarshal(payload []byte) (Expectation, error) {
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Message, e.Err.Error())
+}
+
+func Unmarshal(data []byte) (Expectation, error) {
 	e := &jsonExpectation{}
-	if err := json.Unmarshal(payload, &e); err != nil {
+	if err := json.Unmarshal(data, &e); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal payload (%s)", err.Error())
 	}
 
 	methodRegex, err := compile(e.Method)
 	if err != nil {
-		return nil, fmt.Errorf("illegal method regex")
+		return nil, &CompileError{Pointer: "/method", Message: "illegal method regex", Err: err}
 	}
 
 	pathRegex, err := compile(e.Path)
 	if err != nil {
-		return nil, fmt.Errorf("illegal path regex")
+		return nil, &CompileError{Pointer: "/path", Message: "illegal path regex", Err: err}
 	}
 
-	headerRegexMap := map[string]*regexp.Regexp{}
-	for header, value := range e.Headers {
-		regex, err := compile(value)
+	queryMatchers := []*queryMatcher{}
+	for _, name := range sortedQueryKeys(e.Query) {
+		regex, err := compile(e.Query[name])
 		if err != nil {
-			return nil, fmt.Errorf("illegal header regex")
+			return nil, &CompileError{Pointer: payload.Pointer("query", name), Message: "illegal query regex", Err: err}
 		}
 
 		if regex != nil {
-			headerRegexMap[header] = regex
+			queryMatchers = append(queryMatchers, &queryMatcher{name: name, regex: regex})
+		}
+	}
+
+	headerMatchers := []*headerMatcher{}
+	headerNames := map[string]string{}
+
+	for _, header := range sortedKeys(e.Headers) {
+		pointer := payload.Pointer("headers", header)
+
+		name := http.CanonicalHeaderKey(header)
+		if other, ok := headerNames[name]; ok {
+			return nil, &CompileError{Pointer: pointer, Message: "duplicate header", Err: fmt.Errorf("%s is also given as %s", header, other)}
+		}
+
+		headerNames[name] = header
+
+		regex, err := compile(e.Headers[header].Pattern)
+		if err != nil {
+			return nil, &CompileError{Pointer: pointer, Message: "illegal header regex", Err: err}
+		}
+
+		matcher, err := newHeaderMatcher(name, e.Headers[header], regex)
+		if err != nil {
+			return nil, &CompileError{Pointer: pointer, Message: "illegal header", Err: err}
+		}
+
+		if matcher != nil {
+			headerMatchers = append(headerMatchers, matcher)
 		}
 	}
 
 	bodyRegex, err := compile(e.Body)
 	if err != nil {
-		return nil, fmt.Errorf("illegal body regex")
+		return nil, &CompileError{Pointer: "/body", Message: "illegal body regex", Err: err}
+	}
+
+	bodyEncoder, ok := bodyEncoders[e.BodyEncoding]
+	if !ok {
+		return nil, &CompileError{Pointer: "/body_encoding", Message: "illegal body encoding", Err: fmt.Errorf("expected hex or base64, got %s", e.BodyEncoding)}
 	}
 
 	return &expectation{
-		method:  methodRegex,
-		path:    pathRegex,
-		headers: headerRegexMap,
-		body:    bodyRegex,
+		method:     methodRegex,
+		path:       pathRegex,
+		pathPrefix: anchoredPrefix(e.Path),
+		query:      queryMatchers,
+		headers:    headerMatchers,
+		body:       bodyRegex,
+		encodeBody: bodyEncoder,
 	}, nil
 }
 
+var headerModes = map[string]headerMode{
+	"":      headerFirst,
+	"first": headerFirst,
+	"any":   headerAny,
+	"all":   headerAll,
+}
+
+// newHeaderMatcher creates a matcher for the given header pattern and its
+// compiled regex. A nil matcher is returned if the pattern does not constrain
+// the header.
+func newHeaderMatcher(name string, pattern request.HeaderPattern, regex *regexp.Regexp) (*headerMatcher, error) {
+	mode, ok := headerModes[pattern.Match]
+	if !ok {
+		return nil, fmt.Errorf("illegal match mode %s (expected first, any, or all)", pattern.Match)
+	}
+
+	if pattern.Absent {
+		if pattern.Pattern != "" || pattern.Match != "" || pattern.Index != nil {
+			return nil, fmt.Errorf("an absent header cannot have a pattern, match mode, or index")
+		}
+
+		return &headerMatcher{name: name, mode: headerAbsent}, nil
+	}
+
+	index := 0
+	if pattern.Index != nil {
+		if pattern.Match != "" {
+			return nil, fmt.Errorf("a header cannot have both a match mode and an index")
+		}
+
+		if *pattern.Index < 0 {
+			return nil, fmt.Errorf("illegal index %d (expected a non-negative number)", *pattern.Index)
+		}
+
+		mode, index = headerIndex, *pattern.Index
+	}
+
+	if regex == nil && mode == headerFirst {
+		return nil, nil
+	}
+
+	return &headerMatcher{name: name, regex: regex, mode: mode, index: index}, nil
+}
+
 func compile(val string) (*regexp.Regexp, error) {
 	if val == "" {
 		return nil, nil
@@ -61,3 +164,45 @@ func compile(val string) (*regexp.Regexp, error) {
 
 	return regexp.Compile(val)
 }
+
+// anchoredPrefix returns the literal text that immediately follows a
+// start-of-text anchor in the given pattern. Any construct that would
+// make the prefix ambiguous (alternation, case folding, a group, etc)
+// ends the prefix early.
+func anchoredPrefix(val string) string {
+	re, err := syntax.Parse(val, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 || re.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+
+	if literal := re.Sub[1]; literal.Op == syntax.OpLiteral && literal.Flags&syntax.FoldCase == 0 {
+		return string(literal.Rune)
+	}
+
+	return ""
+}
+
+func sortedQueryKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(m map[string]request.HeaderPattern) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
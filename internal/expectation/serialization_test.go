@@ -1,6 +1,8 @@
 package expectation
 
 import (
+	"fmt"
+
 	"github.com/aphistic/sweet"
 	"github.com/efritz/derision/internal/request"
 	. "github.com/onsi/gomega"
@@ -65,6 +67,54 @@ func (s *SerializationSuite) TestUnmarshalEmpty(t sweet.T) {
 	}
 }
 
+func (s *SerializationSuite) TestUnmarshalHeaders(t sweet.T) {
+	e, err := Unmarshal([]byte(`{
+		"headers": {
+			"content-type": "json",
+			"accept": {"pattern": "^text/(.*)$", "match": "any"},
+			"x-debug": {"absent": true}
+		}
+	}`))
+
+	Expect(err).To(BeNil())
+
+	match := e.Matches(&request.Request{Headers: map[string][]string{
+		"Content-Type": []string{"application/json"},
+		"Accept":       []string{"application/json", "text/html", "text/plain"},
+	}})
+
+	Expect(match).NotTo(BeNil())
+	Expect(match.HeaderGroups).To(Equal(map[string][]string{
+		"Content-Type": []string{"json"},
+		"Accept":       []string{"text/html", "html"},
+	}))
+	Expect(match.HeaderValueGroups["Accept"]).To(Equal([][]string{
+		{"text/html", "html"},
+		{"text/plain", "plain"},
+	}))
+
+	match = e.Matches(&request.Request{Headers: map[string][]string{
+		"Content-Type": []string{"application/json"},
+		"Accept":       []string{"text/html"},
+		"X-Debug":      []string{"true"},
+	}})
+
+	Expect(match).To(BeNil())
+}
+
+func (s *SerializationSuite) TestBadHeaders(t sweet.T) {
+	for payload, message := range map[string]string{
+		`{"headers": {"X-A": {"absent": true, "pattern": "."}}}`: "illegal header (an absent header cannot have a pattern, match mode, or index)",
+		`{"headers": {"X-A": {"match": "some"}}}`:                "illegal header (illegal match mode some (expected first, any, or all))",
+		`{"headers": {"X-A": {"match": "any", "index": 1}}}`:     "illegal header (a header cannot have both a match mode and an index)",
+		`{"headers": {"X-A": {"index": -1}}}`:                    "illegal header (illegal index -1 (expected a non-negative number))",
+		`{"headers": {"X-A": "foo", "x-a": "bar"}}`:              "duplicate header (x-a is also given as X-A)",
+	} {
+		_, err := Unmarshal([]byte(payload))
+		Expect(err).To(MatchError(message))
+	}
+}
+
 func (s *SerializationSuite) TestBadJSON(t sweet.T) {
 	_, err := Unmarshal([]byte(``))
 	Expect(err).To(MatchError("failed to unmarshal payload (unexpected end of JSON input)"))
@@ -72,20 +122,80 @@ func (s *SerializationSuite) TestBadJSON(t sweet.T) {
 
 func (s *SerializationSuite) TestBadMethodRegex(t sweet.T) {
 	_, err := Unmarshal([]byte(`{"method": "("}`))
-	Expect(err).To(MatchError("illegal method regex"))
+	Expect(err).To(MatchError("illegal method regex (error parsing regexp: missing closing ): `(`)"))
+	Expect(err.(*CompileError).Pointer).To(Equal("/method"))
 }
 
 func (s *SerializationSuite) TestBadPathRegex(t sweet.T) {
 	_, err := Unmarshal([]byte(`{"path": "("}`))
-	Expect(err).To(MatchError("illegal path regex"))
+	Expect(err).To(MatchError("illegal path regex (error parsing regexp: missing closing ): `(`)"))
+	Expect(err.(*CompileError).Pointer).To(Equal("/path"))
+}
+
+func (s *SerializationSuite) TestUnmarshalQuery(t sweet.T) {
+	e, err := Unmarshal([]byte(`{"query": {"q": "^foo$", "page": ""}}`))
+	Expect(err).To(BeNil())
+	Expect(e.Matches(&request.Request{Query: map[string][]string{"q": {"foo"}}})).NotTo(BeNil())
+	Expect(e.Matches(&request.Request{Query: map[string][]string{"q": {"bar"}}})).To(BeNil())
+}
+
+func (s *SerializationSuite) TestBadQueryRegex(t sweet.T) {
+	_, err := Unmarshal([]byte(`{"query": {"a/b": "("}}`))
+	Expect(err).To(MatchError("illegal query regex (error parsing regexp: missing closing ): `(`)"))
+	Expect(err.(*CompileError).Pointer).To(Equal("/query/a~1b"))
 }
 
 func (s *SerializationSuite) TestHeaderPathRegex(t sweet.T) {
-	_, err := Unmarshal([]byte(`{"headers": {"Authorization": "("}}`))
-	Expect(err).To(MatchError("illegal header regex"))
+	_, err := Unmarshal([]byte(`{"headers": {"X/Y": "("}}`))
+	Expect(err).To(MatchError("illegal header regex (error parsing regexp: missing closing ): `(`)"))
+	Expect(err.(*CompileError).Pointer).To(Equal("/headers/X~1Y"))
 }
 
 func (s *SerializationSuite) TestBadBodyRegex(t sweet.T) {
 	_, err := Unmarshal([]byte(`{"body": "("}`))
-	Expect(err).To(MatchError("illegal body regex"))
+	Expect(err).To(MatchError("illegal body regex (error parsing regexp: missing closing ): `(`)"))
+	Expect(err.(*CompileError).Pointer).To(Equal("/body"))
+}
+
+func (s *SerializationSuite) TestBodyEncoding(t sweet.T) {
+	e, err := Unmarshal([]byte(`{"body": "^cafe", "body_encoding": "hex"}`))
+	Expect(err).To(BeNil())
+	Expect(e.Matches(&request.Request{Body: "\xca\xfe"})).NotTo(BeNil())
+	Expect(e.Matches(&request.Request{Body: "cafe"})).To(BeNil())
+}
+
+func (s *SerializationSuite) TestBadBodyEncoding(t sweet.T) {
+	_, err := Unmarshal([]byte(`{"body": "foo", "body_encoding": "rot13"}`))
+	Expect(err).To(MatchError("illegal body encoding (expected hex or base64, got rot13)"))
+	Expect(err.(*CompileError).Pointer).To(Equal("/body_encoding"))
+}
+
+func (s *SerializationSuite) TestPathPrefix(t sweet.T) {
+	for pattern, prefix := range map[string]string{
+		"":                 "",
+		"/users":           "",
+		"^/users":          "/users",
+		"^/users/(\\d+)$":  "/users/",
+		"^/users/\\d+":     "/users/",
+		"(?i)^/users":      "",
+		"^/a|^/b":          "",
+		"^(/users)/(\\d+)": "",
+		"\\A/users/(.*)":   "/users/",
+	} {
+		e, err := Unmarshal([]byte(fmt.Sprintf(`{"path": %q}`, pattern)))
+		Expect(err).To(BeNil())
+		Expect(e.PathPrefix()).To(Equal(prefix))
+	}
+}
+
+func (s *SerializationSuite) TestMatchesMethod(t sweet.T) {
+	e1, err := Unmarshal([]byte(`{"method": "GET|POST"}`))
+	Expect(err).To(BeNil())
+	Expect(e1.MatchesMethod("GET")).To(BeTrue())
+	Expect(e1.MatchesMethod("POST")).To(BeTrue())
+	Expect(e1.MatchesMethod("PUT")).To(BeFalse())
+
+	e2, err := Unmarshal([]byte(`{}`))
+	Expect(err).To(BeNil())
+	Expect(e2.MatchesMethod("PUT")).To(BeTrue())
 }
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/efritz/derision/internal/request"
+)
+
+type (
+	// snapshot is an immutable, ordered list of registrations. Per-method
+	// indexes over the list are built lazily the first time a request with
+	// that method is handled. Only the standard methods are indexed on their
+	// own; requests with any other method share a single index so that the
+	// number of indexes is bounded.
+	snapshot struct {
+		registrations []*Registration
+		indexes       map[string]*methodIndex
+	}
+
+	// methodIndex is a lazily built path index over the registrations that
+	// accept a particular method.
+	methodIndex struct {
+		once  sync.Once
+		index *pathIndex
+	}
+
+	// pathIndex is a trie over the literal path prefixes of registrations.
+	// Each node holds the ranks (positions within the snapshot) of the
+	// registrations whose prefix ends at that node.
+	pathIndex struct {
+		ranks    []int
+		children map[byte]*pathIndex
+	}
+)
+
+// otherMethods is the key of the index shared by non-standard methods.
+const otherMethods = ""
+
+var standardMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+func newSnapshot(registrations []*Registration) *snapshot {
+	indexes := map[string]*methodIndex{otherMethods: &methodIndex{}}
+	for _, method := range standardMethods {
+		indexes[method] = &methodIndex{}
+	}
+
+	return &snapshot{
+		registrations: registrations,
+		indexes:       indexes,
+	}
+}
+
+// candidates returns the registrations that could respond to the given
+// request in the same relative order that they appear in the snapshot.
+func (s *snapshot) candidates(r *request.Request) []*Registration {
+	ranks := s.index(r.Method).lookup(r.Path)
+
+	candidates := make([]*Registration, 0, len(ranks))
+	for _, rank := range ranks {
+		candidates = append(candidates, s.registrations[rank])
+	}
+
+	return candidates
+}
+
+func (s *snapshot) index(method string) *pathIndex {
+	key := method
+	if _, ok := s.indexes[key]; !ok {
+		key = otherMethods
+	}
+
+	methodIndex := s.indexes[key]
+	methodIndex.once.Do(func() {
+		methodIndex.index = s.buildIndex(key)
+	})
+
+	return methodIndex.index
+}
+
+// buildIndex creates a path index over the registrations that accept the
+// given method. The index of non-standard methods contains every registration,
+// as the method of each request is still checked by its registration.
+func (s *snapshot) buildIndex(method string) *pathIndex {
+	index := &pathIndex{}
+	for rank, registration := range s.registrations {
+		if method == otherMethods || registration.methodFilter(method) {
+			index.insert(registration.pathPrefix, rank)
+		}
+	}
+
+	return index
+}
+
+func (i *pathIndex) insert(prefix string, rank int) {
+	node := i
+	for j := 0; j < len(prefix); j++ {
+		if node.children == nil {
+			node.children = map[byte]*pathIndex{}
+		}
+
+		child, ok := node.children[prefix[j]]
+		if !ok {
+			child = &pathIndex{}
+			node.children[prefix[j]] = child
+		}
+
+		node = child
+	}
+
+	node.ranks = append(node.ranks, rank)
+}
+
+func (i *pathIndex) lookup(path string) []int {
+	ranks := append([]int{}, i.ranks...)
+
+	node := i
+	for j := 0; j < len(path); j++ {
+		if node = node.children[path[j]]; node == nil {
+			break
+		}
+
+		ranks = append(ranks, node.ranks...)
+	}
+
+	sort.Ints(ranks)
+	return ranks
+}
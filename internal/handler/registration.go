@@ -0,0 +1,65 @@
+package handler
+
+type (
+	Registration struct {
+		handler      Handler
+		priority     int
+		position     Position
+		methodFilter func(method string) bool
+		pathPrefix   string
+		fallback     bool
+		source       string
+		sequence     int
+	}
+
+	RegistrationConfigFunc func(*Registration)
+
+	Position int
+)
+
+const (
+	PositionDefault Position = iota
+	PositionFirst
+)
+
+func NewRegistration(handler Handler, configs ...RegistrationConfigFunc) *Registration {
+	r := &Registration{
+		handler:      handler,
+		position:     PositionDefault,
+		methodFilter: func(method string) bool { return true },
+	}
+
+	for _, f := range configs {
+		f(r)
+	}
+
+	return r
+}
+
+func WithPriority(priority int) RegistrationConfigFunc {
+	return func(r *Registration) { r.priority = priority }
+}
+
+func WithPosition(position Position) RegistrationConfigFunc {
+	return func(r *Registration) { r.position = position }
+}
+
+// WithFallback marks the handler as a fallback. Fallback handlers are
+// evaluated only after all other handlers have declined to respond.
+func WithFallback() RegistrationConfigFunc {
+	return func(r *Registration) { r.fallback = true }
+}
+
+// WithMethodFilter sets a function that determines whether the handler
+// can possibly respond to a request with the given method. The handler
+// set uses this to skip the handler for other methods.
+func WithMethodFilter(methodFilter func(method string) bool) RegistrationConfigFunc {
+	return func(r *Registration) { r.methodFilter = methodFilter }
+}
+
+// WithPathPrefix sets a literal prefix that every path to which the handler
+// can possibly respond begins with. The handler set uses this to skip the
+// handler for other paths.
+func WithPathPrefix(pathPrefix string) RegistrationConfigFunc {
+	return func(r *Registration) { r.pathPrefix = pathPrefix }
+}
@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/efritz/derision/internal/request"
 	"github.com/efritz/response"
@@ -10,23 +12,59 @@ import (
 type (
 	HandlerSet interface {
 		Handle(r *request.Request) (response.Response, error)
-		Add(handler Handler)
+		Add(handler Handler, configs ...RegistrationConfigFunc)
+		Append(registrations []*Registration)
+		Set(registrations []*Registration)
+		Replace(source string, registrations []*Registration)
 		Clear()
 	}
 
+	// handlerSet holds an immutable snapshot of its registrations. Writers
+	// serialize on the mutex and publish a fresh snapshot so that readers
+	// never need to take a lock or observe a partially updated list.
+	//
+	// Each registration is given a sequence number in the order in which it
+	// was added. The registrations of a source share the sequence number of
+	// the first Replace call with that source.
 	handlerSet struct {
-		handlers []Handler
-		mutex    sync.RWMutex
+		tieBreak  TieBreak
+		snapshots atomic.Value
+		mutex     sync.Mutex
+		sequence  int
+		sources   map[string]int
 	}
+
+	HandlerSetConfigFunc func(*handlerSet)
+
+	TieBreak int
+)
+
+const (
+	TieBreakOrder TieBreak = iota
+	TieBreakRecency
 )
 
-func NewHandlerSet() *handlerSet {
-	return &handlerSet{}
+func NewHandlerSet(configs ...HandlerSetConfigFunc) *handlerSet {
+	s := &handlerSet{
+		tieBreak: TieBreakOrder,
+		sources:  map[string]int{},
+	}
+
+	for _, f := range configs {
+		f(s)
+	}
+
+	s.snapshots.Store(newSnapshot(nil))
+	return s
+}
+
+func WithTieBreak(tieBreak TieBreak) HandlerSetConfigFunc {
+	return func(s *handlerSet) { s.tieBreak = tieBreak }
 }
 
 func (s *handlerSet) Handle(r *request.Request) (response.Response, error) {
-	for _, handler := range s.handlers {
-		if resp, err := handler(r); err != nil || resp != nil {
+	for _, registration := range s.snapshot().candidates(r) {
+		if resp, err := registration.handler(r); err != nil || resp != nil {
 			return resp, err
 		}
 	}
@@ -34,14 +72,130 @@ func (s *handlerSet) Handle(r *request.Request) (response.Response, error) {
 	return nil, nil
 }
 
-func (s *handlerSet) Add(handler Handler) {
+func (s *handlerSet) Add(handler Handler, configs ...RegistrationConfigFunc) {
+	s.Append([]*Registration{NewRegistration(handler, configs...)})
+}
+
+func (s *handlerSet) Append(registrations []*Registration) {
 	s.mutex.Lock()
-	s.handlers = append(s.handlers, handler)
-	s.mutex.Unlock()
+	defer s.mutex.Unlock()
+
+	current := s.snapshot().registrations
+	updated := make([]*Registration, len(current), len(current)+len(registrations))
+	copy(updated, current)
+
+	for _, registration := range registrations {
+		registration.sequence = s.next()
+		updated = s.insert(updated, registration)
+	}
+
+	s.snapshots.Store(newSnapshot(updated))
+}
+
+func (s *handlerSet) Set(registrations []*Registration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	replacement := make([]*Registration, 0, len(registrations))
+	for _, registration := range registrations {
+		registration.sequence = s.next()
+		replacement = s.insert(replacement, registration)
+	}
+
+	s.sources = map[string]int{}
+	s.snapshots.Store(newSnapshot(replacement))
+}
+
+// Replace removes the registrations previously added by a call to Replace
+// with the same source and inserts the given registrations in their place,
+// leaving all other registrations alone. The new registrations are ordered
+// as if they were added at the time of the first call with the source, so
+// that registrations added in the meantime keep their relative order.
+func (s *handlerSet) Replace(source string, registrations []*Registration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sequence, ok := s.sources[source]
+	if !ok {
+		sequence = s.next()
+		s.sources[source] = sequence
+	}
+
+	current := s.snapshot().registrations
+	history := make([]*Registration, 0, len(current)+len(registrations))
+	for _, registration := range current {
+		if registration.source != source {
+			history = append(history, registration)
+		}
+	}
+
+	for _, registration := range registrations {
+		registration.source = source
+		registration.sequence = sequence
+		history = append(history, registration)
+	}
+
+	// Replay the insertions in the order in which they were made. Ties
+	// (within a source) are kept in the given order.
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].sequence < history[j].sequence
+	})
+
+	updated := make([]*Registration, 0, len(history))
+	for _, registration := range history {
+		updated = s.insert(updated, registration)
+	}
+
+	s.snapshots.Store(newSnapshot(updated))
 }
 
 func (s *handlerSet) Clear() {
 	s.mutex.Lock()
-	s.handlers = s.handlers[:0]
+	s.sources = map[string]int{}
+	s.snapshots.Store(newSnapshot(nil))
 	s.mutex.Unlock()
 }
+
+// next returns the next sequence number. The mutex must be held.
+func (s *handlerSet) next() int {
+	s.sequence++
+	return s.sequence
+}
+
+func (s *handlerSet) snapshot() *snapshot {
+	return s.snapshots.Load().(*snapshot)
+}
+
+// search returns the index at which the registration should be inserted
+// into the given list, which is ordered by descending priority with all
+// fallback registrations at the end. Registrations with equal priority are
+// ordered by the set's tie break unless the registration requests to go
+// first.
+func (s *handlerSet) search(registrations []*Registration, registration *Registration) int {
+	return sort.Search(len(registrations), func(i int) bool {
+		return s.precedes(registration, registrations[i])
+	})
+}
+
+// insert places the registration into the given list in-place. The list
+// must not be visible to readers.
+func (s *handlerSet) insert(registrations []*Registration, registration *Registration) []*Registration {
+	index := s.search(registrations, registration)
+
+	registrations = append(registrations, nil)
+	copy(registrations[index+1:], registrations[index:])
+	registrations[index] = registration
+	return registrations
+}
+
+func (s *handlerSet) precedes(registration, other *Registration) bool {
+	if registration.fallback != other.fallback {
+		return other.fallback
+	}
+
+	if registration.priority != other.priority {
+		return registration.priority > other.priority
+	}
+
+	return registration.position == PositionFirst || s.tieBreak == TieBreakRecency
+}
@@ -3,6 +3,8 @@ package handler
 import (
 	"fmt"
 	"net/http"
+	"sync"
+	"testing"
 
 	"github.com/aphistic/sweet"
 	"github.com/efritz/derision/internal/request"
@@ -64,12 +66,365 @@ func (s *SetSuite) TestHandleClear(t sweet.T) {
 	Expect(resp).To(BeNil())
 }
 
+func (s *SetSuite) TestHandleSet(t sweet.T) {
+	set := NewHandlerSet()
+	set.Add(makeHandler("/foo", http.StatusOK))
+	set.Add(makeHandler("/bar", http.StatusOK))
+
+	set.Set([]*Registration{
+		NewRegistration(makeHandler("/bar", http.StatusCreated)),
+		NewRegistration(makeHandler("/baz", http.StatusAccepted)),
+	})
+
+	resp, err := set.Handle(&request.Request{Path: "/foo"})
+	Expect(err).To(BeNil())
+	Expect(resp).To(BeNil())
+
+	resp, err = set.Handle(&request.Request{Path: "/bar"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(201))
+
+	resp, err = set.Handle(&request.Request{Path: "/baz"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(202))
+}
+
+func (s *SetSuite) TestHandleAppend(t sweet.T) {
+	set := NewHandlerSet()
+	set.Add(makeHandler("/foo", http.StatusOK))
+	set.Add(makeHandler("/bar", http.StatusOK))
+
+	set.Append([]*Registration{
+		NewRegistration(makeHandler("/bar", http.StatusCreated), WithPriority(1)),
+		NewRegistration(makeHandler("/baz", http.StatusAccepted)),
+	})
+
+	resp, err := set.Handle(&request.Request{Path: "/foo"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(200))
+
+	resp, err = set.Handle(&request.Request{Path: "/bar"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(201))
+
+	resp, err = set.Handle(&request.Request{Path: "/baz"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(202))
+}
+
+func (s *SetSuite) TestHandleReplace(t sweet.T) {
+	set := NewHandlerSet()
+	set.Replace("config", []*Registration{
+		NewRegistration(makeHandler("/foo", http.StatusOK)),
+		NewRegistration(makeHandler("/bar", http.StatusOK)),
+	})
+
+	set.Add(makeHandler("/baz", http.StatusOK))
+
+	set.Replace("config", []*Registration{
+		NewRegistration(makeHandler("/bar", http.StatusCreated)),
+	})
+
+	resp, err := set.Handle(&request.Request{Path: "/foo"})
+	Expect(err).To(BeNil())
+	Expect(resp).To(BeNil())
+
+	resp, err = set.Handle(&request.Request{Path: "/bar"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(201))
+
+	resp, err = set.Handle(&request.Request{Path: "/baz"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(200))
+}
+
+func (s *SetSuite) TestHandleReplaceKeepsOrder(t sweet.T) {
+	for _, tieBreak := range []TieBreak{TieBreakOrder, TieBreakRecency} {
+		set := NewHandlerSet(WithTieBreak(tieBreak))
+		set.Add(makeHandler("/early", http.StatusAccepted))
+		set.Replace("config", []*Registration{NewRegistration(makeHandler("/a", http.StatusOK))})
+		set.Add(makeHandler("", http.StatusTeapot))
+
+		// The catch-all was added last, so it wins only by recency
+		expected, expectedEarly := http.StatusOK, http.StatusAccepted
+		if tieBreak == TieBreakRecency {
+			expected, expectedEarly = http.StatusTeapot, http.StatusTeapot
+		}
+
+		for i := 0; i < 2; i++ {
+			resp, err := set.Handle(&request.Request{Path: "/a"})
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode()).To(Equal(expected))
+
+			resp, err = set.Handle(&request.Request{Path: "/early"})
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode()).To(Equal(expectedEarly))
+
+			// A reload puts the registrations back where they were
+			set.Replace("config", []*Registration{NewRegistration(makeHandler("/a", http.StatusOK))})
+		}
+	}
+}
+
+func (s *SetSuite) TestHandlePriority(t sweet.T) {
+	set := NewHandlerSet()
+	set.Add(makeHandler("/foo", http.StatusOK))
+	set.Add(makeHandler("/foo", http.StatusCreated), WithPriority(10))
+	set.Add(makeHandler("/foo", http.StatusAccepted), WithPriority(5))
+
+	resp, err := set.Handle(&request.Request{Path: "/foo"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(201))
+}
+
+func (s *SetSuite) TestHandlePositionFirst(t sweet.T) {
+	set := NewHandlerSet()
+	set.Add(makeHandler("/foo", http.StatusOK))
+	set.Add(makeHandler("/foo", http.StatusCreated))
+	set.Add(makeHandler("/foo", http.StatusAccepted), WithPosition(PositionFirst))
+	set.Add(makeHandler("/foo", http.StatusConflict), WithPriority(-1), WithPosition(PositionFirst))
+
+	resp, err := set.Handle(&request.Request{Path: "/foo"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(202))
+}
+
+func (s *SetSuite) TestHandleTieBreakRecency(t sweet.T) {
+	set := NewHandlerSet(WithTieBreak(TieBreakRecency))
+	set.Add(makeHandler("/foo", http.StatusOK), WithPriority(5))
+	set.Add(makeHandler("/foo", http.StatusCreated))
+	set.Add(makeHandler("/foo", http.StatusAccepted))
+
+	resp, err := set.Handle(&request.Request{Path: "/foo"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(200))
+
+	set.Set([]*Registration{
+		NewRegistration(makeHandler("/foo", http.StatusOK)),
+		NewRegistration(makeHandler("/foo", http.StatusCreated)),
+		NewRegistration(makeHandler("/foo", http.StatusAccepted)),
+	})
+
+	resp, err = set.Handle(&request.Request{Path: "/foo"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(202))
+}
+
+func (s *SetSuite) TestHandleFallback(t sweet.T) {
+	set := NewHandlerSet()
+	set.Add(makeHandler("", http.StatusNotImplemented), WithFallback(), WithPriority(10), WithPosition(PositionFirst))
+	set.Add(makeHandler("/foo", http.StatusOK), WithPriority(-10))
+	set.Add(makeHandler("", http.StatusNotFound), WithFallback())
+
+	resp, err := set.Handle(&request.Request{Path: "/foo"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(200))
+
+	resp, err = set.Handle(&request.Request{Path: "/bar"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(501))
+}
+
+func (s *SetSuite) TestHandleIndexed(t sweet.T) {
+	set := NewHandlerSet()
+	set.Add(makeHandler("/users/1", http.StatusOK), WithPathPrefix("/users/"), WithMethodFilter(isMethod("GET")))
+	set.Add(makeHandler("/users/1", http.StatusCreated), WithPathPrefix("/users/"), WithMethodFilter(isMethod("POST")))
+	set.Add(makeHandler("/users/1", http.StatusAccepted), WithPathPrefix("/groups/"))
+	set.Add(makeHandler("/users/1", http.StatusConflict), WithPathPrefix("/"))
+
+	resp, err := set.Handle(&request.Request{Method: "GET", Path: "/users/1"})
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode()).To(Equal(200))
+
+	resp, err = set.Handle(&request.Request{Method: "POST", Path: "/users/1"})
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode()).To(Equal(201))
+
+	resp, err = set.Handle(&request.Request{Method: "PUT", Path: "/users/1"})
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode()).To(Equal(409))
+}
+
+func (s *SetSuite) TestHandleIndexedOtherMethods(t sweet.T) {
+	set := NewHandlerSet()
+	set.Add(makeHandler("/users/1", http.StatusOK), WithPathPrefix("/users/"), WithMethodFilter(isMethod("GET")))
+	set.Add(makeHandler("/users/1", http.StatusCreated), WithPathPrefix("/groups/"))
+
+	for i := 0; i < 100; i++ {
+		candidates := set.snapshot().candidates(&request.Request{Method: fmt.Sprintf("M%d", i), Path: "/users/1"})
+		Expect(candidates).To(HaveLen(1))
+	}
+
+	// Non-standard methods share a single index
+	Expect(set.snapshot().indexes).To(HaveLen(len(standardMethods) + 1))
+}
+
+func (s *SetSuite) TestHandleIndexedPreservesOrder(t sweet.T) {
+	set := NewHandlerSet()
+	set.Add(makeHandler("/users/1", http.StatusOK), WithPathPrefix("/"))
+	set.Add(makeHandler("/users/1", http.StatusCreated), WithPathPrefix("/users/"), WithPriority(1))
+	set.Add(makeHandler("/users/1", http.StatusAccepted))
+
+	resp, err := set.Handle(&request.Request{Method: "GET", Path: "/users/1"})
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode()).To(Equal(201))
+
+	Expect(set.snapshot().candidates(&request.Request{Method: "GET", Path: "/users/1"})).To(Equal(set.snapshot().registrations))
+	Expect(set.snapshot().candidates(&request.Request{Method: "GET", Path: "/groups"})).To(HaveLen(2))
+	Expect(set.snapshot().candidates(&request.Request{Method: "GET", Path: "groups"})).To(HaveLen(1))
+}
+
+func (s *SetSuite) TestConcurrentAccess(t sweet.T) {
+	set := NewHandlerSet()
+	set.Add(makeHandler("/foo", http.StatusOK))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(4)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				set.Handle(&request.Request{Path: "/foo"})
+			}
+		}()
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				set.Add(makeHandler(fmt.Sprintf("/%d/%d", i, j), http.StatusOK), WithPriority(j%3))
+			}
+		}(i)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				set.Set([]*Registration{NewRegistration(makeHandler("/foo", http.StatusOK))})
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				set.Clear()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	set.Clear()
+	set.Add(makeHandler("/foo", http.StatusCreated))
+
+	resp, err := set.Handle(&request.Request{Path: "/foo"})
+	Expect(err).To(BeNil())
+	Expect(resp).NotTo(BeNil())
+	Expect(resp.StatusCode()).To(Equal(201))
+}
+
+func (s *SetSuite) TestClearRetainsSnapshot(t sweet.T) {
+	set := NewHandlerSet()
+	set.Add(makeHandler("/foo", http.StatusOK))
+	set.Add(makeHandler("/bar", http.StatusOK))
+
+	snapshot := set.snapshot()
+	set.Clear()
+	set.Add(makeHandler("/baz", http.StatusOK))
+
+	Expect(snapshot.registrations).To(HaveLen(2))
+	Expect(set.snapshot().registrations).To(HaveLen(1))
+}
+
+func BenchmarkHandle100(b *testing.B)  { benchmarkHandle(b, 100) }
+func BenchmarkHandle1000(b *testing.B) { benchmarkHandle(b, 1000) }
+func BenchmarkHandle5000(b *testing.B) { benchmarkHandle(b, 5000) }
+
+func benchmarkHandle(b *testing.B, n int) {
+	set := NewHandlerSet()
+	for i := 0; i < n; i++ {
+		set.Add(makeHandler(fmt.Sprintf("/%d", i), http.StatusOK))
+	}
+
+	r := &request.Request{Path: fmt.Sprintf("/%d", n-1)}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			set.Handle(r)
+		}
+	})
+}
+
+func BenchmarkHandleIndexed5000(b *testing.B) {
+	set := NewHandlerSet()
+	for i := 0; i < 5000; i++ {
+		path := fmt.Sprintf("/%d/", i)
+		set.Add(makeHandler(path, http.StatusOK), WithPathPrefix(path), WithMethodFilter(isMethod("GET")))
+	}
+
+	r := &request.Request{Method: "GET", Path: "/4999/"}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			set.Handle(r)
+		}
+	})
+}
+
+func BenchmarkAdd(b *testing.B) {
+	set := NewHandlerSet()
+
+	for i := 0; i < b.N; i++ {
+		if i%5000 == 0 {
+			set.Clear()
+		}
+
+		set.Add(makeHandler("/foo", http.StatusOK), WithPriority(i%10))
+	}
+}
+
+func BenchmarkSet5000(b *testing.B) {
+	registrations := []*Registration{}
+	for i := 0; i < 5000; i++ {
+		registrations = append(registrations, NewRegistration(makeHandler(fmt.Sprintf("/%d", i), http.StatusOK), WithPriority(i%10)))
+	}
+
+	set := NewHandlerSet()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		set.Set(registrations)
+	}
+}
+
 func makeHandler(path string, status int) Handler {
 	return func(r *request.Request) (response.Response, error) {
-		if r.Path == path {
+		if path == "" || r.Path == path {
 			return response.Empty(status), nil
 		}
 
 		return nil, nil
 	}
 }
+
+func isMethod(method string) func(string) bool {
+	return func(m string) bool { return m == method }
+}
@@ -0,0 +1,123 @@
+package har
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/efritz/derision/internal/payload"
+)
+
+// ignoredHeaders are response headers that describe the recorded transfer
+// rather than the response itself. The body of a HAR entry is already
+// decoded, so replaying these headers would misdescribe it.
+var ignoredHeaders = map[string]struct{}{
+	"Connection":        struct{}{},
+	"Content-Encoding":  struct{}{},
+	"Content-Length":    struct{}{},
+	"Keep-Alive":        struct{}{},
+	"Transfer-Encoding": struct{}{},
+}
+
+// Convert generates one handler payload for each distinct method and path
+// recorded in the given HAR document. When the same method and path were
+// recorded more than once, the first recorded response is used. Entries
+// without a response (e.g. aborted requests) are skipped.
+func Convert(data []byte) ([]payload.Handler, error) {
+	document, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]struct{}{}
+	handlers := []payload.Handler{}
+
+	for i, entry := range document.Log.Entries {
+		if entry == nil || entry.Request == nil || entry.Response == nil || entry.Response.Status == 0 {
+			continue
+		}
+
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse URL of entry %d (%s)", i, err.Error())
+		}
+
+		path := u.Path
+		if path == "" {
+			path = "/"
+		}
+
+		key := entry.Request.Method + " " + path
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+
+		response, err := convertResponse(entry.Response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert response of entry %d (%s)", i, err.Error())
+		}
+
+		handlers = append(handlers, payload.Handler{
+			Request: payload.Request{
+				Method: payload.Exact(entry.Request.Method),
+				Path:   payload.Exact(path),
+			},
+			Response: response,
+		})
+	}
+
+	return handlers, nil
+}
+
+func convertResponse(r *Response) (payload.Response, error) {
+	headers := map[string][]string{}
+	for _, header := range r.Headers {
+		name := http.CanonicalHeaderKey(header.Name)
+		if _, ok := ignoredHeaders[name]; ok {
+			continue
+		}
+
+		headers[name] = append(headers[name], payload.Literal(header.Value))
+	}
+
+	body, bodyBase64 := "", ""
+	if r.Content != nil {
+		body = r.Content.Text
+
+		if r.Content.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(body)
+			if err != nil {
+				return payload.Response{}, fmt.Errorf("illegal base64 content (%s)", err.Error())
+			}
+
+			// Binary content cannot be given as a (JSON string) template,
+			// so it is served verbatim from its base64 encoding instead
+			if utf8.Valid(decoded) {
+				body = string(decoded)
+			} else {
+				body, bodyBase64 = "", r.Content.Text
+			}
+		}
+
+		if _, ok := headers["Content-Type"]; !ok && r.Content.MimeType != "" {
+			headers["Content-Type"] = []string{payload.Literal(r.Content.MimeType)}
+		}
+	}
+
+	response := payload.Response{
+		StatusCode: strconv.Itoa(r.Status),
+		Headers:    headers,
+		BodyBase64: bodyBase64,
+	}
+
+	if bodyBase64 == "" {
+		response.Body = payload.Literal(body)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,110 @@
+package har
+
+import (
+	"encoding/json"
+
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/internal/payload"
+	. "github.com/onsi/gomega"
+)
+
+type ConvertSuite struct{}
+
+func (s *ConvertSuite) TestConvert(t sweet.T) {
+	handlers, err := Convert([]byte(`{
+		"log": {
+			"version": "1.2",
+			"entries": [
+				{
+					"request": {"method": "GET", "url": "https://api.example.com/users/1?expand=true"},
+					"response": {
+						"status": 200,
+						"headers": [
+							{"name": "content-type", "value": "application/json"},
+							{"name": "Content-Length", "value": "16"},
+							{"name": "Set-Cookie", "value": "a=1"},
+							{"name": "Set-Cookie", "value": "b=2"}
+						],
+						"content": {"size": 16, "mimeType": "application/json", "text": "{\"name\":\"{{x}}\"}"}
+					}
+				},
+				{
+					"request": {"method": "GET", "url": "https://api.example.com/users/1"},
+					"response": {"status": 500}
+				},
+				{
+					"request": {"method": "GET", "url": "https://api.example.com/aborted"},
+					"response": {"status": 0}
+				},
+				{
+					"request": {"method": "POST", "url": "https://api.example.com"},
+					"response": {
+						"status": 201,
+						"content": {"mimeType": "text/plain", "text": "Y3JlYXRlZA==", "encoding": "base64"}
+					}
+				}
+			]
+		}
+	}`))
+
+	Expect(err).To(BeNil())
+	Expect(handlers).To(Equal([]payload.Handler{
+		{
+			Request: payload.Request{Method: "^GET$", Path: "^/users/1$"},
+			Response: payload.Response{
+				StatusCode: "200",
+				Headers: map[string][]string{
+					"Content-Type": {"application/json"},
+					"Set-Cookie":   {"a=1", "b=2"},
+				},
+				Body: `{{"{\"name\":\"{{x}}\"}"}}`,
+			},
+		},
+		{
+			Request: payload.Request{Method: "^POST$", Path: "^/$"},
+			Response: payload.Response{
+				StatusCode: "201",
+				Headers:    map[string][]string{"Content-Type": {"text/plain"}},
+				Body:       "created",
+			},
+		},
+	}))
+}
+
+func (s *ConvertSuite) TestConvertIllegalContent(t sweet.T) {
+	_, err := Convert([]byte(`{
+		"log": {
+			"entries": [
+				{
+					"request": {"method": "GET", "url": "/a"},
+					"response": {"status": 200, "content": {"text": "!!", "encoding": "base64"}}
+				}
+			]
+		}
+	}`))
+
+	Expect(err).To(MatchError(ContainSubstring("failed to convert response of entry 0 (illegal base64 content")))
+}
+
+func (s *ConvertSuite) TestConvertBinaryContent(t sweet.T) {
+	handlers, err := Convert([]byte(`{
+		"log": {
+			"entries": [
+				{
+					"request": {"method": "GET", "url": "/image"},
+					"response": {"status": 200, "content": {"mimeType": "image/jpeg", "text": "/9j/", "encoding": "base64"}}
+				}
+			]
+		}
+	}`))
+
+	Expect(err).To(BeNil())
+	Expect(handlers).To(HaveLen(1))
+	Expect(handlers[0].Response.Body).To(BeEmpty())
+	Expect(handlers[0].Response.BodyBase64).To(Equal("/9j/"))
+
+	// The body survives serialization of the converted handlers
+	serialized, err := json.Marshal(handlers)
+	Expect(err).To(BeNil())
+	Expect(string(serialized)).To(ContainSubstring(`"body_base64":"/9j/"`))
+}
@@ -0,0 +1,111 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type (
+	// Document is an HTTP Archive (HAR) 1.2 document. Only the fields used
+	// by derision are modeled.
+	Document struct {
+		Log *Log `json:"log"`
+	}
+
+	Log struct {
+		Version string   `json:"version"`
+		Creator *Creator `json:"creator"`
+		Entries []*Entry `json:"entries"`
+	}
+
+	Creator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	Entry struct {
+		StartedDateTime string    `json:"startedDateTime"`
+		Time            float64   `json:"time"`
+		Request         *Request  `json:"request"`
+		Response        *Response `json:"response"`
+		Cache           struct{}  `json:"cache"`
+		Timings         *Timings  `json:"timings"`
+	}
+
+	Request struct {
+		Method      string    `json:"method"`
+		URL         string    `json:"url"`
+		HTTPVersion string    `json:"httpVersion"`
+		Cookies     []*Pair   `json:"cookies"`
+		Headers     []*Pair   `json:"headers"`
+		QueryString []*Pair   `json:"queryString"`
+		PostData    *PostData `json:"postData,omitempty"`
+		HeadersSize int       `json:"headersSize"`
+		BodySize    int       `json:"bodySize"`
+	}
+
+	Response struct {
+		Status      int      `json:"status"`
+		StatusText  string   `json:"statusText"`
+		HTTPVersion string   `json:"httpVersion"`
+		Cookies     []*Pair  `json:"cookies"`
+		Headers     []*Pair  `json:"headers"`
+		Content     *Content `json:"content"`
+		RedirectURL string   `json:"redirectURL"`
+		HeadersSize int      `json:"headersSize"`
+		BodySize    int      `json:"bodySize"`
+	}
+
+	Pair struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	PostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	}
+
+	Content struct {
+		Size     int    `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty"`
+	}
+
+	Timings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+)
+
+// IsDocument determines if the given JSON data looks like a HAR document
+// (an object with a log containing a list of entries).
+func IsDocument(data []byte) bool {
+	document := &struct {
+		Log *struct {
+			Entries []json.RawMessage `json:"entries"`
+		} `json:"log"`
+	}{}
+
+	if err := json.Unmarshal(data, document); err != nil {
+		return false
+	}
+
+	return document.Log != nil && document.Log.Entries != nil
+}
+
+// Parse unmarshals a HAR document from JSON data.
+func Parse(data []byte) (*Document, error) {
+	document := &Document{}
+	if err := json.Unmarshal(data, document); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal document (%s)", err.Error())
+	}
+
+	if document.Log == nil {
+		return nil, fmt.Errorf("missing log")
+	}
+
+	return document, nil
+}
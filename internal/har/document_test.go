@@ -0,0 +1,23 @@
+package har
+
+import (
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type DocumentSuite struct{}
+
+func (s *DocumentSuite) TestIsDocument(t sweet.T) {
+	Expect(IsDocument([]byte(`{"log": {"version": "1.2", "entries": []}}`))).To(BeTrue())
+	Expect(IsDocument([]byte(`{"log": {"version": "1.2"}}`))).To(BeFalse())
+	Expect(IsDocument([]byte(`{"openapi": "3.0.0"}`))).To(BeFalse())
+	Expect(IsDocument([]byte(`[{"log": {"entries": []}}]`))).To(BeFalse())
+}
+
+func (s *DocumentSuite) TestParseInvalid(t sweet.T) {
+	_, err := Parse([]byte(`{"log": []}`))
+	Expect(err).To(MatchError(ContainSubstring("failed to unmarshal document")))
+
+	_, err = Parse([]byte(`{}`))
+	Expect(err).To(MatchError("missing log"))
+}
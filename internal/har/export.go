@@ -0,0 +1,155 @@
+package har
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/efritz/derision/internal/request"
+)
+
+// Export creates a HAR document containing one entry for each request in
+// the given request log, along with the response that was sent for it.
+func Export(requests []*request.Request) *Document {
+	entries := []*Entry{}
+	for _, r := range requests {
+		entries = append(entries, exportEntry(r))
+	}
+
+	return &Document{
+		Log: &Log{
+			Version: "1.2",
+			Creator: &Creator{Name: "derision", Version: "1.0"},
+			Entries: entries,
+		},
+	}
+}
+
+func exportEntry(r *request.Request) *Entry {
+	resp := r.Response
+	if resp == nil {
+		resp = &request.Response{}
+	}
+
+	return &Entry{
+		StartedDateTime: r.Timestamp.Format(time.RFC3339Nano),
+		Time:            resp.ElapsedMs,
+		Request:         exportRequest(r),
+		Response:        exportResponse(resp),
+		Timings:         &Timings{Wait: resp.ElapsedMs},
+	}
+}
+
+// defaultHost is the host of an exported request URL if the request did not
+// record one. HAR requires an absolute URL.
+const defaultHost = "localhost"
+
+func exportRequest(r *request.Request) *Request {
+	host := r.Host
+	if host == "" {
+		host = defaultHost
+	}
+
+	u := &url.URL{
+		Scheme:   "http",
+		Host:     host,
+		Path:     r.Path,
+		RawQuery: url.Values(r.Query).Encode(),
+	}
+
+	var postData *PostData
+	if r.Body != "" {
+		postData = &PostData{
+			MimeType: first(r.Headers, "Content-Type"),
+			Text:     r.Body,
+		}
+	}
+
+	headers := r.Headers
+	if r.Encoding != "" {
+		headers = decodedHeaders(r.Headers)
+	}
+
+	return &Request{
+		Method:      r.Method,
+		URL:         u.String(),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []*Pair{},
+		Headers:     exportPairs(headers),
+		QueryString: exportPairs(r.Query),
+		PostData:    postData,
+		HeadersSize: -1,
+		BodySize:    len(r.Body),
+	}
+}
+
+func exportResponse(r *request.Response) *Response {
+	content := &Content{
+		Size:     len(r.Body),
+		MimeType: first(r.Headers, "Content-Type"),
+		Text:     r.Body,
+	}
+
+	// Bodies that are not valid text are base64-encoded, as permitted
+	// by the HAR specification.
+	if !utf8.ValidString(r.Body) {
+		content.Text = base64.StdEncoding.EncodeToString([]byte(r.Body))
+		content.Encoding = "base64"
+	}
+
+	return &Response{
+		Status:      r.StatusCode,
+		StatusText:  http.StatusText(r.StatusCode),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []*Pair{},
+		Headers:     exportPairs(r.Headers),
+		Content:     content,
+		HeadersSize: -1,
+		BodySize:    len(r.Body),
+	}
+}
+
+// decodedHeaders returns the given request headers without the headers that
+// describe the encoded body. The recorded body was decoded, so these headers
+// no longer apply to it.
+func decodedHeaders(headers map[string][]string) map[string][]string {
+	decoded := map[string][]string{}
+	for name, values := range headers {
+		if name != "Content-Encoding" && name != "Content-Length" {
+			decoded[name] = values
+		}
+	}
+
+	return decoded
+}
+
+// exportPairs flattens the given multi-valued map into a list of pairs
+// ordered by name.
+func exportPairs(values map[string][]string) []*Pair {
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	pairs := []*Pair{}
+	for _, name := range names {
+		for _, value := range values[name] {
+			pairs = append(pairs, &Pair{Name: name, Value: value})
+		}
+	}
+
+	return pairs
+}
+
+func first(headers map[string][]string, k string) string {
+	if vals, ok := headers[k]; ok && len(vals) > 0 {
+		return vals[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,145 @@
+package har
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/internal/request"
+	. "github.com/onsi/gomega"
+)
+
+type ExportSuite struct{}
+
+func (s *ExportSuite) TestExport(t sweet.T) {
+	document := Export([]*request.Request{
+		&request.Request{
+			Method:    "POST",
+			Host:      "localhost:5000",
+			Path:      "/users",
+			Query:     map[string][]string{"q": {"a b"}},
+			Headers:   map[string][]string{"Content-Type": {"application/json"}, "Accept": {"*/*"}},
+			Body:      `{"name": "foo"}`,
+			Timestamp: time.Date(2019, 4, 10, 22, 57, 14, 0, time.UTC),
+			Response: &request.Response{
+				StatusCode: 201,
+				Headers:    map[string][]string{"Content-Type": {"application/json"}},
+				Body:       `{"id": 1}`,
+				ElapsedMs:  1.5,
+			},
+		},
+	})
+
+	serialized, err := json.Marshal(document)
+	Expect(err).To(BeNil())
+	Expect(serialized).To(MatchJSON(`{
+		"log": {
+			"version": "1.2",
+			"creator": {"name": "derision", "version": "1.0"},
+			"entries": [
+				{
+					"startedDateTime": "2019-04-10T22:57:14Z",
+					"time": 1.5,
+					"request": {
+						"method": "POST",
+						"url": "http://localhost:5000/users?q=a+b",
+						"httpVersion": "HTTP/1.1",
+						"cookies": [],
+						"headers": [
+							{"name": "Accept", "value": "*/*"},
+							{"name": "Content-Type", "value": "application/json"}
+						],
+						"queryString": [{"name": "q", "value": "a b"}],
+						"postData": {"mimeType": "application/json", "text": "{\"name\": \"foo\"}"},
+						"headersSize": -1,
+						"bodySize": 15
+					},
+					"response": {
+						"status": 201,
+						"statusText": "Created",
+						"httpVersion": "HTTP/1.1",
+						"cookies": [],
+						"headers": [{"name": "Content-Type", "value": "application/json"}],
+						"content": {"size": 9, "mimeType": "application/json", "text": "{\"id\": 1}"},
+						"redirectURL": "",
+						"headersSize": -1,
+						"bodySize": 9
+					},
+					"cache": {},
+					"timings": {"send": 0, "wait": 1.5, "receive": 0}
+				}
+			]
+		}
+	}`))
+}
+
+func (s *ExportSuite) TestExportBinaryBody(t sweet.T) {
+	document := Export([]*request.Request{
+		&request.Request{
+			Method:   "GET",
+			Path:     "/image",
+			Response: &request.Response{StatusCode: 200, Body: "\xff\xd8\xff"},
+		},
+	})
+
+	Expect(document.Log.Entries).To(HaveLen(1))
+	Expect(document.Log.Entries[0].Request.PostData).To(BeNil())
+	Expect(document.Log.Entries[0].Response.Content.Text).To(Equal("/9j/"))
+	Expect(document.Log.Entries[0].Response.Content.Encoding).To(Equal("base64"))
+}
+
+func (s *ExportSuite) TestExportDecodedBody(t sweet.T) {
+	document := Export([]*request.Request{
+		&request.Request{
+			Method: "POST",
+			Path:   "/a",
+			Headers: map[string][]string{
+				"Content-Encoding": {"gzip"},
+				"Content-Length":   {"27"},
+				"Content-Type":     {"text/plain"},
+			},
+			Body:     "foo",
+			Encoding: "gzip",
+		},
+	})
+
+	Expect(document.Log.Entries).To(HaveLen(1))
+	Expect(document.Log.Entries[0].Request.Headers).To(Equal([]*Pair{&Pair{Name: "Content-Type", Value: "text/plain"}}))
+	Expect(document.Log.Entries[0].Request.PostData).To(Equal(&PostData{MimeType: "text/plain", Text: "foo"}))
+	Expect(document.Log.Entries[0].Request.BodySize).To(Equal(3))
+}
+
+func (s *ExportSuite) TestExportRoundTrip(t sweet.T) {
+	document := Export([]*request.Request{
+		&request.Request{
+			Method:   "GET",
+			Path:     "/a",
+			Response: &request.Response{StatusCode: 404, Body: "missing"},
+		},
+	})
+
+	serialized, err := json.Marshal(document)
+	Expect(err).To(BeNil())
+	Expect(IsDocument(serialized)).To(BeTrue())
+
+	handlers, err := Convert(serialized)
+	Expect(err).To(BeNil())
+	Expect(handlers).To(HaveLen(1))
+	Expect(handlers[0].Request.Path).To(Equal("^/a$"))
+	Expect(handlers[0].Response.StatusCode).To(Equal("404"))
+	Expect(handlers[0].Response.Body).To(Equal("missing"))
+}
+
+func (s *ExportSuite) TestExportWithoutHost(t sweet.T) {
+	document := Export([]*request.Request{
+		&request.Request{
+			Method:   "GET",
+			Path:     "/a",
+			Query:    map[string][]string{"x": []string{"1"}},
+			Response: &request.Response{StatusCode: 200},
+		},
+	})
+
+	Expect(document.Log.Entries).To(HaveLen(1))
+	Expect(document.Log.Entries[0].Request.URL).To(Equal("http://localhost/a?x=1"))
+}
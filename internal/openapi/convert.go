@@ -0,0 +1,383 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/efritz/derision/internal/payload"
+)
+
+type methodOperation struct {
+	method    string
+	operation *Operation
+}
+
+// Convert generates one handler payload for each operation defined in the
+// given OpenAPI 3 document. Operations are ordered so that concrete paths
+// are evaluated before templated paths that could also match them.
+func Convert(data []byte) ([]payload.Handler, error) {
+	document, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := document.basePath()
+
+	handlers := []payload.Handler{}
+	for _, path := range sortedPaths(document.Paths) {
+		item := document.Paths[path]
+
+		for _, op := range item.operations() {
+			handler, err := document.convertOperation(basePath+path, item, op)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert %s %s (%s)", op.method, path, err.Error())
+			}
+
+			handlers = append(handlers, handler)
+		}
+	}
+
+	return handlers, nil
+}
+
+func (d *Document) basePath() string {
+	if len(d.Servers) == 0 {
+		return ""
+	}
+
+	u, err := url.Parse(d.Servers[0].URL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+func (d *Document) convertOperation(path string, item *PathItem, op methodOperation) (payload.Handler, error) {
+	headers, err := d.requestHeaders(append(append([]*Parameter{}, item.Parameters...), op.operation.Parameters...))
+	if err != nil {
+		return payload.Handler{}, err
+	}
+
+	response, err := d.convertResponses(op.operation.Responses)
+	if err != nil {
+		return payload.Handler{}, err
+	}
+
+	handler := payload.Handler{
+		Request: payload.Request{
+			Method:  payload.Exact(op.method),
+			Path:    PathPattern(path),
+			Headers: headers,
+		},
+		Response: response,
+	}
+
+	return handler, nil
+}
+
+// requestHeaders returns a pattern for each required header parameter. A
+// header with an enumerated schema must match one of the values. Any other
+// required header must simply be present.
+func (d *Document) requestHeaders(parameters []*Parameter) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, parameter := range parameters {
+		parameter, err := d.parameter(parameter)
+		if err != nil {
+			return nil, err
+		}
+
+		if parameter == nil || parameter.In != "header" || !parameter.Required {
+			continue
+		}
+
+		schema, err := d.schema(parameter.Schema)
+		if err != nil {
+			return nil, err
+		}
+
+		pattern := ".+"
+		if schema != nil && len(schema.Enum) > 0 {
+			values := []string{}
+			for _, raw := range schema.Enum {
+				values = append(values, regexp.QuoteMeta(stringify(raw)))
+			}
+
+			pattern = fmt.Sprintf("^(?:%s)$", strings.Join(values, "|"))
+		}
+
+		headers[http.CanonicalHeaderKey(parameter.Name)] = pattern
+	}
+
+	return headers, nil
+}
+
+func (d *Document) convertResponses(responses map[string]*Response) (payload.Response, error) {
+	code, response := selectResponse(responses)
+
+	response, err := d.response(response)
+	if err != nil || response == nil {
+		return payload.Response{StatusCode: code}, err
+	}
+
+	headers := map[string][]string{}
+	for name, header := range response.Headers {
+		if http.CanonicalHeaderKey(name) == "Content-Type" {
+			continue
+		}
+
+		value, err := d.headerValue(header)
+		if err != nil {
+			return payload.Response{}, err
+		}
+
+		if value == "" {
+			continue
+		}
+
+		headers[name] = []string{payload.Literal(value)}
+	}
+
+	mediaType, content := selectMediaType(response.Content)
+	if content == nil {
+		return payload.Response{StatusCode: code, Headers: headers}, nil
+	}
+
+	body, err := d.exampleBody(mediaType, content)
+	if err != nil {
+		return payload.Response{}, err
+	}
+
+	headers["Content-Type"] = []string{payload.Literal(mediaType)}
+
+	return payload.Response{
+		StatusCode: code,
+		Headers:    headers,
+		Body:       payload.Literal(body),
+	}, nil
+}
+
+func (d *Document) headerValue(header *Header) (string, error) {
+	header, err := d.header(header)
+	if err != nil || header == nil {
+		return "", err
+	}
+
+	if len(header.Example) > 0 {
+		return stringify(header.Example), nil
+	}
+
+	if header.Schema == nil {
+		return "", nil
+	}
+
+	value, err := d.synthesize(header.Schema, nil)
+	if err != nil {
+		return "", err
+	}
+
+	serialized, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return stringify(serialized), nil
+}
+
+// exampleBody returns the first example defined for the given media type.
+// If the media type defines no examples, one is synthesized from its schema.
+func (d *Document) exampleBody(mediaType string, content *MediaType) (string, error) {
+	raw := content.Example
+
+	if len(raw) == 0 && len(content.Examples) > 0 {
+		names := []string{}
+		for name := range content.Examples {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		example, err := d.example(content.Examples[names[0]])
+		if err != nil {
+			return "", err
+		}
+
+		if example != nil {
+			raw = example.Value
+		}
+	}
+
+	if len(raw) == 0 {
+		if content.Schema == nil {
+			return "", nil
+		}
+
+		value, err := d.synthesize(content.Schema, nil)
+		if err != nil {
+			return "", err
+		}
+
+		if raw, err = json.Marshal(value); err != nil {
+			return "", err
+		}
+	}
+
+	if !isJSONMediaType(mediaType) {
+		return stringify(raw), nil
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := json.Compact(buffer, raw); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
+// PathPattern converts an OpenAPI path template into a regular expression
+// that matches the entire path. Each templated segment becomes a capture
+// group.
+func PathPattern(template string) string {
+	pattern := "^"
+	for len(template) > 0 {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			pattern += regexp.QuoteMeta(template)
+			break
+		}
+
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			pattern += regexp.QuoteMeta(template)
+			break
+		}
+
+		pattern += regexp.QuoteMeta(template[:start]) + "([^/]+)"
+		template = template[start+end+1:]
+	}
+
+	return pattern + "$"
+}
+
+func (i *PathItem) operations() []methodOperation {
+	operations := []methodOperation{}
+	for _, op := range []methodOperation{
+		{"GET", i.Get},
+		{"PUT", i.Put},
+		{"POST", i.Post},
+		{"DELETE", i.Delete},
+		{"OPTIONS", i.Options},
+		{"HEAD", i.Head},
+		{"PATCH", i.Patch},
+		{"TRACE", i.Trace},
+	} {
+		if op.operation != nil {
+			operations = append(operations, op)
+		}
+	}
+
+	return operations
+}
+
+// sortedPaths orders paths by the number of templated segments, then
+// alphabetically. This ensures that /users/me precedes /users/{id}.
+func sortedPaths(paths map[string]*PathItem) []string {
+	sorted := []string{}
+	for path, item := range paths {
+		if item != nil {
+			sorted = append(sorted, path)
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if ci, cj := strings.Count(sorted[i], "{"), strings.Count(sorted[j], "{"); ci != cj {
+			return ci < cj
+		}
+
+		return sorted[i] < sorted[j]
+	})
+
+	return sorted
+}
+
+// selectResponse chooses the response with the lowest successful status
+// code. If there is no successful response, the default response is used.
+// Otherwise, the response with the lowest status code is used.
+func selectResponse(responses map[string]*Response) (string, *Response) {
+	codes := []string{}
+	for code := range responses {
+		codes = append(codes, code)
+	}
+
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			return statusCode(code), responses[code]
+		}
+	}
+
+	if response, ok := responses["default"]; ok {
+		return "200", response
+	}
+
+	if len(codes) > 0 {
+		return statusCode(codes[0]), responses[codes[0]]
+	}
+
+	return "200", nil
+}
+
+// statusCode converts a status code range (e.g. 2XX) into a concrete
+// status code.
+func statusCode(code string) string {
+	if len(code) != 3 {
+		return "200"
+	}
+
+	return strings.NewReplacer("X", "0", "x", "0").Replace(code)
+}
+
+// selectMediaType chooses the JSON media type, if one exists. Otherwise the
+// first media type (alphabetically) is chosen.
+func selectMediaType(content map[string]*MediaType) (string, *MediaType) {
+	mediaTypes := []string{}
+	for mediaType := range content {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+
+	sort.Strings(mediaTypes)
+
+	for _, mediaType := range mediaTypes {
+		if isJSONMediaType(mediaType) {
+			return mediaType, content[mediaType]
+		}
+	}
+
+	if len(mediaTypes) > 0 {
+		return mediaTypes[0], content[mediaTypes[0]]
+	}
+
+	return "", nil
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// stringify returns the value of a JSON string, or the raw JSON text of
+// any other value.
+func stringify(raw []byte) string {
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value
+	}
+
+	return string(raw)
+}
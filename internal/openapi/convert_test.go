@@ -0,0 +1,208 @@
+package openapi
+
+import (
+	"regexp"
+
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/internal/payload"
+	. "github.com/onsi/gomega"
+)
+
+type ConvertSuite struct{}
+
+func (s *ConvertSuite) TestConvert(t sweet.T) {
+	handlers, err := Convert([]byte(`{
+		"openapi": "3.0.0",
+		"servers": [{"url": "https://api.example.com/v1/"}],
+		"paths": {
+			"/users/{id}": {
+				"parameters": [
+					{"$ref": "#/components/parameters/Tenant"}
+				],
+				"get": {
+					"parameters": [
+						{"name": "id", "in": "path", "required": true},
+						{"name": "x-trace", "in": "header", "required": false}
+					],
+					"responses": {
+						"404": {"description": "missing"},
+						"200": {"$ref": "#/components/responses/User"}
+					}
+				},
+				"delete": {
+					"responses": {
+						"204": {"description": "deleted"}
+					}
+				}
+			},
+			"/users/me": {
+				"get": {
+					"responses": {
+						"2XX": {
+							"description": "me",
+							"content": {
+								"text/plain": {"example": "{{me}}"}
+							}
+						}
+					}
+				}
+			}
+		},
+		"components": {
+			"parameters": {
+				"Tenant": {
+					"name": "x-tenant",
+					"in": "header",
+					"required": true,
+					"schema": {"type": "string", "enum": ["a.b", "c"]}
+				}
+			},
+			"responses": {
+				"User": {
+					"description": "user",
+					"headers": {
+						"X-Rate-Limit": {"schema": {"type": "integer", "minimum": 100}},
+						"X-Empty": {}
+					},
+					"content": {
+						"application/xml": {"example": "<user />"},
+						"application/json": {
+							"examples": {
+								"b": {"value": {"id": "b"}},
+								"a": {"$ref": "#/components/examples/User"}
+							}
+						}
+					}
+				}
+			},
+			"examples": {
+				"User": {"value": {"id": "a", "name": "Alice"}}
+			}
+		}
+	}`))
+
+	Expect(err).To(BeNil())
+	Expect(handlers).To(Equal([]payload.Handler{
+		{
+			Request: payload.Request{
+				Method:  "^GET$",
+				Path:    "^/v1/users/me$",
+				Headers: map[string]string{},
+			},
+			Response: payload.Response{
+				StatusCode: "200",
+				Headers:    map[string][]string{"Content-Type": []string{"text/plain"}},
+				Body:       `{{"{{me}}"}}`,
+			},
+		},
+		{
+			Request: payload.Request{
+				Method: "^GET$",
+				Path:   "^/v1/users/([^/]+)$",
+				Headers: map[string]string{
+					"X-Tenant": `^(?:a\.b|c)$`,
+				},
+			},
+			Response: payload.Response{
+				StatusCode: "200",
+				Headers: map[string][]string{
+					"Content-Type": []string{"application/json"},
+					"X-Rate-Limit": []string{"100"},
+				},
+				Body: `{"id":"a","name":"Alice"}`,
+			},
+		},
+		{
+			Request: payload.Request{
+				Method: "^DELETE$",
+				Path:   "^/v1/users/([^/]+)$",
+				Headers: map[string]string{
+					"X-Tenant": `^(?:a\.b|c)$`,
+				},
+			},
+			Response: payload.Response{
+				StatusCode: "204",
+				Headers:    map[string][]string{},
+			},
+		},
+	}))
+}
+
+func (s *ConvertSuite) TestConvertSynthesizedBody(t sweet.T) {
+	handlers, err := Convert([]byte(`{
+		"openapi": "3.0.0",
+		"paths": {
+			"/pets": {
+				"post": {
+					"responses": {
+						"201": {
+							"content": {
+								"application/json": {
+									"schema": {
+										"type": "object",
+										"properties": {
+											"name": {"type": "string"},
+											"born": {"type": "string", "format": "date"}
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}`))
+
+	Expect(err).To(BeNil())
+	Expect(handlers).To(HaveLen(1))
+	Expect(handlers[0].Response.StatusCode).To(Equal("201"))
+	Expect(handlers[0].Response.Body).To(MatchJSON(`{"name": "string", "born": "1970-01-01"}`))
+}
+
+func (s *ConvertSuite) TestConvertDefaultResponse(t sweet.T) {
+	handlers, err := Convert([]byte(`{
+		"openapi": "3.0.0",
+		"paths": {
+			"/pets": {
+				"get": {"responses": {"default": {}, "500": {}}}
+			},
+			"/cats": {
+				"get": {"responses": {"503": {}, "500": {}}}
+			},
+			"/dogs": {
+				"get": {}
+			}
+		}
+	}`))
+
+	Expect(err).To(BeNil())
+	Expect(handlers).To(HaveLen(3))
+	Expect(handlers[0].Response.StatusCode).To(Equal("500"))
+	Expect(handlers[1].Response.StatusCode).To(Equal("200"))
+	Expect(handlers[2].Response.StatusCode).To(Equal("200"))
+}
+
+func (s *ConvertSuite) TestConvertBadReference(t sweet.T) {
+	_, err := Convert([]byte(`{
+		"openapi": "3.0.0",
+		"paths": {
+			"/pets": {
+				"get": {"responses": {"200": {"$ref": "#/components/responses/Missing"}}}
+			}
+		}
+	}`))
+
+	Expect(err).To(MatchError("failed to convert GET /pets (unresolved reference Missing)"))
+}
+
+func (s *ConvertSuite) TestPathPattern(t sweet.T) {
+	Expect(PathPattern("/users")).To(Equal("^/users$"))
+	Expect(PathPattern("/users/{id}.json")).To(Equal(`^/users/([^/]+)\.json$`))
+	Expect(PathPattern("/a/{b}/c/{d}")).To(Equal("^/a/([^/]+)/c/([^/]+)$"))
+	Expect(PathPattern("/a/{b")).To(Equal("^/a/\\{b$"))
+
+	re := regexp.MustCompile(PathPattern("/users/{id}/posts/{post}"))
+	Expect(re.FindStringSubmatch("/users/1/posts/2")).To(Equal([]string{"/users/1/posts/2", "1", "2"}))
+	Expect(re.MatchString("/users/1/2/posts/3")).To(BeFalse())
+}
@@ -0,0 +1,253 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type (
+	Document struct {
+		OpenAPI    string               `json:"openapi"`
+		Servers    []*Server            `json:"servers"`
+		Paths      map[string]*PathItem `json:"paths"`
+		Components *Components          `json:"components"`
+	}
+
+	Server struct {
+		URL string `json:"url"`
+	}
+
+	PathItem struct {
+		Parameters []*Parameter `json:"parameters"`
+		Get        *Operation   `json:"get"`
+		Put        *Operation   `json:"put"`
+		Post       *Operation   `json:"post"`
+		Delete     *Operation   `json:"delete"`
+		Options    *Operation   `json:"options"`
+		Head       *Operation   `json:"head"`
+		Patch      *Operation   `json:"patch"`
+		Trace      *Operation   `json:"trace"`
+	}
+
+	Operation struct {
+		Parameters  []*Parameter         `json:"parameters"`
+		RequestBody *RequestBody         `json:"requestBody"`
+		Responses   map[string]*Response `json:"responses"`
+	}
+
+	Parameter struct {
+		Ref      string  `json:"$ref"`
+		Name     string  `json:"name"`
+		In       string  `json:"in"`
+		Required bool    `json:"required"`
+		Schema   *Schema `json:"schema"`
+	}
+
+	RequestBody struct {
+		Ref      string                `json:"$ref"`
+		Required bool                  `json:"required"`
+		Content  map[string]*MediaType `json:"content"`
+	}
+
+	Response struct {
+		Ref     string                `json:"$ref"`
+		Headers map[string]*Header    `json:"headers"`
+		Content map[string]*MediaType `json:"content"`
+	}
+
+	Header struct {
+		Ref     string          `json:"$ref"`
+		Schema  *Schema         `json:"schema"`
+		Example json.RawMessage `json:"example"`
+	}
+
+	MediaType struct {
+		Schema   *Schema             `json:"schema"`
+		Example  json.RawMessage     `json:"example"`
+		Examples map[string]*Example `json:"examples"`
+	}
+
+	Example struct {
+		Ref   string          `json:"$ref"`
+		Value json.RawMessage `json:"value"`
+	}
+
+	Schema struct {
+		Ref        string             `json:"$ref"`
+		Type       string             `json:"type"`
+		Format     string             `json:"format"`
+		Enum       []json.RawMessage  `json:"enum"`
+		Default    json.RawMessage    `json:"default"`
+		Example    json.RawMessage    `json:"example"`
+		Minimum    *float64           `json:"minimum"`
+		Properties map[string]*Schema `json:"properties"`
+		Items      *Schema            `json:"items"`
+		AllOf      []*Schema          `json:"allOf"`
+		OneOf      []*Schema          `json:"oneOf"`
+		AnyOf      []*Schema          `json:"anyOf"`
+		raw        json.RawMessage
+	}
+
+	Components struct {
+		Schemas    map[string]*Schema      `json:"schemas"`
+		Responses  map[string]*Response    `json:"responses"`
+		Parameters map[string]*Parameter   `json:"parameters"`
+		Examples   map[string]*Example     `json:"examples"`
+		Headers    map[string]*Header      `json:"headers"`
+		Bodies     map[string]*RequestBody `json:"requestBodies"`
+	}
+)
+
+// IsDocument determines if the given JSON data looks like an OpenAPI 3
+// document (an object with a 3.x openapi version field).
+func IsDocument(data []byte) bool {
+	document := &struct {
+		OpenAPI string `json:"openapi"`
+	}{}
+
+	if err := json.Unmarshal(data, document); err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(document.OpenAPI, "3.")
+}
+
+// Parse unmarshals an OpenAPI 3 document from JSON data.
+func Parse(data []byte) (*Document, error) {
+	document := &Document{}
+	if err := json.Unmarshal(data, document); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal document (%s)", err.Error())
+	}
+
+	if !strings.HasPrefix(document.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q", document.OpenAPI)
+	}
+
+	if document.Components == nil {
+		document.Components = &Components{}
+	}
+
+	return document, nil
+}
+
+// UnmarshalJSON decodes the schema and retains its original JSON so that
+// it can later be compiled for validation.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type plain Schema
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+
+	s.raw = append(json.RawMessage{}, data...)
+	return nil
+}
+
+func (d *Document) parameter(p *Parameter) (*Parameter, error) {
+	for i := 0; p != nil && p.Ref != ""; i++ {
+		name, err := refName(p.Ref, "parameters", i)
+		if err != nil {
+			return nil, err
+		}
+
+		if p = d.Components.Parameters[name]; p == nil {
+			return nil, fmt.Errorf("unresolved reference %s", name)
+		}
+	}
+
+	return p, nil
+}
+
+func (d *Document) requestBody(b *RequestBody) (*RequestBody, error) {
+	for i := 0; b != nil && b.Ref != ""; i++ {
+		name, err := refName(b.Ref, "requestBodies", i)
+		if err != nil {
+			return nil, err
+		}
+
+		if b = d.Components.Bodies[name]; b == nil {
+			return nil, fmt.Errorf("unresolved reference %s", name)
+		}
+	}
+
+	return b, nil
+}
+
+func (d *Document) response(r *Response) (*Response, error) {
+	for i := 0; r != nil && r.Ref != ""; i++ {
+		name, err := refName(r.Ref, "responses", i)
+		if err != nil {
+			return nil, err
+		}
+
+		if r = d.Components.Responses[name]; r == nil {
+			return nil, fmt.Errorf("unresolved reference %s", name)
+		}
+	}
+
+	return r, nil
+}
+
+func (d *Document) header(h *Header) (*Header, error) {
+	for i := 0; h != nil && h.Ref != ""; i++ {
+		name, err := refName(h.Ref, "headers", i)
+		if err != nil {
+			return nil, err
+		}
+
+		if h = d.Components.Headers[name]; h == nil {
+			return nil, fmt.Errorf("unresolved reference %s", name)
+		}
+	}
+
+	return h, nil
+}
+
+func (d *Document) example(e *Example) (*Example, error) {
+	for i := 0; e != nil && e.Ref != ""; i++ {
+		name, err := refName(e.Ref, "examples", i)
+		if err != nil {
+			return nil, err
+		}
+
+		if e = d.Components.Examples[name]; e == nil {
+			return nil, fmt.Errorf("unresolved reference %s", name)
+		}
+	}
+
+	return e, nil
+}
+
+func (d *Document) schema(s *Schema) (*Schema, error) {
+	for i := 0; s != nil && s.Ref != ""; i++ {
+		name, err := refName(s.Ref, "schemas", i)
+		if err != nil {
+			return nil, err
+		}
+
+		if s = d.Components.Schemas[name]; s == nil {
+			return nil, fmt.Errorf("unresolved reference %s", name)
+		}
+	}
+
+	return s, nil
+}
+
+const maxRefDepth = 32
+
+// refName returns the name of a local component reference of the given
+// kind (e.g. #/components/schemas/User). External references are not
+// supported. The depth is the number of references followed so far and
+// is used to detect reference cycles.
+func refName(ref, kind string, depth int) (string, error) {
+	if depth > maxRefDepth {
+		return "", fmt.Errorf("circular reference %s", ref)
+	}
+
+	prefix := fmt.Sprintf("#/components/%s/", kind)
+	if !strings.HasPrefix(ref, prefix) {
+		return "", fmt.Errorf("unsupported reference %s", ref)
+	}
+
+	return strings.Replace(strings.Replace(strings.TrimPrefix(ref, prefix), "~1", "/", -1), "~0", "~", -1), nil
+}
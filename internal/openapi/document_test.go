@@ -0,0 +1,47 @@
+package openapi
+
+import (
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type DocumentSuite struct{}
+
+func (s *DocumentSuite) TestIsDocument(t sweet.T) {
+	Expect(IsDocument([]byte(`{"openapi": "3.0.2", "paths": {}}`))).To(BeTrue())
+	Expect(IsDocument([]byte(`{"swagger": "2.0", "paths": {}}`))).To(BeFalse())
+	Expect(IsDocument([]byte(`[{"request": {}, "response": {}}]`))).To(BeFalse())
+}
+
+func (s *DocumentSuite) TestParseUnsupportedVersion(t sweet.T) {
+	_, err := Parse([]byte(`{"openapi": "2.0"}`))
+	Expect(err).To(MatchError(`unsupported OpenAPI version "2.0"`))
+}
+
+func (s *DocumentSuite) TestResolveReferences(t sweet.T) {
+	document, err := Parse([]byte(`{
+		"openapi": "3.0.0",
+		"components": {
+			"schemas": {
+				"A": {"$ref": "#/components/schemas/B"},
+				"B": {"type": "string"},
+				"C": {"$ref": "#/components/schemas/C"}
+			}
+		}
+	}`))
+
+	Expect(err).To(BeNil())
+
+	schema, err := document.schema(&Schema{Ref: "#/components/schemas/A"})
+	Expect(err).To(BeNil())
+	Expect(schema.Type).To(Equal("string"))
+
+	_, err = document.schema(&Schema{Ref: "#/components/schemas/C"})
+	Expect(err).To(MatchError("circular reference #/components/schemas/C"))
+
+	_, err = document.schema(&Schema{Ref: "#/components/schemas/D"})
+	Expect(err).To(MatchError("unresolved reference D"))
+
+	_, err = document.schema(&Schema{Ref: "other.yaml#/User"})
+	Expect(err).To(MatchError("unsupported reference other.yaml#/User"))
+}
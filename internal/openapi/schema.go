@@ -0,0 +1,160 @@
+package openapi
+
+import "encoding/json"
+
+var stringFormats = map[string]string{
+	"date":      "1970-01-01",
+	"date-time": "1970-01-01T00:00:00Z",
+	"uuid":      "00000000-0000-0000-0000-000000000000",
+	"email":     "user@example.com",
+	"uri":       "https://example.com",
+	"hostname":  "example.com",
+	"ipv4":      "127.0.0.1",
+	"ipv6":      "::1",
+	"byte":      "c3RyaW5n",
+}
+
+// synthesize generates a value that conforms to the given schema. Explicit
+// examples, defaults, and enum values are preferred over generated values.
+// The refs parameter holds the schema references currently being expanded
+// so that a recursive schema generates a null value instead of recursing.
+func (d *Document) synthesize(s *Schema, refs []string) (interface{}, error) {
+	if s != nil && s.Ref != "" {
+		for _, ref := range refs {
+			if ref == s.Ref {
+				return nil, nil
+			}
+		}
+
+		refs = append(append([]string{}, refs...), s.Ref)
+	}
+
+	s, err := d.schema(s)
+	if err != nil || s == nil {
+		return nil, err
+	}
+
+	for _, raw := range []json.RawMessage{s.Example, s.Default} {
+		if len(raw) > 0 {
+			return decode(raw)
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		return decode(s.Enum[0])
+	}
+
+	if len(s.AllOf) > 0 {
+		return d.synthesizeAllOf(s.AllOf, refs)
+	}
+
+	for _, alternatives := range [][]*Schema{s.OneOf, s.AnyOf} {
+		if len(alternatives) > 0 {
+			return d.synthesize(alternatives[0], refs)
+		}
+	}
+
+	switch s.Type {
+	case "object":
+		return d.synthesizeObject(s, refs)
+
+	case "array":
+		return d.synthesizeArray(s, refs)
+
+	case "string":
+		if value, ok := stringFormats[s.Format]; ok {
+			return value, nil
+		}
+
+		return "string", nil
+
+	case "integer":
+		if s.Minimum != nil {
+			return int64(*s.Minimum), nil
+		}
+
+		return 0, nil
+
+	case "number":
+		if s.Minimum != nil {
+			return *s.Minimum, nil
+		}
+
+		return 0, nil
+
+	case "boolean":
+		return true, nil
+	}
+
+	if s.Properties != nil {
+		return d.synthesizeObject(s, refs)
+	}
+
+	return nil, nil
+}
+
+func (d *Document) synthesizeObject(s *Schema, refs []string) (interface{}, error) {
+	object := map[string]interface{}{}
+	for name, property := range s.Properties {
+		value, err := d.synthesize(property, refs)
+		if err != nil {
+			return nil, err
+		}
+
+		object[name] = value
+	}
+
+	return object, nil
+}
+
+func (d *Document) synthesizeArray(s *Schema, refs []string) (interface{}, error) {
+	if s.Items == nil {
+		return []interface{}{}, nil
+	}
+
+	value, err := d.synthesize(s.Items, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{value}, nil
+}
+
+// synthesizeAllOf merges the synthesized values of each schema. If any
+// value is not an object, the last value is returned instead.
+func (d *Document) synthesizeAllOf(schemas []*Schema, refs []string) (interface{}, error) {
+	var last interface{}
+	merged := map[string]interface{}{}
+
+	for _, schema := range schemas {
+		value, err := d.synthesize(schema, refs)
+		if err != nil {
+			return nil, err
+		}
+
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			last = value
+			continue
+		}
+
+		for k, v := range object {
+			merged[k] = v
+		}
+	}
+
+	if last != nil {
+		return last, nil
+	}
+
+	return merged, nil
+}
+
+func decode(raw json.RawMessage) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,85 @@
+package openapi
+
+import (
+	"encoding/json"
+
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type SchemaSuite struct{}
+
+func (s *SchemaSuite) TestSynthesize(t sweet.T) {
+	document, err := Parse([]byte(`{
+		"openapi": "3.0.0",
+		"components": {
+			"schemas": {
+				"User": {
+					"type": "object",
+					"properties": {
+						"id": {"type": "string", "format": "uuid"},
+						"age": {"type": "integer", "minimum": 18},
+						"admin": {"type": "boolean"},
+						"role": {"type": "string", "enum": ["owner", "member"]},
+						"tags": {"type": "array", "items": {"type": "string", "example": "a"}},
+						"manager": {"$ref": "#/components/schemas/User"}
+					}
+				}
+			}
+		}
+	}`))
+
+	Expect(err).To(BeNil())
+
+	value, err := document.synthesize(&Schema{Ref: "#/components/schemas/User"}, nil)
+	Expect(err).To(BeNil())
+
+	serialized, err := json.Marshal(value)
+	Expect(err).To(BeNil())
+	Expect(serialized).To(MatchJSON(`{
+		"id": "00000000-0000-0000-0000-000000000000",
+		"age": 18,
+		"admin": true,
+		"role": "owner",
+		"tags": ["a"],
+		"manager": null
+	}`))
+}
+
+func (s *SchemaSuite) TestSynthesizeComposition(t sweet.T) {
+	document, err := Parse([]byte(`{"openapi": "3.0.0"}`))
+	Expect(err).To(BeNil())
+
+	value, err := document.synthesize(&Schema{
+		AllOf: []*Schema{
+			&Schema{Properties: map[string]*Schema{"a": &Schema{Type: "integer"}}},
+			&Schema{Properties: map[string]*Schema{"b": &Schema{Type: "number"}}},
+		},
+	}, nil)
+
+	Expect(err).To(BeNil())
+	Expect(value).To(Equal(map[string]interface{}{"a": 0, "b": 0}))
+
+	value, err = document.synthesize(&Schema{
+		OneOf: []*Schema{
+			&Schema{Type: "string", Format: "date-time"},
+			&Schema{Type: "integer"},
+		},
+	}, nil)
+
+	Expect(err).To(BeNil())
+	Expect(value).To(Equal("1970-01-01T00:00:00Z"))
+}
+
+func (s *SchemaSuite) TestSynthesizeExample(t sweet.T) {
+	document, err := Parse([]byte(`{"openapi": "3.0.0"}`))
+	Expect(err).To(BeNil())
+
+	value, err := document.synthesize(&Schema{
+		Type:    "object",
+		Example: json.RawMessage(`{"x": [1, 2, 3]}`),
+	}, nil)
+
+	Expect(err).To(BeNil())
+	Expect(value).To(Equal(map[string]interface{}{"x": []interface{}{1.0, 2.0, 3.0}}))
+}
@@ -0,0 +1,422 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/efritz/derision/internal/request"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+type (
+	// Validator checks requests against the operations of an OpenAPI 3
+	// document.
+	Validator struct {
+		operations []*validatedOperation
+		prefixes   map[string][]int
+	}
+
+	validatedOperation struct {
+		method     string
+		template   string
+		pattern    *regexp.Regexp
+		names      []string
+		parameters []*validatedParameter
+		body       *validatedBody
+	}
+
+	validatedParameter struct {
+		name       string
+		in         string
+		required   bool
+		schemaType string
+		itemsType  string
+		schema     *gojsonschema.Schema
+	}
+
+	validatedBody struct {
+		required bool
+		content  map[string]*gojsonschema.Schema
+	}
+)
+
+// NewValidator creates a validator from the given OpenAPI 3 document (JSON).
+// All schemas are compiled eagerly so that an invalid document is rejected
+// on load rather than on the first request.
+func NewValidator(data []byte) (*Validator, error) {
+	document, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := document.basePath()
+
+	operations := []*validatedOperation{}
+	prefixes := map[string][]int{}
+
+	for _, path := range sortedPaths(document.Paths) {
+		item := document.Paths[path]
+
+		for _, op := range item.operations() {
+			operation, err := document.compileOperation(basePath+path, item, op)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile %s %s (%s)", op.method, path, err.Error())
+			}
+
+			prefix := literalPrefix(operation.template)
+			prefixes[prefix] = append(prefixes[prefix], len(operations))
+			operations = append(operations, operation)
+		}
+	}
+
+	return &Validator{operations: operations, prefixes: prefixes}, nil
+}
+
+// Validate returns a description of each way in which the request does not
+// conform to the document. An empty list is returned for a valid request.
+func (v *Validator) Validate(r *request.Request) []string {
+	pathMatched := false
+	for _, operation := range v.candidates(r.Path) {
+		groups := operation.pattern.FindStringSubmatch(r.Path)
+		if groups == nil {
+			continue
+		}
+
+		pathMatched = true
+
+		if operation.method == r.Method {
+			return operation.validate(r, groups[1:])
+		}
+	}
+
+	if pathMatched {
+		return []string{fmt.Sprintf("method %s is not allowed for path %s", r.Method, r.Path)}
+	}
+
+	return []string{fmt.Sprintf("no operation matches path %s", r.Path)}
+}
+
+// candidates returns the operations whose path template has a literal prefix
+// (the text before the first parameter) with which the given path begins, in
+// document order. No other operation can match the path.
+func (v *Validator) candidates(path string) []*validatedOperation {
+	ranks := []int{}
+	for i := 0; i <= len(path); i++ {
+		ranks = append(ranks, v.prefixes[path[:i]]...)
+	}
+
+	sort.Ints(ranks)
+
+	operations := make([]*validatedOperation, 0, len(ranks))
+	for _, rank := range ranks {
+		operations = append(operations, v.operations[rank])
+	}
+
+	return operations
+}
+
+func literalPrefix(template string) string {
+	if index := strings.Index(template, "{"); index >= 0 {
+		return template[:index]
+	}
+
+	return template
+}
+
+func (o *validatedOperation) validate(r *request.Request, groups []string) []string {
+	pathValues := map[string]string{}
+	for i, name := range o.names {
+		if i < len(groups) {
+			pathValues[name] = groups[i]
+		}
+	}
+
+	violations := []string{}
+	for _, parameter := range o.parameters {
+		var values []string
+		switch parameter.in {
+		case "path":
+			if value, ok := pathValues[parameter.name]; ok {
+				values = []string{value}
+			}
+
+		case "query":
+			values = r.Query[parameter.name]
+
+		case "header":
+			values = r.Headers[http.CanonicalHeaderKey(parameter.name)]
+		}
+
+		violations = append(violations, parameter.validate(values)...)
+	}
+
+	if o.body != nil {
+		violations = append(violations, o.body.validate(r)...)
+	}
+
+	return violations
+}
+
+func (p *validatedParameter) validate(values []string) []string {
+	if len(values) == 0 {
+		if p.required {
+			return []string{fmt.Sprintf("missing required %s parameter %s", p.in, p.name)}
+		}
+
+		return nil
+	}
+
+	if p.schema == nil {
+		return nil
+	}
+
+	var value interface{} = coerce(values[0], p.schemaType)
+	if p.schemaType == "array" {
+		items := []interface{}{}
+		for _, value := range values {
+			for _, item := range strings.Split(value, ",") {
+				items = append(items, coerce(item, p.itemsType))
+			}
+		}
+
+		value = items
+	}
+
+	return describe(fmt.Sprintf("%s parameter %s", p.in, p.name), p.schema, value)
+}
+
+func (b *validatedBody) validate(r *request.Request) []string {
+	if r.Body == "" {
+		if b.required {
+			return []string{"missing required request body"}
+		}
+
+		return nil
+	}
+
+	contentType, _, _ := mime.ParseMediaType(getFirst(r.Headers, "Content-Type"))
+
+	schema, ok := selectSchema(b.content, contentType)
+	if !ok {
+		return []string{fmt.Sprintf("unsupported content type %q", contentType)}
+	}
+
+	if schema == nil || !isJSONMediaType(contentType) {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(r.Body), &value); err != nil {
+		return []string{"request body is not valid JSON"}
+	}
+
+	return describe("request body", schema, value)
+}
+
+func (d *Document) compileOperation(path string, item *PathItem, op methodOperation) (*validatedOperation, error) {
+	parameters, err := d.compileParameters(append(append([]*Parameter{}, item.Parameters...), op.operation.Parameters...))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := d.compileBody(op.operation.RequestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return &validatedOperation{
+		method:     op.method,
+		template:   path,
+		pattern:    regexp.MustCompile(PathPattern(path)),
+		names:      pathParameterNames(path),
+		parameters: parameters,
+		body:       body,
+	}, nil
+}
+
+// compileParameters compiles the schema of each parameter. Parameters
+// defined later (on the operation) override parameters of the same name
+// and location defined earlier (on the path item).
+func (d *Document) compileParameters(parameters []*Parameter) ([]*validatedParameter, error) {
+	indexes := map[string]int{}
+	compiled := []*validatedParameter{}
+
+	for _, parameter := range parameters {
+		parameter, err := d.parameter(parameter)
+		if err != nil {
+			return nil, err
+		}
+
+		if parameter == nil || parameter.In == "cookie" {
+			continue
+		}
+
+		schema, err := d.schema(parameter.Schema)
+		if err != nil {
+			return nil, err
+		}
+
+		validated := &validatedParameter{
+			name:     parameter.Name,
+			in:       parameter.In,
+			required: parameter.Required || parameter.In == "path",
+		}
+
+		if schema != nil {
+			validated.schemaType = schema.Type
+
+			items, err := d.schema(schema.Items)
+			if err != nil {
+				return nil, err
+			}
+
+			if items != nil {
+				validated.itemsType = items.Type
+			}
+
+			if validated.schema, err = d.compileSchema(parameter.Schema); err != nil {
+				return nil, err
+			}
+		}
+
+		key := parameter.In + ":" + parameter.Name
+		if index, ok := indexes[key]; ok {
+			compiled[index] = validated
+			continue
+		}
+
+		indexes[key] = len(compiled)
+		compiled = append(compiled, validated)
+	}
+
+	return compiled, nil
+}
+
+func (d *Document) compileBody(body *RequestBody) (*validatedBody, error) {
+	body, err := d.requestBody(body)
+	if err != nil || body == nil {
+		return nil, err
+	}
+
+	content := map[string]*gojsonschema.Schema{}
+	for mediaType, value := range body.Content {
+		var schema *gojsonschema.Schema
+		if value != nil && value.Schema != nil {
+			if schema, err = d.compileSchema(value.Schema); err != nil {
+				return nil, err
+			}
+		}
+
+		content[mediaType] = schema
+	}
+
+	return &validatedBody{
+		required: body.Required,
+		content:  content,
+	}, nil
+}
+
+// compileSchema compiles the given schema into a JSON schema validator.
+// The document's component schemas are embedded into the root of the
+// compiled schema so that local references resolve as expected.
+func (d *Document) compileSchema(s *Schema) (*gojsonschema.Schema, error) {
+	root := map[string]interface{}{}
+	if err := json.Unmarshal(s.raw, &root); err != nil {
+		return nil, err
+	}
+
+	schemas := map[string]json.RawMessage{}
+	for name, schema := range d.Components.Schemas {
+		if schema != nil {
+			schemas[name] = schema.raw
+		}
+	}
+
+	root["components"] = map[string]interface{}{"schemas": schemas}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(root))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema (%s)", err.Error())
+	}
+
+	return schema, nil
+}
+
+// selectSchema returns the schema for the content entry that matches the
+// given content type, either exactly or by a wildcard range.
+func selectSchema(content map[string]*gojsonschema.Schema, contentType string) (*gojsonschema.Schema, bool) {
+	candidates := []string{contentType, "*/*"}
+	if index := strings.Index(contentType, "/"); index >= 0 {
+		candidates = []string{contentType, contentType[:index] + "/*", "*/*"}
+	}
+
+	for _, candidate := range candidates {
+		if schema, ok := content[candidate]; ok {
+			return schema, true
+		}
+	}
+
+	return nil, false
+}
+
+func describe(subject string, schema *gojsonschema.Schema, value interface{}) []string {
+	result, err := schema.Validate(gojsonschema.NewGoLoader(value))
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %s", subject, err.Error())}
+	}
+
+	violations := []string{}
+	for _, err := range result.Errors() {
+		if field := err.Field(); field != "(root)" {
+			violations = append(violations, fmt.Sprintf("%s: %s: %s", subject, field, err.Description()))
+		} else {
+			violations = append(violations, fmt.Sprintf("%s: %s", subject, err.Description()))
+		}
+	}
+
+	return violations
+}
+
+// coerce converts a parameter value into the type declared by its schema
+// so that it can be validated. Values that cannot be converted are left
+// as strings, which causes the validator to report a type mismatch.
+func coerce(value, schemaType string) interface{} {
+	switch schemaType {
+	case "integer", "number":
+		if val, err := strconv.ParseFloat(value, 64); err == nil {
+			return val
+		}
+
+	case "boolean":
+		if val, err := strconv.ParseBool(value); err == nil {
+			return val
+		}
+	}
+
+	return value
+}
+
+func pathParameterNames(template string) []string {
+	names := []string{}
+	for _, match := range pathParameterPattern.FindAllStringSubmatch(template, -1) {
+		names = append(names, match[1])
+	}
+
+	return names
+}
+
+var pathParameterPattern = regexp.MustCompile(`\{([^}]*)\}`)
+
+func getFirst(headers map[string][]string, k string) string {
+	if vals, ok := headers[k]; ok && len(vals) > 0 {
+		return vals[0]
+	}
+
+	return ""
+}
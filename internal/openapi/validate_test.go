@@ -0,0 +1,198 @@
+package openapi
+
+import (
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/internal/request"
+	. "github.com/onsi/gomega"
+)
+
+type ValidateSuite struct{}
+
+var testContract = []byte(`{
+	"openapi": "3.0.0",
+	"servers": [{"url": "/v1"}],
+	"paths": {
+		"/pets": {
+			"get": {
+				"parameters": [
+					{"name": "limit", "in": "query", "schema": {"type": "integer"}},
+					{"name": "sort", "in": "query", "schema": {"type": "string", "enum": ["name", "age"]}},
+					{"name": "ids", "in": "query", "schema": {"type": "array", "items": {"type": "integer"}}}
+				],
+				"responses": {"200": {"description": "ok"}}
+			},
+			"post": {
+				"requestBody": {"$ref": "#/components/requestBodies/Pet"},
+				"responses": {"201": {"description": "created"}}
+			}
+		},
+		"/pets/{petId}": {
+			"parameters": [
+				{"name": "petId", "in": "path", "required": true, "schema": {"type": "integer"}}
+			],
+			"get": {
+				"parameters": [
+					{"name": "x-api-key", "in": "header", "required": true, "schema": {"type": "string"}}
+				],
+				"responses": {"200": {"description": "ok"}}
+			}
+		}
+	},
+	"components": {
+		"schemas": {
+			"Pet": {
+				"type": "object",
+				"required": ["name"],
+				"properties": {
+					"name": {"type": "string"},
+					"tag": {"$ref": "#/components/schemas/Tag"}
+				}
+			},
+			"Tag": {"type": "string", "enum": ["cat", "dog"]}
+		},
+		"requestBodies": {
+			"Pet": {
+				"required": true,
+				"content": {
+					"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}
+				}
+			}
+		}
+	}
+}`)
+
+func (s *ValidateSuite) TestValidRequests(t sweet.T) {
+	validator, err := NewValidator(testContract)
+	Expect(err).To(BeNil())
+
+	Expect(validator.Validate(&request.Request{
+		Method: "GET",
+		Path:   "/v1/pets",
+		Query:  map[string][]string{"limit": {"10"}},
+	})).To(BeEmpty())
+
+	Expect(validator.Validate(&request.Request{
+		Method:  "GET",
+		Path:    "/v1/pets/12",
+		Headers: map[string][]string{"X-Api-Key": {"secret"}},
+	})).To(BeEmpty())
+
+	Expect(validator.Validate(&request.Request{
+		Method:  "POST",
+		Path:    "/v1/pets",
+		Headers: map[string][]string{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:    `{"name": "Rex", "tag": "dog"}`,
+	})).To(BeEmpty())
+}
+
+func (s *ValidateSuite) TestArrayParameters(t sweet.T) {
+	validator, err := NewValidator(testContract)
+	Expect(err).To(BeNil())
+
+	Expect(validator.Validate(&request.Request{
+		Method: "GET",
+		Path:   "/v1/pets",
+		Query:  map[string][]string{"ids": {"1,2", "3"}},
+	})).To(BeEmpty())
+
+	Expect(validator.Validate(&request.Request{
+		Method: "GET",
+		Path:   "/v1/pets",
+		Query:  map[string][]string{"ids": {"1,x"}},
+	})).To(ConsistOf("query parameter ids: 1: Invalid type. Expected: integer, given: string"))
+}
+
+func (s *ValidateSuite) TestCandidates(t sweet.T) {
+	validator, err := NewValidator(testContract)
+	Expect(err).To(BeNil())
+
+	Expect(validator.candidates("/v1/pets")).To(HaveLen(2))
+	Expect(validator.candidates("/v1/pets/12")).To(HaveLen(3))
+	Expect(validator.candidates("/v2/pets")).To(BeEmpty())
+}
+
+func (s *ValidateSuite) TestUnknownOperation(t sweet.T) {
+	validator, err := NewValidator(testContract)
+	Expect(err).To(BeNil())
+
+	Expect(validator.Validate(&request.Request{
+		Method: "GET",
+		Path:   "/v1/owners",
+	})).To(ConsistOf("no operation matches path /v1/owners"))
+
+	Expect(validator.Validate(&request.Request{
+		Method: "DELETE",
+		Path:   "/v1/pets",
+	})).To(ConsistOf("method DELETE is not allowed for path /v1/pets"))
+}
+
+func (s *ValidateSuite) TestInvalidParameters(t sweet.T) {
+	validator, err := NewValidator(testContract)
+	Expect(err).To(BeNil())
+
+	Expect(validator.Validate(&request.Request{
+		Method: "GET",
+		Path:   "/v1/pets",
+		Query:  map[string][]string{"limit": {"ten"}, "sort": {"size"}},
+	})).To(ConsistOf(
+		"query parameter limit: Invalid type. Expected: integer, given: string",
+		`query parameter sort: (root) must be one of the following: "name", "age"`,
+	))
+
+	Expect(validator.Validate(&request.Request{
+		Method: "GET",
+		Path:   "/v1/pets/rex",
+	})).To(ConsistOf(
+		"path parameter petId: Invalid type. Expected: integer, given: string",
+		"missing required header parameter x-api-key",
+	))
+}
+
+func (s *ValidateSuite) TestInvalidBody(t sweet.T) {
+	validator, err := NewValidator(testContract)
+	Expect(err).To(BeNil())
+
+	Expect(validator.Validate(&request.Request{
+		Method: "POST",
+		Path:   "/v1/pets",
+	})).To(ConsistOf("missing required request body"))
+
+	Expect(validator.Validate(&request.Request{
+		Method:  "POST",
+		Path:    "/v1/pets",
+		Headers: map[string][]string{"Content-Type": {"text/plain"}},
+		Body:    "Rex",
+	})).To(ConsistOf(`unsupported content type "text/plain"`))
+
+	Expect(validator.Validate(&request.Request{
+		Method:  "POST",
+		Path:    "/v1/pets",
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    `{"name":`,
+	})).To(ConsistOf("request body is not valid JSON"))
+
+	Expect(validator.Validate(&request.Request{
+		Method:  "POST",
+		Path:    "/v1/pets",
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    `{"tag": "fish"}`,
+	})).To(ConsistOf(
+		"request body: name is required",
+		`request body: tag: tag must be one of the following: "cat", "dog"`,
+	))
+}
+
+func (s *ValidateSuite) TestInvalidDocument(t sweet.T) {
+	_, err := NewValidator([]byte(`{
+		"openapi": "3.0.0",
+		"paths": {
+			"/pets": {
+				"get": {
+					"parameters": [{"$ref": "#/components/parameters/Missing"}]
+				}
+			}
+		}
+	}`))
+
+	Expect(err).To(MatchError("failed to compile GET /pets (unresolved reference Missing)"))
+}
@@ -0,0 +1,234 @@
+package pact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/efritz/derision/internal/payload"
+)
+
+const (
+	stringPattern  = `"(?:[^"\\]|\\.)*"`
+	numberPattern  = `-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?`
+	integerPattern = `-?\d+`
+	booleanPattern = `(?:true|false)`
+)
+
+// bodyBuilder translates a JSON body into a pattern that matches the same
+// JSON document, tolerating insignificant whitespace. Values with a body
+// matching rule are matched by the rule instead of exactly. A type rule
+// applies to the value and to all values nested within it.
+type bodyBuilder struct {
+	decoder *json.Decoder
+	rules   []*bodyRule
+}
+
+// bodyPattern returns a pattern that matches the given Pact request body.
+// A string body is matched exactly as text. Any other JSON value is matched
+// token by token. A regular expression cannot match the members of an object
+// in any order (without enumerating every permutation), so the members must
+// appear in the order in which they are given in the contract.
+func bodyPattern(raw json.RawMessage, rules []*bodyRule) (string, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		if matcher := matchRule(rules, []string{}); matcher != nil && matcher.Match == "regex" {
+			return "^(?:" + matcher.Regex + ")$", nil
+		}
+
+		return payload.Exact(text), nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	builder := &bodyBuilder{decoder: decoder, rules: rules}
+
+	pattern, err := builder.value([]string{}, false)
+	if err != nil {
+		return "", fmt.Errorf("illegal body (%s)", err.Error())
+	}
+
+	return `^\s*` + pattern + `\s*$`, nil
+}
+
+func (b *bodyBuilder) value(path []string, typed bool) (string, error) {
+	token, err := b.decoder.Token()
+	if err != nil {
+		return "", err
+	}
+
+	matcher := matchRule(b.rules, path)
+	if matcher != nil && matcher.Match == "type" {
+		typed = true
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '{' {
+			return b.object(path, typed)
+		}
+
+		return b.array(path, typed)
+
+	case string:
+		return stringValuePattern(value, matcher, typed)
+
+	case json.Number:
+		return numberValuePattern(value, matcher, typed), nil
+
+	case bool:
+		if typed || (matcher != nil && matcher.Match == "boolean") {
+			return booleanPattern, nil
+		}
+
+		return strconv.FormatBool(value), nil
+	}
+
+	return "null", nil
+}
+
+func (b *bodyBuilder) object(path []string, typed bool) (string, error) {
+	members := []string{}
+	for b.decoder.More() {
+		token, err := b.decoder.Token()
+		if err != nil {
+			return "", err
+		}
+
+		key, _ := token.(string)
+
+		value, err := b.value(append(append([]string{}, path...), key), typed)
+		if err != nil {
+			return "", err
+		}
+
+		members = append(members, quote(key)+`\s*:\s*`+value)
+	}
+
+	if _, err := b.decoder.Token(); err != nil {
+		return "", err
+	}
+
+	return `\{\s*` + strings.Join(members, `\s*,\s*`) + `\s*\}`, nil
+}
+
+// array returns a pattern matching the elements of an array in order. An
+// array matched by type may instead have any number of elements, each of
+// which matches the type of the first element.
+func (b *bodyBuilder) array(path []string, typed bool) (string, error) {
+	elements := []string{}
+	for i := 0; b.decoder.More(); i++ {
+		value, err := b.value(append(append([]string{}, path...), strconv.Itoa(i)), typed)
+		if err != nil {
+			return "", err
+		}
+
+		elements = append(elements, value)
+	}
+
+	if _, err := b.decoder.Token(); err != nil {
+		return "", err
+	}
+
+	if typed && len(elements) > 0 {
+		return `\[\s*(?:` + elements[0] + `(?:\s*,\s*` + elements[0] + `)*)?\s*\]`, nil
+	}
+
+	return `\[\s*` + strings.Join(elements, `\s*,\s*`) + `\s*\]`, nil
+}
+
+func stringValuePattern(value string, matcher *Matcher, typed bool) (string, error) {
+	if matcher != nil {
+		switch matcher.Match {
+		case "regex":
+			return `"(?:` + strings.TrimSuffix(strings.TrimPrefix(matcher.Regex, "^"), "$") + `)"`, nil
+		case "include":
+			return `"(?:[^"\\]|\\.)*` + regexp.QuoteMeta(matcher.Value) + `(?:[^"\\]|\\.)*"`, nil
+		}
+	}
+
+	if typed {
+		return stringPattern, nil
+	}
+
+	return quote(value), nil
+}
+
+func numberValuePattern(value json.Number, matcher *Matcher, typed bool) string {
+	if matcher != nil {
+		switch matcher.Match {
+		case "regex":
+			return `(?:` + strings.TrimSuffix(strings.TrimPrefix(matcher.Regex, "^"), "$") + `)`
+		case "integer":
+			return integerPattern
+		case "decimal", "number":
+			return numberPattern
+		}
+	}
+
+	if typed {
+		return numberPattern
+	}
+
+	return regexp.QuoteMeta(value.String())
+}
+
+// matchRule returns the matcher of the most specific rule (the one with
+// the fewest wildcards) whose path matches the given path within the body.
+func matchRule(rules []*bodyRule, path []string) *Matcher {
+	var best *bodyRule
+	for _, rule := range rules {
+		if matchesPath(rule.segments, path) && (best == nil || wildcards(rule.segments) < wildcards(best.segments)) {
+			best = rule
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+
+	return best.matcher
+}
+
+func wildcards(segments []string) int {
+	count := 0
+	for _, segment := range segments {
+		if segment == "*" {
+			count++
+		}
+	}
+
+	return count
+}
+
+func matchesPath(segments, path []string) bool {
+	if len(segments) != len(path) {
+		return false
+	}
+
+	for i, segment := range segments {
+		if segment != "*" && segment != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// quote returns a pattern matching the JSON encoding of the given string.
+func quote(value string) string {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+
+	return regexp.QuoteMeta(strings.TrimSuffix(buffer.String(), "\n"))
+}
@@ -0,0 +1,483 @@
+package pact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/efritz/derision/internal/payload"
+)
+
+type (
+	// contract is a Pact v2 or v3 document as read for import. Matching
+	// rules and headers differ between versions, so they are decoded
+	// lazily.
+	contract struct {
+		Consumer     *Pacticipant           `json:"consumer"`
+		Provider     *Pacticipant           `json:"provider"`
+		Interactions []*contractInteraction `json:"interactions"`
+	}
+
+	contractInteraction struct {
+		Description string            `json:"description"`
+		Request     *contractRequest  `json:"request"`
+		Response    *contractResponse `json:"response"`
+	}
+
+	contractRequest struct {
+		Method        string                     `json:"method"`
+		Path          string                     `json:"path"`
+		Query         json.RawMessage            `json:"query"`
+		Headers       map[string]json.RawMessage `json:"headers"`
+		Body          json.RawMessage            `json:"body"`
+		MatchingRules map[string]json.RawMessage `json:"matchingRules"`
+	}
+
+	contractResponse struct {
+		Status  int                        `json:"status"`
+		Headers map[string]json.RawMessage `json:"headers"`
+		Body    json.RawMessage            `json:"body"`
+	}
+
+	// rules are the matching rules of a request, normalized from either
+	// the v2 or v3 format. Body rules are keyed by their path within the
+	// body (e.g. $.items[*].id).
+	rules struct {
+		path    *Matcher
+		query   map[string]*Matcher
+		headers map[string]*Matcher
+		body    []*bodyRule
+	}
+
+	bodyRule struct {
+		segments []string
+		matcher  *Matcher
+	}
+)
+
+// IsDocument determines if the given JSON data looks like a Pact contract
+// (an object with a consumer or provider and a list of interactions).
+func IsDocument(data []byte) bool {
+	document := &struct {
+		Consumer     *Pacticipant      `json:"consumer"`
+		Provider     *Pacticipant      `json:"provider"`
+		Interactions []json.RawMessage `json:"interactions"`
+	}{}
+
+	if err := json.Unmarshal(data, document); err != nil {
+		return false
+	}
+
+	return document.Interactions != nil && (document.Consumer != nil || document.Provider != nil)
+}
+
+// Convert generates one handler payload for each interaction of the given
+// Pact v2 or v3 contract. Type, regex, and include matching rules of the
+// request path, query, headers, and body are translated into patterns. Only
+// the first value of a query parameter is matched. Provider states are not
+// represented in the generated payloads.
+func Convert(data []byte) ([]payload.Handler, error) {
+	document := &contract{}
+	if err := json.Unmarshal(data, document); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal contract (%s)", err.Error())
+	}
+
+	handlers := []payload.Handler{}
+	for i, interaction := range document.Interactions {
+		if interaction == nil || interaction.Request == nil {
+			continue
+		}
+
+		handler, err := convertInteraction(interaction)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert interaction %d (%s)", i, err.Error())
+		}
+
+		handlers = append(handlers, handler)
+	}
+
+	return handlers, nil
+}
+
+func convertInteraction(interaction *contractInteraction) (payload.Handler, error) {
+	rules, err := parseRules(interaction.Request.MatchingRules)
+	if err != nil {
+		return payload.Handler{}, err
+	}
+
+	request, err := convertRequest(interaction.Request, rules)
+	if err != nil {
+		return payload.Handler{}, err
+	}
+
+	response := payload.Response{StatusCode: "200"}
+	if interaction.Response != nil {
+		if response, err = convertResponse(interaction.Response); err != nil {
+			return payload.Handler{}, err
+		}
+	}
+
+	return payload.Handler{Request: request, Response: response}, nil
+}
+
+func convertRequest(r *contractRequest, rules *rules) (payload.Request, error) {
+	method := ""
+	if r.Method != "" {
+		method = payload.Exact(strings.ToUpper(r.Method))
+	}
+
+	path := ""
+	if r.Path != "" || rules.path != nil {
+		path = valuePattern(r.Path, rules.path)
+	}
+
+	values, err := queryValues(r.Query)
+	if err != nil {
+		return payload.Request{}, fmt.Errorf("illegal query (%s)", err.Error())
+	}
+
+	var query map[string]string
+	for name, value := range values {
+		if query == nil {
+			query = map[string]string{}
+		}
+
+		query[name] = valuePattern(value[0], rules.query[name])
+	}
+
+	var headers map[string]string
+	for name, raw := range r.Headers {
+		value, err := headerValue(raw)
+		if err != nil {
+			return payload.Request{}, fmt.Errorf("illegal header %s (%s)", name, err.Error())
+		}
+
+		if headers == nil {
+			headers = map[string]string{}
+		}
+
+		name = http.CanonicalHeaderKey(name)
+		if matcher := rules.headers[name]; matcher != nil || name != "Content-Type" {
+			headers[name] = valuePattern(value, matcher)
+			continue
+		}
+
+		headers[name] = mediaTypePattern(value)
+	}
+
+	body, err := bodyPattern(r.Body, rules.body)
+	if err != nil {
+		return payload.Request{}, err
+	}
+
+	return payload.Request{
+		Method:  method,
+		Path:    path,
+		Query:   query,
+		Headers: headers,
+		Body:    body,
+	}, nil
+}
+
+func convertResponse(r *contractResponse) (payload.Response, error) {
+	headers := map[string][]string{}
+	for name, raw := range r.Headers {
+		value, err := headerValue(raw)
+		if err != nil {
+			return payload.Response{}, fmt.Errorf("illegal header %s (%s)", name, err.Error())
+		}
+
+		headers[http.CanonicalHeaderKey(name)] = []string{payload.Literal(value)}
+	}
+
+	body := ""
+	if len(r.Body) > 0 && !bytes.Equal(r.Body, []byte("null")) {
+		var text string
+		if err := json.Unmarshal(r.Body, &text); err == nil {
+			body = text
+		} else {
+			buffer := &bytes.Buffer{}
+			if err := json.Compact(buffer, r.Body); err != nil {
+				return payload.Response{}, err
+			}
+
+			body = buffer.String()
+
+			if _, ok := headers["Content-Type"]; !ok {
+				headers["Content-Type"] = []string{"application/json"}
+			}
+		}
+	}
+
+	statusCode := r.Status
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	return payload.Response{
+		StatusCode: strconv.Itoa(statusCode),
+		Headers:    headers,
+		Body:       payload.Literal(body),
+	}, nil
+}
+
+// queryValues decodes a query given either as a query string (v2) or as a
+// map from parameter names to a value or a list of values (v3). Parameters
+// without a value are omitted.
+func queryValues(raw json.RawMessage) (map[string][]string, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		values, err := url.ParseQuery(text)
+		if err != nil {
+			return nil, err
+		}
+
+		return nonEmpty(values), nil
+	}
+
+	object := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+
+	values := map[string][]string{}
+	for name, raw := range object {
+		var value string
+		if err := json.Unmarshal(raw, &value); err == nil {
+			values[name] = []string{value}
+			continue
+		}
+
+		list := []string{}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Errorf("illegal parameter %s (%s)", name, err.Error())
+		}
+
+		values[name] = list
+	}
+
+	return nonEmpty(values), nil
+}
+
+func nonEmpty(values map[string][]string) map[string][]string {
+	for name, list := range values {
+		if len(list) == 0 {
+			delete(values, name)
+		}
+	}
+
+	return values
+}
+
+// headerValue decodes a header value given either as a string or as a
+// list of strings (which are joined).
+func headerValue(raw json.RawMessage) (string, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, nil
+	}
+
+	values := []string{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return "", err
+	}
+
+	return strings.Join(values, ", "), nil
+}
+
+// mediaTypePattern returns a pattern that matches the given media type,
+// ignoring case, followed by any parameters (such as a charset) that were
+// not given in the contract.
+func mediaTypePattern(value string) string {
+	return `^(?i:` + regexp.QuoteMeta(strings.TrimSpace(value)) + `)(?:\s*;.*)?$`
+}
+
+// valuePattern returns a pattern that matches an entire path or header
+// value according to the given matcher. Without a matcher, the value must
+// be matched exactly.
+func valuePattern(value string, matcher *Matcher) string {
+	if matcher != nil {
+		switch matcher.Match {
+		case "regex":
+			return "^(?:" + matcher.Regex + ")$"
+		case "include":
+			return regexp.QuoteMeta(matcher.Value)
+		case "type":
+			return ".+"
+		}
+	}
+
+	return payload.Exact(value)
+}
+
+// parseRules normalizes the matching rules of a request. Version 2 rules
+// are a flat map keyed by paths such as $.headers.Accept, while version 3
+// rules are grouped by category and list their matchers.
+func parseRules(raw map[string]json.RawMessage) (*rules, error) {
+	rules := &rules{query: map[string]*Matcher{}, headers: map[string]*Matcher{}}
+
+	for key, value := range raw {
+		switch {
+		case key == "path":
+			matcher, err := parseMatchers(value)
+			if err != nil {
+				return nil, err
+			}
+
+			rules.path = matcher
+
+		case key == "query" || key == "header" || key == "body":
+			group := map[string]json.RawMessage{}
+			if err := json.Unmarshal(value, &group); err != nil {
+				return nil, fmt.Errorf("illegal %s matching rules (%s)", key, err.Error())
+			}
+
+			for name, value := range group {
+				if err := rules.add(key, name, value); err != nil {
+					return nil, err
+				}
+			}
+
+		case key == "$.path":
+			matcher, err := parseMatchers(value)
+			if err != nil {
+				return nil, err
+			}
+
+			rules.path = matcher
+
+		case strings.HasPrefix(key, "$.query."):
+			if err := rules.add("query", strings.TrimPrefix(key, "$.query."), value); err != nil {
+				return nil, err
+			}
+
+		case strings.HasPrefix(key, "$.headers."):
+			if err := rules.add("header", strings.TrimPrefix(key, "$.headers."), value); err != nil {
+				return nil, err
+			}
+
+		case key == "$.body" || strings.HasPrefix(key, "$.body.") || strings.HasPrefix(key, "$.body["):
+			if err := rules.add("body", "$"+strings.TrimPrefix(key, "$.body"), value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Rules are read from a map, so order them to make the choice between
+	// equally specific rules deterministic.
+	sort.Slice(rules.body, func(i, j int) bool {
+		return strings.Join(rules.body[i].segments, ".") < strings.Join(rules.body[j].segments, ".")
+	})
+
+	return rules, nil
+}
+
+func (r *rules) add(category, name string, value json.RawMessage) error {
+	matcher, err := parseMatchers(value)
+	if err != nil || matcher == nil {
+		return err
+	}
+
+	switch category {
+	case "query":
+		r.query[name] = matcher
+		return nil
+
+	case "header":
+		r.headers[http.CanonicalHeaderKey(name)] = matcher
+		return nil
+	}
+
+	r.body = append(r.body, &bodyRule{
+		segments: parseRulePath(name),
+		matcher:  matcher,
+	})
+
+	return nil
+}
+
+// parseMatchers decodes a v3 rule (an object with a list of matchers) or
+// a v2 rule (a single matcher). Only the first matcher of a rule is used.
+func parseMatchers(raw json.RawMessage) (*Matcher, error) {
+	rule := &struct {
+		Matchers []*Matcher `json:"matchers"`
+		*Matcher
+	}{Matcher: &Matcher{}}
+
+	if err := json.Unmarshal(raw, rule); err != nil {
+		return nil, fmt.Errorf("illegal matching rule (%s)", err.Error())
+	}
+
+	matcher := rule.Matcher
+	if len(rule.Matchers) > 0 {
+		matcher = rule.Matchers[0]
+	}
+
+	if matcher == nil {
+		return nil, nil
+	}
+
+	if matcher.Match == "" && matcher.Regex != "" {
+		matcher.Match = "regex"
+	}
+
+	if matcher.Match == "regex" {
+		if _, err := regexp.Compile(matcher.Regex); err != nil {
+			return nil, fmt.Errorf("illegal regex %s", matcher.Regex)
+		}
+	}
+
+	if matcher.Match == "" {
+		return nil, nil
+	}
+
+	return matcher, nil
+}
+
+// parseRulePath splits a body rule path such as $.items[*]['first name']
+// into its segments. Wildcards are represented as "*".
+func parseRulePath(path string) []string {
+	path = strings.TrimPrefix(path, "$")
+
+	segments := []string{}
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+
+			segments = append(segments, path[:end])
+			path = path[end:]
+
+		case '[':
+			end := strings.Index(path, "]")
+			if end < 0 {
+				// An unterminated bracket extends to the end of the path
+				segments = append(segments, strings.Trim(path[1:], `'"`))
+				return segments
+			}
+
+			segments = append(segments, strings.Trim(path[1:end], `'"`))
+			path = path[end+1:]
+
+		default:
+			return segments
+		}
+	}
+
+	return segments
+}
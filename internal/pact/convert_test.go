@@ -0,0 +1,226 @@
+package pact
+
+import (
+	"regexp"
+
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/internal/payload"
+	. "github.com/onsi/gomega"
+)
+
+type ConvertSuite struct{}
+
+func (s *ConvertSuite) TestIsDocument(t sweet.T) {
+	Expect(IsDocument([]byte(`{"consumer": {"name": "a"}, "provider": {"name": "b"}, "interactions": []}`))).To(BeTrue())
+	Expect(IsDocument([]byte(`{"interactions": []}`))).To(BeFalse())
+	Expect(IsDocument([]byte(`{"consumer": {"name": "a"}}`))).To(BeFalse())
+	Expect(IsDocument([]byte(`[]`))).To(BeFalse())
+}
+
+func (s *ConvertSuite) TestConvertV3(t sweet.T) {
+	handlers, err := Convert([]byte(`{
+		"consumer": {"name": "web"},
+		"provider": {"name": "users"},
+		"interactions": [
+			{
+				"description": "create a user",
+				"providerStates": [{"name": "no users exist"}],
+				"request": {
+					"method": "post",
+					"path": "/users/1",
+					"headers": {"content-type": "application/json", "x-api-key": "abc"},
+					"body": {"name": "foo", "age": 3, "tags": ["a"], "admin": false},
+					"matchingRules": {
+						"path": {"matchers": [{"match": "regex", "regex": "/users/\\d+"}]},
+						"header": {"X-Api-Key": {"matchers": [{"match": "type"}]}},
+						"body": {
+							"$.name": {"matchers": [{"match": "include", "value": "o"}]},
+							"$.age": {"matchers": [{"match": "integer"}]},
+							"$.tags": {"matchers": [{"match": "type", "min": 1}]}
+						}
+					}
+				},
+				"response": {
+					"status": 201,
+					"headers": {"Location": "/users/1"},
+					"body": {"id": 1, "name": "foo"}
+				}
+			}
+		]
+	}`))
+
+	Expect(err).To(BeNil())
+	Expect(handlers).To(HaveLen(1))
+	Expect(handlers[0].Request.Method).To(Equal("^POST$"))
+	Expect(handlers[0].Request.Path).To(Equal(`^(?:/users/\d+)$`))
+	Expect(handlers[0].Request.Headers).To(Equal(map[string]string{
+		"Content-Type": `^(?i:application/json)(?:\s*;.*)?$`,
+		"X-Api-Key":    ".+",
+	}))
+
+	contentType := regexp.MustCompile(handlers[0].Request.Headers["Content-Type"])
+	Expect(contentType.MatchString("application/json")).To(BeTrue())
+	Expect(contentType.MatchString("application/json; charset=utf-8")).To(BeTrue())
+	Expect(contentType.MatchString("Application/JSON;charset=UTF-8")).To(BeTrue())
+	Expect(contentType.MatchString("application/jsonp")).To(BeFalse())
+
+	Expect(handlers[0].Response).To(Equal(payload.Response{
+		StatusCode: "201",
+		Headers: map[string][]string{
+			"Location":     {"/users/1"},
+			"Content-Type": {"application/json"},
+		},
+		Body: `{"id":1,"name":"foo"}`,
+	}))
+
+	body := regexp.MustCompile(handlers[0].Request.Body)
+	Expect(body.MatchString(`{"name": "foo", "age": 3, "tags": ["a"], "admin": false}`)).To(BeTrue())
+	Expect(body.MatchString(`{"name":"bob","age":-12,"tags":["x","y"],"admin":false}`)).To(BeTrue())
+	Expect(body.MatchString(`{"name":"bob","age":-12,"tags":[],"admin":false}`)).To(BeTrue())
+	Expect(body.MatchString(`{"name":"bill","age":3,"tags":["a"],"admin":false}`)).To(BeFalse())
+	Expect(body.MatchString(`{"name":"foo","age":3.5,"tags":["a"],"admin":false}`)).To(BeFalse())
+	Expect(body.MatchString(`{"name":"foo","age":3,"tags":[1],"admin":false}`)).To(BeFalse())
+	Expect(body.MatchString(`{"name":"foo","age":3,"tags":["a"],"admin":true}`)).To(BeFalse())
+
+	// Object members must be given in the order of the contract
+	Expect(body.MatchString(`{"age": 3, "name": "foo", "tags": ["a"], "admin": false}`)).To(BeFalse())
+}
+
+func (s *ConvertSuite) TestConvertV2(t sweet.T) {
+	handlers, err := Convert([]byte(`{
+		"consumer": {"name": "web"},
+		"provider": {"name": "users"},
+		"interactions": [
+			{
+				"description": "list users",
+				"providerState": "users exist",
+				"request": {
+					"method": "GET",
+					"path": "/users",
+					"query": "page=1",
+					"headers": {"Accept": "application/json"},
+					"matchingRules": {
+						"$.headers.Accept": {"regex": "application/.*json"}
+					}
+				},
+				"response": {
+					"status": 200,
+					"body": [{"id": 1}]
+				}
+			},
+			{
+				"description": "create a user",
+				"request": {
+					"method": "POST",
+					"path": "/users",
+					"body": {"user": {"name": "foo", "id": 7}},
+					"matchingRules": {
+						"$.body.user": {"match": "type"},
+						"$.body.user.id": {"regex": "^\\d$"}
+					}
+				},
+				"response": {
+					"status": 204,
+					"body": "created"
+				}
+			}
+		]
+	}`))
+
+	Expect(err).To(BeNil())
+	Expect(handlers).To(HaveLen(2))
+	Expect(handlers[0].Request).To(Equal(payload.Request{
+		Method:  "^GET$",
+		Path:    "^/users$",
+		Query:   map[string]string{"page": "^1$"},
+		Headers: map[string]string{"Accept": "^(?:application/.*json)$"},
+	}))
+
+	Expect(handlers[0].Response.Body).To(Equal(`[{"id":1}]`))
+	Expect(handlers[1].Response).To(Equal(payload.Response{
+		StatusCode: "204",
+		Headers:    map[string][]string{},
+		Body:       "created",
+	}))
+
+	body := regexp.MustCompile(handlers[1].Request.Body)
+	Expect(body.MatchString(`{"user": {"name": "bar", "id": 3}}`)).To(BeTrue())
+	Expect(body.MatchString(`{"user": {"name": "bar", "id": 31}}`)).To(BeFalse())
+	Expect(body.MatchString(`{"user": {"name": 1, "id": 3}}`)).To(BeFalse())
+}
+
+func (s *ConvertSuite) TestConvertQuery(t sweet.T) {
+	handlers, err := Convert([]byte(`{
+		"consumer": {"name": "web"},
+		"interactions": [
+			{
+				"request": {
+					"method": "GET",
+					"path": "/users",
+					"query": {"status": ["active"], "page": ["1", "2"], "q": "a b"},
+					"matchingRules": {
+						"query": {"page": {"matchers": [{"match": "regex", "regex": "\\d+"}]}}
+					}
+				},
+				"response": {"status": 200, "body": "active"}
+			},
+			{
+				"request": {"method": "GET", "path": "/users", "query": {"status": ["inactive"]}},
+				"response": {"status": 200, "body": "inactive"}
+			}
+		]
+	}`))
+
+	Expect(err).To(BeNil())
+	Expect(handlers).To(HaveLen(2))
+	Expect(handlers[0].Request.Query).To(Equal(map[string]string{
+		"status": "^active$",
+		"page":   `^(?:\d+)$`,
+		"q":      `^a b$`,
+	}))
+
+	// Interactions that differ only by query do not collide
+	Expect(handlers[1].Request.Query).To(Equal(map[string]string{"status": "^inactive$"}))
+}
+
+func (s *ConvertSuite) TestConvertIllegalQuery(t sweet.T) {
+	_, err := Convert([]byte(`{
+		"consumer": {"name": "web"},
+		"interactions": [
+			{"request": {"method": "GET", "path": "/a", "query": {"x": 1}}}
+		]
+	}`))
+
+	Expect(err).To(MatchError(ContainSubstring("failed to convert interaction 0 (illegal query (illegal parameter x")))
+}
+
+func (s *ConvertSuite) TestConvertTextBody(t sweet.T) {
+	handlers, err := Convert([]byte(`{
+		"consumer": {"name": "web"},
+		"interactions": [
+			{"request": {"method": "PUT", "path": "/notes", "body": "a.b"}, "response": {"status": 200}}
+		]
+	}`))
+
+	Expect(err).To(BeNil())
+	Expect(handlers[0].Request.Body).To(Equal(`^a\.b$`))
+}
+
+func (s *ConvertSuite) TestConvertIllegalRegex(t sweet.T) {
+	_, err := Convert([]byte(`{
+		"consumer": {"name": "web"},
+		"interactions": [
+			{"request": {"method": "GET", "path": "/a", "matchingRules": {"$.path": {"regex": "("}}}}
+		]
+	}`))
+
+	Expect(err).To(MatchError("failed to convert interaction 0 (illegal regex ()"))
+}
+
+func (s *ConvertSuite) TestParseRulePath(t sweet.T) {
+	Expect(parseRulePath("$")).To(BeEmpty())
+	Expect(parseRulePath("$.a.b")).To(Equal([]string{"a", "b"}))
+	Expect(parseRulePath("$.items[*].id")).To(Equal([]string{"items", "*", "id"}))
+	Expect(parseRulePath("$['first name'][0].*")).To(Equal([]string{"first name", "0", "*"}))
+	Expect(parseRulePath("$.a['b")).To(Equal([]string{"a", "b"}))
+}
@@ -0,0 +1,73 @@
+package pact
+
+import "encoding/json"
+
+type (
+	// Document is a Pact contract between a consumer and a provider. Only
+	// the fields used by derision are modeled.
+	Document struct {
+		Consumer     *Pacticipant   `json:"consumer"`
+		Provider     *Pacticipant   `json:"provider"`
+		Interactions []*Interaction `json:"interactions"`
+		Metadata     *Metadata      `json:"metadata"`
+	}
+
+	Pacticipant struct {
+		Name string `json:"name"`
+	}
+
+	Interaction struct {
+		Description    string           `json:"description"`
+		ProviderStates []*ProviderState `json:"providerStates,omitempty"`
+		Request        *Request         `json:"request"`
+		Response       *Response        `json:"response"`
+	}
+
+	ProviderState struct {
+		Name string `json:"name"`
+	}
+
+	Request struct {
+		Method        string              `json:"method"`
+		Path          string              `json:"path"`
+		Query         map[string][]string `json:"query,omitempty"`
+		Headers       map[string]string   `json:"headers,omitempty"`
+		Body          json.RawMessage     `json:"body,omitempty"`
+		MatchingRules *MatchingRules      `json:"matchingRules,omitempty"`
+	}
+
+	Response struct {
+		Status        int               `json:"status"`
+		Headers       map[string]string `json:"headers,omitempty"`
+		Body          json.RawMessage   `json:"body,omitempty"`
+		MatchingRules *MatchingRules    `json:"matchingRules,omitempty"`
+	}
+
+	// MatchingRules are the v3 matching rules of a request or response,
+	// grouped by the part of the message to which they apply.
+	MatchingRules struct {
+		Path   *Rule            `json:"path,omitempty"`
+		Query  map[string]*Rule `json:"query,omitempty"`
+		Header map[string]*Rule `json:"header,omitempty"`
+		Body   map[string]*Rule `json:"body,omitempty"`
+	}
+
+	Rule struct {
+		Combine  string     `json:"combine,omitempty"`
+		Matchers []*Matcher `json:"matchers"`
+	}
+
+	Matcher struct {
+		Match string `json:"match"`
+		Regex string `json:"regex,omitempty"`
+		Value string `json:"value,omitempty"`
+	}
+
+	Metadata struct {
+		PactSpecification *Specification `json:"pactSpecification"`
+	}
+
+	Specification struct {
+		Version string `json:"version"`
+	}
+)
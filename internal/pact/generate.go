@@ -0,0 +1,165 @@
+package pact
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/efritz/derision/internal/request"
+)
+
+// ignoredHeaders are response headers that are specific to a single
+// exchange and should not be part of a contract.
+var ignoredHeaders = map[string]struct{}{
+	"Content-Length": struct{}{},
+	"Date":           struct{}{},
+}
+
+// Generate creates a Pact v3 contract containing one interaction for each
+// request in the given log that was matched by an expectation. Requests
+// that were not matched (or not answered) are omitted, as are requests that
+// were matched only by a fallback expectation, which describes what the mock
+// does when nothing else matches rather than what the provider does. The
+// patterns of the matched expectation become regex matching rules of the
+// interaction.
+func Generate(consumer, provider string, requests []*request.Request) *Document {
+	descriptions := map[string]int{}
+	interactions := []*Interaction{}
+
+	for _, r := range requests {
+		if r.Expectation == nil || r.Expectation.Fallback || r.Response == nil {
+			continue
+		}
+
+		description := fmt.Sprintf("%s %s", r.Method, r.Path)
+		if descriptions[description]++; descriptions[description] > 1 {
+			description = fmt.Sprintf("%s (%d)", description, descriptions[description])
+		}
+
+		interactions = append(interactions, &Interaction{
+			Description: description,
+			Request:     generateRequest(r),
+			Response:    generateResponse(r.Response),
+		})
+	}
+
+	return &Document{
+		Consumer:     &Pacticipant{Name: consumer},
+		Provider:     &Pacticipant{Name: provider},
+		Interactions: interactions,
+		Metadata: &Metadata{
+			PactSpecification: &Specification{Version: "3.0.0"},
+		},
+	}
+}
+
+func generateRequest(r *request.Request) *Request {
+	rules := &MatchingRules{}
+	if r.Expectation.Path != "" {
+		rules.Path = regexRule(r.Expectation.Path)
+	}
+
+	for name, pattern := range r.Expectation.Query {
+		if rules.Query == nil {
+			rules.Query = map[string]*Rule{}
+		}
+
+		rules.Query[name] = regexRule(pattern)
+	}
+
+	// Only the headers constrained by the expectation are part of the
+	// contract. Other headers were incidental to the recorded request.
+	headers := map[string]string{}
+	for name, pattern := range r.Expectation.Headers {
+		// The absence of a header cannot be expressed in a contract
+		if pattern.Absent {
+			continue
+		}
+
+		name = http.CanonicalHeaderKey(name)
+		headers[name] = strings.Join(r.Headers[name], ", ")
+
+		if rules.Header == nil {
+			rules.Header = map[string]*Rule{}
+		}
+
+		// Pact applies a header rule to the whole (comma-joined) value, so
+		// the any, all, and index modes become a rule on that value. This is
+		// lossy: the rule is looser or stricter than the expectation was.
+		rules.Header[name] = regexRule(pattern.Pattern)
+	}
+
+	if rules.Path == nil && rules.Query == nil && rules.Header == nil {
+		rules = nil
+	}
+
+	query := r.Query
+	if len(query) == 0 {
+		query = nil
+	}
+
+	return &Request{
+		Method:        r.Method,
+		Path:          r.Path,
+		Query:         query,
+		Headers:       headers,
+		Body:          generateBody(r.Body, first(r.Headers, "Content-Type")),
+		MatchingRules: rules,
+	}
+}
+
+func generateResponse(r *request.Response) *Response {
+	headers := map[string]string{}
+	for name, values := range r.Headers {
+		if _, ok := ignoredHeaders[http.CanonicalHeaderKey(name)]; !ok {
+			headers[name] = strings.Join(values, ", ")
+		}
+	}
+
+	return &Response{
+		Status:  r.StatusCode,
+		Headers: headers,
+		Body:    generateBody(r.Body, first(r.Headers, "Content-Type")),
+	}
+}
+
+// generateBody returns the given body as embedded JSON if it has a JSON
+// content type and is well-formed. Otherwise, the body is a JSON string.
+func generateBody(body, contentType string) json.RawMessage {
+	if body == "" {
+		return nil
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(contentType); isJSONMediaType(mediaType) && json.Valid([]byte(body)) {
+		return json.RawMessage(body)
+	}
+
+	serialized, _ := json.Marshal(body)
+	return serialized
+}
+
+// regexRule creates a rule from an expectation pattern. Pact regexes
+// must match the entire value, whereas expectation patterns may match
+// any substring, so patterns that are not anchored at both ends are
+// padded.
+func regexRule(pattern string) *Rule {
+	if !strings.HasPrefix(pattern, "^") || !strings.HasSuffix(pattern, "$") {
+		pattern = ".*(?:" + pattern + ").*"
+	}
+
+	return &Rule{Matchers: []*Matcher{&Matcher{Match: "regex", Regex: pattern}}}
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func first(headers map[string][]string, k string) string {
+	if vals, ok := headers[k]; ok && len(vals) > 0 {
+		return vals[0]
+	}
+
+	return ""
+}
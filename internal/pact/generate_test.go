@@ -0,0 +1,116 @@
+package pact
+
+import (
+	"encoding/json"
+
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/internal/request"
+	. "github.com/onsi/gomega"
+)
+
+type GenerateSuite struct{}
+
+func (s *GenerateSuite) TestGenerate(t sweet.T) {
+	document := Generate("web", "users", []*request.Request{
+		&request.Request{
+			Method:  "POST",
+			Path:    "/users",
+			Query:   map[string][]string{"dry_run": {"true"}},
+			Headers: map[string][]string{"Content-Type": {"application/json"}, "X-Api-Key": {"secret"}, "Accept": {"*/*"}},
+			Body:    `{"name": "foo"}`,
+			Expectation: &request.Expectation{
+				Method:  "POST",
+				Path:    "^/users$",
+				Query:   map[string]string{"dry_run": "^true$"},
+				Headers: map[string]request.HeaderPattern{"x-api-key": {Pattern: "."}},
+			},
+			Response: &request.Response{
+				StatusCode: 201,
+				Headers:    map[string][]string{"Content-Type": {"application/json"}, "Content-Length": {"9"}},
+				Body:       `{"id": 1}`,
+			},
+		},
+		&request.Request{
+			Method:   "GET",
+			Path:     "/unmatched",
+			Response: &request.Response{StatusCode: 404},
+		},
+		&request.Request{
+			Method:      "GET",
+			Path:        "/health",
+			Query:       map[string][]string{},
+			Expectation: &request.Expectation{},
+			Response:    &request.Response{StatusCode: 200, Body: "ok"},
+		},
+		&request.Request{
+			Method:      "GET",
+			Path:        "/health",
+			Expectation: &request.Expectation{},
+			Response:    &request.Response{StatusCode: 200, Body: "ok"},
+		},
+	})
+
+	serialized, err := json.Marshal(document)
+	Expect(err).To(BeNil())
+	Expect(serialized).To(MatchJSON(`{
+		"consumer": {"name": "web"},
+		"provider": {"name": "users"},
+		"interactions": [
+			{
+				"description": "POST /users",
+				"request": {
+					"method": "POST",
+					"path": "/users",
+					"query": {"dry_run": ["true"]},
+					"headers": {"X-Api-Key": "secret"},
+					"body": {"name": "foo"},
+					"matchingRules": {
+						"path": {"matchers": [{"match": "regex", "regex": "^/users$"}]},
+						"query": {"dry_run": {"matchers": [{"match": "regex", "regex": "^true$"}]}},
+						"header": {"X-Api-Key": {"matchers": [{"match": "regex", "regex": ".*(?:.).*"}]}}
+					}
+				},
+				"response": {
+					"status": 201,
+					"headers": {"Content-Type": "application/json"},
+					"body": {"id": 1}
+				}
+			},
+			{
+				"description": "GET /health",
+				"request": {"method": "GET", "path": "/health"},
+				"response": {"status": 200, "body": "ok"}
+			},
+			{
+				"description": "GET /health (2)",
+				"request": {"method": "GET", "path": "/health"},
+				"response": {"status": 200, "body": "ok"}
+			}
+		],
+		"metadata": {"pactSpecification": {"version": "3.0.0"}}
+	}`))
+}
+
+func (s *GenerateSuite) TestGenerateSkipsFallback(t sweet.T) {
+	document := Generate("web", "users", []*request.Request{
+		&request.Request{
+			Method:      "GET",
+			Path:        "/missing",
+			Expectation: &request.Expectation{Fallback: true},
+			Response:    &request.Response{StatusCode: 404},
+		},
+	})
+
+	Expect(document.Interactions).To(BeEmpty())
+}
+
+func (s *GenerateSuite) TestGenerateEmpty(t sweet.T) {
+	serialized, err := json.Marshal(Generate("web", "users", nil))
+	Expect(err).To(BeNil())
+	Expect(serialized).To(MatchJSON(`{
+		"consumer": {"name": "web"},
+		"provider": {"name": "users"},
+		"interactions": [],
+		"metadata": {"pactSpecification": {"version": "3.0.0"}}
+	}`))
+}
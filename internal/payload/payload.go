@@ -0,0 +1,59 @@
+package payload
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Handler is the structure of a payload to the register endpoint. It is
+	// used by converters that generate expectations from other formats.
+	Handler struct {
+		Request  Request  `json:"request"`
+		Response Response `json:"response"`
+	}
+
+	Request struct {
+		Method  string            `json:"method,omitempty"`
+		Path    string            `json:"path,omitempty"`
+		Query   map[string]string `json:"query,omitempty"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    string            `json:"body,omitempty"`
+	}
+
+	Response struct {
+		StatusCode string              `json:"status_code,omitempty"`
+		Headers    map[string][]string `json:"headers,omitempty"`
+		Body       string              `json:"body,omitempty"`
+		BodyBase64 string              `json:"body_base64,omitempty"`
+	}
+)
+
+// Exact returns a pattern that matches only the given text.
+func Exact(text string) string {
+	return "^" + regexp.QuoteMeta(text) + "$"
+}
+
+// Literal returns a response template that renders the given text
+// verbatim, even if the text contains template actions.
+func Literal(text string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	return "{{" + strconv.Quote(text) + "}}"
+}
+
+// Pointer returns a JSON pointer (RFC 6901) to the value with the given path
+// of reference tokens.
+func Pointer(tokens ...string) string {
+	pointer := ""
+	for _, token := range tokens {
+		pointer += "/" + pointerEscaper.Replace(token)
+	}
+
+	return pointer
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
@@ -0,0 +1,34 @@
+package payload
+
+import (
+	"bytes"
+	"regexp"
+	"text/template"
+
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type PayloadSuite struct{}
+
+func (s *PayloadSuite) TestExact(t sweet.T) {
+	re := regexp.MustCompile(Exact("/users/1.json"))
+	Expect(re.MatchString("/users/1.json")).To(BeTrue())
+	Expect(re.MatchString("/users/1xjson")).To(BeFalse())
+	Expect(re.MatchString("/api/users/1.json")).To(BeFalse())
+}
+
+func (s *PayloadSuite) TestLiteral(t sweet.T) {
+	for _, text := range []string{"", "plain", `{"a": "{{b}}"}`, "}} {{ \"quoted\"\n"} {
+		buffer := &bytes.Buffer{}
+		err := template.Must(template.New("").Parse(Literal(text))).Execute(buffer, nil)
+		Expect(err).To(BeNil())
+		Expect(buffer.String()).To(Equal(text))
+	}
+}
+
+func (s *PayloadSuite) TestPointer(t sweet.T) {
+	Expect(Pointer()).To(Equal(""))
+	Expect(Pointer("0", "request", "path")).To(Equal("/0/request/path"))
+	Expect(Pointer("headers", "a/b~c")).To(Equal("/headers/a~1b~0c"))
+}
@@ -0,0 +1,118 @@
+package postman
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/efritz/derision/internal/payload"
+)
+
+// Convert generates one handler payload for each saved example response in
+// the given Postman collection. Examples are visited in the order in which
+// they appear in the collection, descending into folders.
+func Convert(data []byte) ([]payload.Handler, error) {
+	collection, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertItems(collection.Items), nil
+}
+
+func convertItems(items []*Item) []payload.Handler {
+	handlers := []payload.Handler{}
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+
+		handlers = append(handlers, convertItems(item.Items)...)
+
+		for _, response := range item.Responses {
+			if response != nil {
+				handlers = append(handlers, convertExample(item.Request, response))
+			}
+		}
+	}
+
+	return handlers
+}
+
+// convertExample creates a handler for a saved example. The example's
+// original request takes precedence over the request of its item.
+func convertExample(request *Request, response *Response) payload.Handler {
+	method, segments := "GET", []string{""}
+	for _, r := range []*Request{request, response.OriginalRequest} {
+		if r == nil {
+			continue
+		}
+
+		if r.Method != "" {
+			method = strings.ToUpper(r.Method)
+		}
+
+		if r.URL != nil {
+			segments = r.URL.Segments()
+		}
+	}
+
+	headers := map[string][]string{}
+	for _, header := range response.Headers {
+		if header == nil || header.Disabled || header.Key == "" {
+			continue
+		}
+
+		name := http.CanonicalHeaderKey(header.Key)
+		headers[name] = append(headers[name], payload.Literal(header.Value))
+	}
+
+	statusCode := response.Code
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	return payload.Handler{
+		Request: payload.Request{
+			Method: payload.Exact(method),
+			Path:   PathPattern(segments),
+		},
+		Response: payload.Response{
+			StatusCode: strconv.Itoa(statusCode),
+			Headers:    headers,
+			Body:       payload.Literal(response.Body),
+		},
+	}
+}
+
+var variablePattern = regexp.MustCompile(`\{\{[^}]*\}\}`)
+
+// PathPattern converts the segments of a Postman URL into a regular
+// expression that matches the entire path. Path variables (segments of
+// the form :name) and collection variables ({{name}}) become capture
+// groups.
+func PathPattern(segments []string) string {
+	patterns := []string{}
+	for _, segment := range segments {
+		if strings.HasPrefix(segment, ":") && len(segment) > 1 {
+			patterns = append(patterns, "([^/]+)")
+			continue
+		}
+
+		pattern := ""
+		for {
+			location := variablePattern.FindStringIndex(segment)
+			if location == nil {
+				break
+			}
+
+			pattern += regexp.QuoteMeta(segment[:location[0]]) + "([^/]+)"
+			segment = segment[location[1]:]
+		}
+
+		patterns = append(patterns, pattern+regexp.QuoteMeta(segment))
+	}
+
+	return "^/" + strings.Join(patterns, "/") + "$"
+}
@@ -0,0 +1,124 @@
+package postman
+
+import (
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/internal/payload"
+	. "github.com/onsi/gomega"
+)
+
+type ConvertSuite struct{}
+
+func (s *ConvertSuite) TestConvert(t sweet.T) {
+	handlers, err := Convert([]byte(`{
+		"info": {
+			"name": "Users",
+			"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+		},
+		"item": [
+			{
+				"name": "Users",
+				"item": [
+					{
+						"name": "Get user",
+						"request": {
+							"method": "GET",
+							"url": {"raw": "{{baseUrl}}/users/:id", "host": ["{{baseUrl}}"], "path": ["users", ":id"]}
+						},
+						"response": [
+							{
+								"name": "Found",
+								"code": 200,
+								"header": [
+									{"key": "content-type", "value": "application/json"},
+									{"key": "X-Debug", "value": "1", "disabled": true}
+								],
+								"body": "{\"name\": \"{{name}}\"}"
+							},
+							{
+								"name": "Missing",
+								"originalRequest": {
+									"method": "GET",
+									"url": "{{baseUrl}}/users/missing"
+								},
+								"code": 404,
+								"header": null
+							}
+						]
+					}
+				]
+			},
+			{
+				"name": "Create post",
+				"request": {
+					"method": "post",
+					"url": "https://api.example.com/users/{{userId}}/posts"
+				},
+				"response": [
+					{"name": "Created", "body": "created"}
+				]
+			},
+			{
+				"name": "No examples",
+				"request": {"method": "DELETE", "url": "/users/:id"}
+			}
+		]
+	}`))
+
+	Expect(err).To(BeNil())
+	Expect(handlers).To(Equal([]payload.Handler{
+		{
+			Request: payload.Request{Method: "^GET$", Path: "^/users/([^/]+)$"},
+			Response: payload.Response{
+				StatusCode: "200",
+				Headers:    map[string][]string{"Content-Type": {"application/json"}},
+				Body:       `{{"{\"name\": \"{{name}}\"}"}}`,
+			},
+		},
+		{
+			Request: payload.Request{Method: "^GET$", Path: "^/users/missing$"},
+			Response: payload.Response{
+				StatusCode: "404",
+				Headers:    map[string][]string{},
+			},
+		},
+		{
+			Request: payload.Request{Method: "^POST$", Path: "^/users/([^/]+)/posts$"},
+			Response: payload.Response{
+				StatusCode: "200",
+				Headers:    map[string][]string{},
+				Body:       "created",
+			},
+		},
+	}))
+}
+
+func (s *ConvertSuite) TestPathPattern(t sweet.T) {
+	Expect(PathPattern(nil)).To(Equal("^/$"))
+	Expect(PathPattern([]string{""})).To(Equal("^/$"))
+	Expect(PathPattern([]string{"v1.0", "users", ":id"})).To(Equal(`^/v1\.0/users/([^/]+)$`))
+	Expect(PathPattern([]string{"files", "{{name}}.{{ext}}"})).To(Equal(`^/files/([^/]+)\.([^/]+)$`))
+	Expect(PathPattern([]string{":"})).To(Equal(`^/:$`))
+}
+
+func (s *ConvertSuite) TestConvertStringRequests(t sweet.T) {
+	handlers, err := Convert([]byte(`{
+		"info": {"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+		"item": [
+			{
+				"name": "Health",
+				"request": "{{baseUrl}}/health",
+				"response": [
+					{"name": "Up", "code": 200, "body": "ok"},
+					{"name": "Down", "originalRequest": "{{baseUrl}}/health/deep", "code": 503}
+				]
+			}
+		]
+	}`))
+
+	Expect(err).To(BeNil())
+	Expect(handlers).To(HaveLen(2))
+	Expect(handlers[0].Request).To(Equal(payload.Request{Method: "^GET$", Path: "^/health$"}))
+	Expect(handlers[0].Response.StatusCode).To(Equal("200"))
+	Expect(handlers[1].Request).To(Equal(payload.Request{Method: "^GET$", Path: "^/health/deep$"}))
+	Expect(handlers[1].Response.StatusCode).To(Equal("503"))
+}
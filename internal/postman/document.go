@@ -0,0 +1,188 @@
+package postman
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type (
+	// Collection is a Postman Collection v2.1 document. Only the fields
+	// used by derision are modeled.
+	Collection struct {
+		Info  *Info   `json:"info"`
+		Items []*Item `json:"item"`
+	}
+
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	}
+
+	// Item is either a folder (with nested items) or a request with
+	// saved example responses.
+	Item struct {
+		Name      string      `json:"name"`
+		Items     []*Item     `json:"item"`
+		Request   *Request    `json:"request"`
+		Responses []*Response `json:"response"`
+	}
+
+	// Request is the request of an item or example. A collection may
+	// describe a request as a plain URL string, which implies a GET.
+	Request struct {
+		Method string `json:"method"`
+		URL    *URL   `json:"url"`
+	}
+
+	// URL is the location of a request. A collection may describe a URL
+	// either as a raw string or as an object with its parts split out.
+	URL struct {
+		Raw  string
+		Path []string
+	}
+
+	Response struct {
+		Name            string   `json:"name"`
+		OriginalRequest *Request `json:"originalRequest"`
+		Code            int      `json:"code"`
+		Headers         Headers  `json:"header"`
+		Body            string   `json:"body"`
+	}
+
+	// Headers is a list of headers. A collection may describe headers as
+	// a list of objects or as a single raw string.
+	Headers []*Header
+
+	Header struct {
+		Key      string `json:"key"`
+		Value    string `json:"value"`
+		Disabled bool   `json:"disabled"`
+	}
+)
+
+const schemaPrefix = "https://schema.getpostman.com/json/collection/v2.1"
+
+// IsDocument determines if the given JSON data looks like a Postman
+// Collection v2.1 document.
+func IsDocument(data []byte) bool {
+	collection := &struct {
+		Info *Info `json:"info"`
+	}{}
+
+	if err := json.Unmarshal(data, collection); err != nil {
+		return false
+	}
+
+	return collection.Info != nil && strings.HasPrefix(collection.Info.Schema, schemaPrefix)
+}
+
+// Parse unmarshals a Postman collection from JSON data.
+func Parse(data []byte) (*Collection, error) {
+	collection := &Collection{}
+	if err := json.Unmarshal(data, collection); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal collection (%s)", err.Error())
+	}
+
+	return collection, nil
+}
+
+// UnmarshalJSON decodes a request given as either a URL string or an object.
+func (r *Request) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		r.URL = &URL{Raw: raw}
+		return nil
+	}
+
+	type plain Request
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// UnmarshalJSON decodes a URL given as either a raw string or an object.
+// Path segments of an object may be strings or objects with a value.
+func (u *URL) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &u.Raw); err == nil {
+		return nil
+	}
+
+	object := &struct {
+		Raw  string            `json:"raw"`
+		Path []json.RawMessage `json:"path"`
+	}{}
+
+	if err := json.Unmarshal(data, object); err != nil {
+		return err
+	}
+
+	u.Raw = object.Raw
+
+	for _, raw := range object.Path {
+		segment := &struct {
+			Value string `json:"value"`
+		}{}
+
+		if err := json.Unmarshal(raw, &segment.Value); err != nil {
+			if err := json.Unmarshal(raw, segment); err != nil {
+				return err
+			}
+		}
+
+		u.Path = append(u.Path, segment.Value)
+	}
+
+	return nil
+}
+
+// UnmarshalJSON decodes a list of header objects. Raw header strings are
+// parsed as one "Key: Value" pair per line.
+func (h *Headers) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return json.Unmarshal(data, (*[]*Header)(h))
+	}
+
+	for _, line := range strings.Split(raw, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		*h = append(*h, &Header{
+			Key:   strings.TrimSpace(parts[0]),
+			Value: strings.TrimSpace(parts[1]),
+		})
+	}
+
+	return nil
+}
+
+// Segments returns the path segments of the URL. If the URL was given as
+// a raw string, the scheme, host, query string, and fragment are removed.
+func (u *URL) Segments() []string {
+	if u.Path != nil {
+		return u.Path
+	}
+
+	raw := u.Raw
+	if index := strings.IndexAny(raw, "?#"); index >= 0 {
+		raw = raw[:index]
+	}
+
+	if index := strings.Index(raw, "://"); index >= 0 {
+		raw = raw[index+3:]
+	}
+
+	// The remaining text starts with the host, which is commonly a
+	// variable such as {{baseUrl}}.
+	if !strings.HasPrefix(raw, "/") {
+		index := strings.Index(raw, "/")
+		if index < 0 {
+			return nil
+		}
+
+		raw = raw[index:]
+	}
+
+	return strings.Split(strings.TrimPrefix(raw, "/"), "/")
+}
@@ -0,0 +1,56 @@
+package postman
+
+import (
+	"encoding/json"
+
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type DocumentSuite struct{}
+
+func (s *DocumentSuite) TestIsDocument(t sweet.T) {
+	Expect(IsDocument([]byte(`{"info": {"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"}}`))).To(BeTrue())
+	Expect(IsDocument([]byte(`{"info": {"schema": "https://schema.getpostman.com/json/collection/v2.0.0/collection.json"}}`))).To(BeFalse())
+	Expect(IsDocument([]byte(`{"openapi": "3.0.0"}`))).To(BeFalse())
+	Expect(IsDocument([]byte(`[]`))).To(BeFalse())
+}
+
+func (s *DocumentSuite) TestURLSegments(t sweet.T) {
+	for raw, expected := range map[string][]string{
+		`"https://api.example.com/users/:id?expand=true"`: {"users", ":id"},
+		`"{{baseUrl}}/users/{{userId}}/posts"`:            {"users", "{{userId}}", "posts"},
+		`"/health#top"`:                                   {"health"},
+		`"{{baseUrl}}"`:                                   nil,
+		`{"raw": "{{baseUrl}}/x", "path": ["users", {"type": "string", "value": ":id"}]}`: {"users", ":id"},
+	} {
+		u := &URL{}
+		Expect(json.Unmarshal([]byte(raw), u)).To(BeNil())
+		Expect(u.Segments()).To(Equal(expected))
+	}
+}
+
+func (s *DocumentSuite) TestHeaders(t sweet.T) {
+	headers := Headers{}
+	Expect(json.Unmarshal([]byte(`"Content-Type: text/plain\nX-Foo: a:b\n"`), &headers)).To(BeNil())
+	Expect(headers).To(Equal(Headers{
+		{Key: "Content-Type", Value: "text/plain"},
+		{Key: "X-Foo", Value: "a:b"},
+	}))
+
+	headers = Headers{}
+	Expect(json.Unmarshal([]byte(`[{"key": "X-Bar", "value": "baz", "disabled": true}]`), &headers)).To(BeNil())
+	Expect(headers).To(Equal(Headers{{Key: "X-Bar", Value: "baz", Disabled: true}}))
+}
+
+func (s *DocumentSuite) TestRequestString(t sweet.T) {
+	r := &Request{}
+	Expect(json.Unmarshal([]byte(`"{{baseUrl}}/users/:id"`), r)).To(BeNil())
+	Expect(r.Method).To(BeEmpty())
+	Expect(r.URL.Segments()).To(Equal([]string{"users", ":id"}))
+
+	r = &Request{}
+	Expect(json.Unmarshal([]byte(`{"method": "POST", "url": "/users"}`), r)).To(BeNil())
+	Expect(r.Method).To(Equal("POST"))
+	Expect(r.URL.Segments()).To(Equal([]string{"users"}))
+}
@@ -8,22 +8,49 @@ type (
 		Copy(clear bool) []*Request
 		Add(request *Request)
 		Clear()
+		Close()
 	}
 
 	log struct {
 		capacity     int
+		maxBytes     int
+		maxBodySize  int
+		size         int
 		requestChan  chan *Request
 		requestSlice []*Request
+		closed       bool
 		mutex        sync.RWMutex
 	}
+
+	LogConfigFunc func(*log)
 )
 
-func NewLog(capacity int) *log {
-	return &log{
+func NewLog(capacity int, configs ...LogConfigFunc) *log {
+	l := &log{
 		capacity:     capacity,
 		requestChan:  make(chan *Request),
 		requestSlice: []*Request{},
 	}
+
+	for _, f := range configs {
+		f(l)
+	}
+
+	return l
+}
+
+// WithMaxBytes bounds the total size of the bodies held by the log. The
+// oldest requests are pushed out of the log once the bound is exceeded.
+// A value of zero is unbounded.
+func WithMaxBytes(maxBytes int) LogConfigFunc {
+	return func(l *log) { l.maxBytes = maxBytes }
+}
+
+// WithMaxBodySize truncates the request and response bodies of each request
+// added to the log to the given number of bytes. A value of zero does not
+// truncate bodies.
+func WithMaxBodySize(maxBodySize int) LogConfigFunc {
+	return func(l *log) { l.maxBodySize = maxBodySize }
 }
 
 func (l *log) Chan() <-chan *Request {
@@ -31,8 +58,13 @@ func (l *log) Chan() <-chan *Request {
 }
 
 func (l *log) Copy(clear bool) []*Request {
-	l.mutex.RLock()
-	defer l.mutex.RUnlock()
+	if clear {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+	} else {
+		l.mutex.RLock()
+		defer l.mutex.RUnlock()
+	}
 
 	requests := []*Request{}
 	for _, request := range l.requestSlice {
@@ -47,21 +79,35 @@ func (l *log) Copy(clear bool) []*Request {
 }
 
 func (l *log) Add(request *Request) {
+	request.truncate(l.maxBodySize)
+
 	l.mutex.Lock()
-	l.requestChan <- request
+	if !l.closed {
+		l.requestChan <- request
+	}
+
 	l.requestSlice = append(l.requestSlice, request)
+	l.size += request.size()
 	l.prune()
 	l.mutex.Unlock()
 }
 
 func (l *log) prune() {
-	if l.capacity == 0 {
-		return
+	for l.exceedsCapacity() {
+		// Release the evicted request, which the backing array would
+		// otherwise retain until the slice is reallocated
+		l.size -= l.requestSlice[0].size()
+		l.requestSlice[0] = nil
+		l.requestSlice = l.requestSlice[1:]
 	}
+}
 
-	for len(l.requestSlice) > l.capacity {
-		l.requestSlice = l.requestSlice[1:]
+func (l *log) exceedsCapacity() bool {
+	if l.capacity != 0 && len(l.requestSlice) > l.capacity {
+		return true
 	}
+
+	return l.maxBytes != 0 && l.size > l.maxBytes && len(l.requestSlice) > 0
 }
 
 func (l *log) Clear() {
@@ -70,6 +116,19 @@ func (l *log) Clear() {
 	l.mutex.Unlock()
 }
 
+// Close closes the channel of added requests so that its reader can stop.
+// Requests added afterwards are still logged.
+func (l *log) Close() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.closed {
+		l.closed = true
+		close(l.requestChan)
+	}
+}
+
 func (l *log) clear() {
-	l.requestSlice = l.requestSlice[:0]
+	l.requestSlice = []*Request{}
+	l.size = 0
 }
@@ -47,6 +47,40 @@ func (s *LogSuite) TestCapacity(t sweet.T) {
 	}))
 }
 
+func (s *LogSuite) TestMaxBytes(t sweet.T) {
+	log := NewLog(0, WithMaxBytes(10))
+	go readAll(log)
+
+	log.Add(&Request{Path: "1", Body: "aaaa"})
+	log.Add(&Request{Path: "2", Body: "bbbb"})
+	log.Add(&Request{Path: "3", Body: "cc", Response: &Response{Body: "dd"}})
+
+	Expect(log.Copy(false)).To(Equal([]*Request{
+		&Request{Path: "2", Body: "bbbb"},
+		&Request{Path: "3", Body: "cc", Response: &Response{Body: "dd"}},
+	}))
+
+	// Clearing the log releases its size
+	log.Clear()
+	log.Add(&Request{Path: "4", Body: "eeeeeeeeee"})
+	Expect(log.Copy(false)).To(HaveLen(1))
+}
+
+func (s *LogSuite) TestMaxBodySize(t sweet.T) {
+	log := NewLog(0, WithMaxBodySize(3))
+	go readAll(log)
+
+	log.Add(&Request{Body: "foobar", Response: &Response{Body: "baz"}})
+
+	Expect(log.Copy(false)).To(Equal([]*Request{
+		&Request{
+			Body:          "foo",
+			BodyTruncated: true,
+			Response:      &Response{Body: "baz"},
+		},
+	}))
+}
+
 func (s *LogSuite) TestClear(t sweet.T) {
 	log := NewLog(5)
 	go readAll(log)
@@ -64,6 +98,34 @@ func (s *LogSuite) TestClear(t sweet.T) {
 	}))
 }
 
+func (s *LogSuite) TestCopyClearConcurrently(t sweet.T) {
+	log := NewLog(0, WithMaxBytes(1000000))
+	go readAll(log)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 1000; i++ {
+			log.Add(&Request{Body: "foo"})
+		}
+	}()
+
+	copied := 0
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+
+		copied += len(log.Copy(true))
+	}
+
+	// Each request is copied exactly once
+	Expect(copied).To(Equal(1000))
+}
+
 func (s *LogSuite) TestChan(t sweet.T) {
 	log := NewLog(5)
 
@@ -87,6 +149,28 @@ func (s *LogSuite) TestChan(t sweet.T) {
 	Expect(chanRequests).To(Equal(requests))
 }
 
+func (s *LogSuite) TestClose(t sweet.T) {
+	log := NewLog(5)
+
+	done := make(chan struct{})
+	go func() {
+		readAll(log)
+		close(done)
+	}()
+
+	log.Add(&Request{Path: "/foo"})
+	log.Close()
+	log.Close()
+	Eventually(done).Should(BeClosed())
+
+	// Requests are still logged once the channel is closed
+	log.Add(&Request{Path: "/bar"})
+	Expect(log.Copy(false)).To(Equal([]*Request{
+		&Request{Path: "/foo"},
+		&Request{Path: "/bar"},
+	}))
+}
+
 func readAll(log Log) {
 	for range log.Chan() {
 	}
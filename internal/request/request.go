@@ -1,12 +1,231 @@
 package request
 
-type Request struct {
-	Method   string              `json:"method"`
-	Path     string              `json:"path"`
-	Headers  map[string][]string `json:"headers"`
-	Body     string              `json:"body"`
-	RawBody  string              `json:"raw_body"`
-	Form     map[string][]string `json:"form"`
-	Files    map[string]string   `json:"files"`
-	RawFiles map[string]string   `json:"raw_files"`
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+type (
+	// Request is a request received by the mock API. The RawBody, Files, and
+	// RawFiles fields are not stored, but are derived from Body and Parts when
+	// the request is serialized.
+	Request struct {
+		Method   string              `json:"method"`
+		Host     string              `json:"host"`
+		Path     string              `json:"path"`
+		Query    map[string][]string `json:"query"`
+		Headers  map[string][]string `json:"headers"`
+		Body     string              `json:"body"`
+		RawBody  string              `json:"raw_body"`
+		Form     map[string][]string `json:"form"`
+		Files    map[string]string   `json:"files"`
+		RawFiles map[string]string   `json:"raw_files"`
+
+		// Parts lists each part of a multipart/form-data body in the order
+		// in which they were sent. Files (keyed by filename) holds only the
+		// last of the file parts that share a filename.
+		Parts []*Part `json:"parts,omitempty"`
+
+		// BodyTruncated is true if the body was truncated when the request
+		// was added to the request log.
+		BodyTruncated bool `json:"body_truncated,omitempty"`
+
+		// Encoding is the content encoding (gzip or deflate) that was removed
+		// from the body before it was matched and recorded, if any.
+		Encoding string `json:"encoding,omitempty"`
+
+		// Binary is true if the body is not valid UTF-8. The body field of a
+		// binary request is not faithful once serialized, so the raw body
+		// should be used instead.
+		Binary bool `json:"binary"`
+
+		// BodySize and BodySHA256 are the length and the hex-encoded SHA-256
+		// digest of the (decoded) body.
+		BodySize   int    `json:"body_size"`
+		BodySHA256 string `json:"body_sha256"`
+
+		// Violations holds the ways in which the request does not conform
+		// to the API contract, if one is configured.
+		Violations []string `json:"violations,omitempty"`
+
+		// Timestamp is the time at which the request was received.
+		Timestamp time.Time `json:"timestamp"`
+
+		// Expectation is the expectation that matched the request, if any.
+		Expectation *Expectation `json:"expectation,omitempty"`
+
+		// Response is the response sent to the client.
+		Response *Response `json:"response,omitempty"`
+	}
+
+	Expectation struct {
+		Method  string                   `json:"method,omitempty"`
+		Path    string                   `json:"path,omitempty"`
+		Query   map[string]string        `json:"query,omitempty"`
+		Headers map[string]HeaderPattern `json:"headers,omitempty"`
+		Body    string                   `json:"body,omitempty"`
+		Tags    []string                 `json:"tags,omitempty"`
+
+		// BodyEncoding is the encoding (hex or base64) of the body to which
+		// the body pattern was applied, if any.
+		BodyEncoding string `json:"body_encoding,omitempty"`
+
+		// Fallback is true if the expectation is a fallback, which is only
+		// consulted when no other expectation matches.
+		Fallback bool `json:"fallback,omitempty"`
+	}
+
+	// HeaderPattern constrains the values of a request header. In a payload,
+	// it is either a pattern that the first value must match, or an object
+	// with the fields below.
+	HeaderPattern struct {
+		Pattern string `json:"pattern,omitempty"`
+
+		// Match selects the values that must match the pattern: first (the
+		// default), any, or all.
+		Match string `json:"match,omitempty"`
+
+		// Index selects a single value, by position, that must match the
+		// pattern instead.
+		Index *int `json:"index,omitempty"`
+
+		// Absent requires that the header is not sent at all.
+		Absent bool `json:"absent,omitempty"`
+	}
+
+	// Part is a part of a multipart/form-data body. Filename is empty for
+	// parts that are not files. As with a request, RawContent is derived
+	// when the part is serialized.
+	Part struct {
+		Name        string              `json:"name"`
+		Filename    string              `json:"filename,omitempty"`
+		ContentType string              `json:"content_type,omitempty"`
+		Headers     map[string][]string `json:"headers"`
+		Content     string              `json:"content"`
+		RawContent  string              `json:"raw_content"`
+
+		// Size and SHA256 are the length and the hex-encoded SHA-256
+		// digest of the (untruncated) content.
+		Size   int    `json:"size"`
+		SHA256 string `json:"sha256"`
+
+		// Truncated is true if the content was truncated when the request
+		// was received or when it was added to the request log.
+		Truncated bool `json:"truncated,omitempty"`
+	}
+
+	// Response is the response sent for a request. As with a request, the
+	// RawBody field is derived when the response is serialized.
+	Response struct {
+		StatusCode int                 `json:"status_code"`
+		Headers    map[string][]string `json:"headers"`
+		Body       string              `json:"body"`
+		RawBody    string              `json:"raw_body"`
+		ElapsedMs  float64             `json:"elapsed_ms"`
+
+		// BodyTruncated is true if the body was truncated when the request
+		// was added to the request log.
+		BodyTruncated bool `json:"body_truncated,omitempty"`
+	}
+)
+
+// MarshalJSON derives the raw (base64-encoded) body and the file contents of
+// the request. They are not stored so that each body is held only once.
+func (r Request) MarshalJSON() ([]byte, error) {
+	type plain Request
+	p := plain(r)
+	p.RawBody = encode(r.Body)
+	p.Files = map[string]string{}
+	p.RawFiles = map[string]string{}
+
+	for _, part := range r.Parts {
+		if part.Filename != "" {
+			p.Files[part.Filename] = part.Content
+			p.RawFiles[part.Filename] = encode(part.Content)
+		}
+	}
+
+	return json.Marshal(p)
+}
+
+// UnmarshalJSON accepts either a pattern or an object.
+func (p *HeaderPattern) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &p.Pattern); err == nil {
+		return nil
+	}
+
+	type plain HeaderPattern
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+// MarshalJSON serializes a pattern that matches the first value as a string.
+func (p HeaderPattern) MarshalJSON() ([]byte, error) {
+	if p.Match == "" && p.Index == nil && !p.Absent {
+		return json.Marshal(p.Pattern)
+	}
+
+	type plain HeaderPattern
+	return json.Marshal(plain(p))
+}
+
+// MarshalJSON derives the raw (base64-encoded) content of the part.
+func (p Part) MarshalJSON() ([]byte, error) {
+	type plain Part
+	pp := plain(p)
+	pp.RawContent = encode(p.Content)
+	return json.Marshal(pp)
+}
+
+// MarshalJSON derives the raw (base64-encoded) body of the response.
+func (r Response) MarshalJSON() ([]byte, error) {
+	type plain Response
+	p := plain(r)
+	p.RawBody = encode(r.Body)
+	return json.Marshal(p)
+}
+
+// truncate shortens the body of the request and of its response to at most
+// the given number of bytes. A limit of zero does not truncate bodies.
+func (r *Request) truncate(limit int) {
+	if limit == 0 {
+		return
+	}
+
+	if len(r.Body) > limit {
+		// Copy the prefix so the full body can be released
+		r.Body = string([]byte(r.Body[:limit]))
+		r.BodyTruncated = true
+	}
+
+	for _, part := range r.Parts {
+		if len(part.Content) > limit {
+			part.Content = string([]byte(part.Content[:limit]))
+			part.Truncated = true
+		}
+	}
+
+	if r.Response != nil && len(r.Response.Body) > limit {
+		r.Response.Body = string([]byte(r.Response.Body[:limit]))
+		r.Response.BodyTruncated = true
+	}
+}
+
+// size approximates the memory held by the request as the total length of
+// its request body, part contents, and response body.
+func (r *Request) size() int {
+	size := len(r.Body)
+	for _, part := range r.Parts {
+		size += len(part.Content)
+	}
+
+	if r.Response != nil {
+		size += len(r.Response.Body)
+	}
+
+	return size
+}
+
+func encode(raw string) string {
+	return base64.StdEncoding.EncodeToString([]byte(raw))
 }
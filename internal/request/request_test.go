@@ -0,0 +1,31 @@
+package request
+
+import (
+	"encoding/json"
+
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type RequestSuite struct{}
+
+func (s *RequestSuite) TestMarshalRawBodies(t sweet.T) {
+	data, err := json.Marshal(&Request{
+		Body: "foo",
+		Parts: []*Part{
+			{Name: "upload", Filename: "a.txt", Content: "bar"},
+			{Name: "title", Content: "qux"},
+		},
+		Response: &Response{Body: "baz"},
+	})
+
+	Expect(err).To(BeNil())
+
+	payload := map[string]interface{}{}
+	Expect(json.Unmarshal(data, &payload)).To(BeNil())
+	Expect(payload["raw_body"]).To(Equal("Zm9v"))
+	Expect(payload["files"]).To(Equal(map[string]interface{}{"a.txt": "bar"}))
+	Expect(payload["raw_files"]).To(Equal(map[string]interface{}{"a.txt": "YmFy"}))
+	Expect(payload["parts"].([]interface{})[1].(map[string]interface{})["raw_content"]).To(Equal("cXV4"))
+	Expect(payload["response"].(map[string]interface{})["raw_body"]).To(Equal("YmF6"))
+}
@@ -0,0 +1,81 @@
+package schema
+
+// handlerSchema is the JSON schema (in YAML format) of a handler payload, the
+// body of the /register endpoint and each entry of a static configuration file.
+const handlerSchema = `
+type: object
+properties:
+  request:
+    type: object
+    properties:
+      method:
+        type: string
+      path:
+        type: string
+      query:
+        type: object
+        additionalProperties:
+          type: string
+      headers:
+        type: object
+        additionalProperties:
+          oneOf:
+            - type: string
+            - type: object
+              properties:
+                pattern:
+                  type: string
+                match:
+                  type: string
+                  enum:
+                    - first
+                    - any
+                    - all
+                index:
+                  type: integer
+                  minimum: 0
+                absent:
+                  type: boolean
+              additionalProperties: false
+      body:
+        type: string
+      body_encoding:
+        type: string
+        enum:
+          - hex
+          - base64
+    additionalProperties: false
+  response:
+    type: object
+    properties:
+      status_code:
+        type: string
+        pattern: ^\d{3}$
+      headers:
+        type: object
+      body:
+        type: string
+      body_file:
+        type: string
+      body_file_template:
+        type: boolean
+      body_base64:
+        type: string
+    additionalProperties: false
+  priority:
+    type: integer
+  position:
+    type: string
+    enum:
+      - first
+  fallback:
+    type: boolean
+  tags:
+    type: array
+    items:
+      type: string
+additionalProperties: false
+required:
+  - request
+  - response
+`
@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Load returns the schema of a single handler payload and the schema of a
+// list of handler payloads, which is derived from the former. If path is
+// non-empty, the handler schema is read from that file (in YAML or JSON
+// format) instead of the schema compiled into the binary.
+func Load(path string) (*gojsonschema.Schema, *gojsonschema.Schema, error) {
+	content := []byte(handlerSchema)
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read schema (%s)", err.Error())
+		}
+
+		content = data
+	}
+
+	data, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse schema (%s)", err.Error())
+	}
+
+	item := map[string]interface{}{}
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse schema (%s)", err.Error())
+	}
+
+	handler, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(item))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid schema (%s)", err.Error())
+	}
+
+	handlers, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(deriveList(item)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid schema (%s)", err.Error())
+	}
+
+	return handler, handlers, nil
+}
+
+// deriveList creates a schema of a list whose items conform to the given
+// schema. Definitions are hoisted to the root of the new schema so that
+// local references to them continue to resolve.
+func deriveList(item map[string]interface{}) map[string]interface{} {
+	copied := map[string]interface{}{}
+	for key, value := range item {
+		copied[key] = value
+	}
+
+	list := map[string]interface{}{
+		"type":  "array",
+		"items": copied,
+	}
+
+	if definitions, ok := copied["definitions"]; ok {
+		list["definitions"] = definitions
+		delete(copied, "definitions")
+	}
+
+	return list
+}
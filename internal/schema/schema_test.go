@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+type SchemaSuite struct{}
+
+func validate(schema *gojsonschema.Schema, document string) []string {
+	result, err := schema.Validate(gojsonschema.NewStringLoader(document))
+	Expect(err).To(BeNil())
+
+	errors := []string{}
+	for _, err := range result.Errors() {
+		errors = append(errors, err.Field()+": "+err.Description())
+	}
+
+	return errors
+}
+
+func (s *SchemaSuite) TestLoad(t sweet.T) {
+	handler, handlers, err := Load("")
+	Expect(err).To(BeNil())
+
+	Expect(validate(handler, `{"request": {"path": "/a"}, "response": {"status_code": "200"}}`)).To(BeEmpty())
+	Expect(validate(handler, `{"request": {}}`)).To(ConsistOf("(root): response is required"))
+	Expect(validate(handler, `[]`)).To(ConsistOf("(root): Invalid type. Expected: object, given: array"))
+	Expect(validate(handler, `{"request": {"headers": {"Accept": "json", "X-A": {"pattern": "a", "match": "all"}, "X-B": {"absent": true}}}, "response": {}}`)).To(BeEmpty())
+	Expect(validate(handler, `{"request": {"headers": {"X-A": {"match": "some"}}}, "response": {}}`)).NotTo(BeEmpty())
+
+	Expect(validate(handlers, `[{"request": {}, "response": {}}]`)).To(BeEmpty())
+	Expect(validate(handlers, `[{"request": {}, "response": {}}, {"request": {}}]`)).To(ConsistOf("1: response is required"))
+	Expect(validate(handlers, `{}`)).To(ConsistOf("(root): Invalid type. Expected: array, given: object"))
+}
+
+func (s *SchemaSuite) TestLoadOverride(t sweet.T) {
+	handler, handlers, err := Load("./tests/override.yaml")
+	Expect(err).To(BeNil())
+
+	Expect(validate(handler, `{"request": {"path": "/a"}}`)).To(BeEmpty())
+	Expect(validate(handler, `{"request": {}}`)).To(ConsistOf("request: path is required"))
+	Expect(validate(handlers, `[{"request": {"path": "/a"}}, {"request": {}}]`)).To(ConsistOf("1.request: path is required"))
+}
+
+func (s *SchemaSuite) TestLoadMissing(t sweet.T) {
+	_, _, err := Load("./tests/missing.yaml")
+	Expect(err).To(MatchError(HavePrefix("failed to read schema")))
+}
+
+func (s *SchemaSuite) TestLoadInvalid(t sweet.T) {
+	_, _, err := Load("./tests/scalar.yaml")
+	Expect(err).To(MatchError(HavePrefix("failed to parse schema")))
+}
@@ -1,6 +1,79 @@
 package server
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/efritz/derision/internal/handler"
+)
+
 type Config struct {
-	ConfigDir          string `env:"config_dir"`
-	RequestLogCapacity int    `env:"request_log_capacity" default:"0"`
+	ConfigDir             string `env:"config_dir"`
+	RawConfigPollInterval int    `env:"config_poll_interval" default:"2"`
+	SchemaPath            string `env:"schema_path"`
+	RequestLogCapacity    int    `env:"request_log_capacity" default:"0"`
+	RequestLogMaxBytes    int    `env:"request_log_max_bytes" default:"0"`
+	MaxBodySize           int    `env:"max_body_size" default:"10000000"`
+	MaxPartSize           int    `env:"max_part_size" default:"1000000"`
+	MaxLoggedBodySize     int    `env:"max_logged_body_size" default:"0"`
+	RawTieBreak           string `env:"priority_tie_break" default:"order"`
+	ContractPath          string `env:"contract_path"`
+	RawContractMode       string `env:"contract_mode" default:"record"`
+
+	ConfigPollInterval time.Duration
+	TieBreak           handler.TieBreak
+	RejectsInvalid     bool
+}
+
+const (
+	// DefaultMaxBodySize is the default size of the largest request body
+	// that is accepted.
+	DefaultMaxBodySize = 10000000
+
+	// DefaultMaxPartSize is the default number of bytes of the content of
+	// each multipart part that are kept.
+	DefaultMaxPartSize = 1000000
+)
+
+var tieBreaks = map[string]handler.TieBreak{
+	"order":   handler.TieBreakOrder,
+	"recency": handler.TieBreakRecency,
+}
+
+func (c *Config) PostLoad() error {
+	tieBreak, ok := tieBreaks[c.RawTieBreak]
+	if !ok {
+		return fmt.Errorf("illegal priority tie break %s (expected order or recency)", c.RawTieBreak)
+	}
+
+	if c.RawContractMode != "record" && c.RawContractMode != "reject" {
+		return fmt.Errorf("illegal contract mode %s (expected record or reject)", c.RawContractMode)
+	}
+
+	if c.RawConfigPollInterval < 0 {
+		return fmt.Errorf("illegal config poll interval %d (expected a non-negative number of seconds)", c.RawConfigPollInterval)
+	}
+
+	for name, value := range map[string]int{
+		"request log max bytes": c.RequestLogMaxBytes,
+		"max body size":         c.MaxBodySize,
+		"max part size":         c.MaxPartSize,
+		"max logged body size":  c.MaxLoggedBodySize,
+	} {
+		if value < 0 {
+			return fmt.Errorf("illegal %s %d (expected a non-negative number of bytes)", name, value)
+		}
+	}
+
+	c.ConfigPollInterval = time.Duration(c.RawConfigPollInterval) * time.Second
+	c.TieBreak = tieBreak
+	c.RejectsInvalid = c.RawContractMode == "reject"
+	return nil
+}
+
+func (c *Config) bodyLimits() bodyLimits {
+	return bodyLimits{
+		maxBodySize: int64(c.MaxBodySize),
+		maxPartSize: int64(c.MaxPartSize),
+	}
 }
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/efritz/derision/internal/openapi"
+)
+
+// Contract validates requests received by the mock server against an
+// OpenAPI document. Invalid requests are always recorded with a list of
+// violations, and are rejected outright when RejectsInvalid is set.
+type Contract struct {
+	Validator      *openapi.Validator
+	RejectsInvalid bool
+}
+
+func loadValidator(path string) (*openapi.Validator, error) {
+	data, err := loadYAML(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load contract (%s)", err.Error())
+	}
+
+	validator, err := openapi.NewValidator(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load contract (%s)", err.Error())
+	}
+
+	return validator, nil
+}
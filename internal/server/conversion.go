@@ -2,91 +2,231 @@ package server
 
 import (
 	"bytes"
-	"encoding/base64"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"mime"
+	"mime/multipart"
 	"net/http"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/efritz/derision/internal/request"
 )
 
-func convertRequest(r *http.Request) (*request.Request, error) {
+// bodyLimits bounds the memory used to convert a request. The body of a
+// request (before and after decoding) may not exceed the maximum body size,
+// and only the maximum part size of the content of each multipart part is
+// kept. A limit of zero is unlimited.
+type bodyLimits struct {
+	maxBodySize int64
+	maxPartSize int64
+}
+
+// errBodyTooLarge is returned when the (decoded) body of a request exceeds
+// the maximum body size.
+var errBodyTooLarge = fmt.Errorf("request body too large")
+
+func convertRequest(r *http.Request, limits bodyLimits) (*request.Request, error) {
 	defer r.Body.Close()
-	buffer := &bytes.Buffer{}
-	r.Body = ioutil.NopCloser(io.TeeReader(r.Body, buffer))
 
-	if err := tryParse(r); err != nil {
-		return nil, fmt.Errorf("failed to parse form (%s)", err.Error())
+	content, err := readLimited(r.Body, limits.maxBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compressed bodies are matched (and recorded) in their decoded form. A
+	// body that cannot be decoded is kept as it was sent.
+	decoded, encoding, err := decodeBody(r.Header.Get("Content-Encoding"), content, limits.maxBodySize)
+	if err != nil {
+		return nil, err
 	}
 
-	files, rawFiles, err := parseFiles(r)
+	if decoded != nil {
+		content = decoded
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(content))
+
+	parts, err := parseForm(r, content, limits.maxPartSize)
 	if err != nil {
 		return nil, err
 	}
 
 	snapshot := &request.Request{
-		Method:   r.Method,
-		Path:     r.URL.Path,
-		Headers:  r.Header,
-		Body:     buffer.String(),
-		RawBody:  encode(buffer.String()),
-		Form:     r.Form,
-		Files:    files,
-		RawFiles: rawFiles,
+		Method:     r.Method,
+		Host:       r.Host,
+		Path:       r.URL.Path,
+		Query:      r.URL.Query(),
+		Headers:    r.Header,
+		Body:       string(content),
+		Encoding:   encoding,
+		Binary:     !utf8.Valid(content),
+		BodySize:   len(content),
+		BodySHA256: digest(content),
+		Form:       r.Form,
+		Parts:      parts,
 	}
 
 	return snapshot, nil
 }
 
-func tryParse(r *http.Request) error {
-	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
-	if ct == "multipart/form-data" {
-		return r.ParseMultipartForm(2e7)
+// readLimited reads the given reader in full, or returns errBodyTooLarge if
+// there are more than max bytes. A max of zero is unlimited.
+func readLimited(r io.Reader, max int64) ([]byte, error) {
+	if max > 0 {
+		r = io.LimitReader(r, max+1)
 	}
 
-	if err := r.ParseForm(); err != nil {
-		return err
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body (%s)", err.Error())
 	}
 
-	if _, err := ioutil.ReadAll(r.Body); err != nil {
-		return err
+	if max > 0 && int64(len(content)) > max {
+		return nil, errBodyTooLarge
 	}
 
-	return nil
+	return content, nil
 }
 
-func parseFiles(r *http.Request) (map[string]string, map[string]string, error) {
-	files := map[string]string{}
-	rawFiles := map[string]string{}
+// decodeBody removes the given content encoding from the body and returns
+// the decoded body along with the name of the removed encoding. A nil body
+// is returned if the encoding is not supported (only gzip and deflate are)
+// or the body could not be decoded. The decoded body is subject to the same
+// maximum size as the encoded body.
+func decodeBody(contentEncoding string, content []byte, max int64) ([]byte, string, error) {
+	name := strings.ToLower(strings.TrimSpace(contentEncoding))
+	if len(content) == 0 {
+		return nil, "", nil
+	}
+
+	var reader io.ReadCloser
+	var err error
+
+	switch name {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(content))
+
+	case "deflate":
+		// Deflate is specified as a zlib stream, but some clients send
+		// a raw deflate stream instead
+		if reader, err = zlib.NewReader(bytes.NewReader(content)); err != nil {
+			reader, err = flate.NewReader(bytes.NewReader(content)), nil
+		}
+
+	default:
+		return nil, "", nil
+	}
 
-	if r.MultipartForm == nil {
-		return files, rawFiles, nil
+	if err != nil {
+		return nil, "", nil
 	}
 
-	for _, headers := range r.MultipartForm.File {
-		for _, header := range headers {
-			file, err := header.Open()
-			if err != nil {
-				return nil, nil, fmt.Errorf("failed to open file header (%s)", err.Error())
-			}
+	defer reader.Close()
 
-			defer file.Close()
+	decoded, err := readLimited(reader, max)
+	if err != nil {
+		if err == errBodyTooLarge {
+			return nil, "", err
+		}
 
-			content, err := ioutil.ReadAll(file)
-			if err != nil {
-				return nil, nil, fmt.Errorf("failed to read file (%s)", err.Error())
-			}
+		return nil, "", nil
+	}
+
+	return decoded, name, nil
+}
 
-			files[header.Filename] = string(content)
-			rawFiles[header.Filename] = encode(string(content))
+// parseForm populates the form of the request from its query and its body,
+// and returns the parts of a multipart/form-data body. A multipart body is
+// read once: the values of its parts that are not files are added to the
+// form (truncated to the maximum part size, as the parts are).
+func parseForm(r *http.Request, content []byte, maxPartSize int64) ([]*request.Part, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("failed to parse form (%s)", err.Error())
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return []*request.Part{}, nil
+	}
+
+	if params["boundary"] == "" {
+		return nil, fmt.Errorf("failed to parse form (%s)", http.ErrMissingBoundary.Error())
+	}
+
+	parts, err := parseParts(params["boundary"], content, maxPartSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, part := range parts {
+		if part.Filename == "" {
+			r.Form[part.Name] = append(r.Form[part.Name], part.Content)
 		}
 	}
 
-	return files, rawFiles, nil
+	return parts, nil
+}
+
+// parseParts returns each part of a multipart/form-data body with the given
+// boundary in the order in which they were sent. Only the first max bytes of
+// the content of each part are kept (unless max is zero), but the size and
+// digest of a part describe its full content.
+func parseParts(boundary string, content []byte, max int64) ([]*request.Part, error) {
+	parts := []*request.Part{}
+	reader := multipart.NewReader(bytes.NewReader(content), boundary)
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read multipart body (%s)", err.Error())
+		}
+
+		hash := sha256.New()
+		partReader := io.TeeReader(part, hash)
+
+		limitedReader := partReader
+		if max > 0 {
+			limitedReader = io.LimitReader(partReader, max)
+		}
+
+		partContent, err := ioutil.ReadAll(limitedReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read part (%s)", err.Error())
+		}
+
+		rest, err := io.Copy(ioutil.Discard, partReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read part (%s)", err.Error())
+		}
+
+		parts = append(parts, &request.Part{
+			Name:        part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Headers:     part.Header,
+			Content:     string(partContent),
+			Size:        len(partContent) + int(rest),
+			SHA256:      hex.EncodeToString(hash.Sum(nil)),
+			Truncated:   rest > 0,
+		})
+	}
+
+	return parts, nil
 }
 
-func encode(raw string) string {
-	return base64.StdEncoding.EncodeToString([]byte(raw))
+// digest returns the hex-encoded SHA-256 digest of the given content.
+func digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
 }
@@ -2,8 +2,13 @@ package server
 
 import (
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/aphistic/sweet"
 	"github.com/efritz/derision/internal/request"
@@ -12,6 +17,8 @@ import (
 
 type ConversionSuite struct{}
 
+var testLimits = bodyLimits{maxBodySize: DefaultMaxBodySize, maxPartSize: DefaultMaxPartSize}
+
 var multipartBody = `
 --xxx
 Content-Disposition: form-data; name="field1"
@@ -38,20 +45,22 @@ func (s *ConversionSuite) TestConvertSimple(t sweet.T) {
 	r.Header.Add("X-Bar", "baz")
 	r.Header.Add("X-Bar", "bonk")
 
-	converted, err := convertRequest(r)
+	converted, err := convertRequest(r, testLimits)
 	Expect(err).To(BeNil())
 	Expect(converted).To(Equal(&request.Request{
 		Method: "POST",
+		Host:   "test.io",
 		Path:   "/path",
+		Query:  map[string][]string{},
 		Headers: map[string][]string{
 			"X-Foo": []string{"bar"},
 			"X-Bar": []string{"baz", "bonk"},
 		},
-		Body:     "foo\nbar\nbaz\n",
-		RawBody:  "Zm9vCmJhcgpiYXoK",
-		Form:     map[string][]string{},
-		Files:    map[string]string{},
-		RawFiles: map[string]string{},
+		Body:       "foo\nbar\nbaz\n",
+		BodySize:   12,
+		BodySHA256: "b1b113c6ed8ab3a14779f7c54179eac2b87d39fcebbf65a50556b8d68caaa2fb",
+		Form:       map[string][]string{},
+		Parts:      []*request.Part{},
 	}))
 }
 
@@ -64,25 +73,30 @@ func (s *ConversionSuite) TestConvertForm(t sweet.T) {
 	r.Header.Add("X-Bar", "bonk")
 	r.Header.Set("Content-Type", "application/x-www-form-urlencoded; param=value")
 
-	converted, err := convertRequest(r)
+	converted, err := convertRequest(r, testLimits)
 	Expect(err).To(BeNil())
 	Expect(converted).To(Equal(&request.Request{
 		Method: "POST",
+		Host:   "test.io",
 		Path:   "/path",
+		Query: map[string][]string{
+			"q":    []string{"foo", "bar"},
+			"both": []string{"x"},
+		},
 		Headers: map[string][]string{
 			"X-Foo":        []string{"bar"},
 			"X-Bar":        []string{"baz", "bonk"},
 			"Content-Type": []string{"application/x-www-form-urlencoded; param=value"},
 		},
-		Body:    "z=post&both=y",
-		RawBody: "ej1wb3N0JmJvdGg9eQ==",
+		Body:       "z=post&both=y",
+		BodySize:   13,
+		BodySHA256: "f063c396ec9103ff1eb738fb611ffca3e6f45ec7ee9ba33ca57c242d37577146",
 		Form: map[string][]string{
 			"q":    []string{"foo", "bar"},
 			"z":    []string{"post"},
 			"both": []string{"y", "x"},
 		},
-		Files:    map[string]string{},
-		RawFiles: map[string]string{},
+		Parts: []*request.Part{},
 	}))
 }
 
@@ -100,18 +114,21 @@ func (s *ConversionSuite) TestConvertMultipartForm(t sweet.T) {
 	r.Form.Add("y", "456")
 	r.Form.Add("z", "789")
 
-	converted, err := convertRequest(r)
+	converted, err := convertRequest(r, testLimits)
 	Expect(err).To(BeNil())
 	Expect(converted).To(Equal(&request.Request{
 		Method: "POST",
+		Host:   "test.io",
 		Path:   "/path",
+		Query:  map[string][]string{},
 		Headers: map[string][]string{
 			"X-Foo":        []string{"bar"},
 			"X-Bar":        []string{"baz", "bonk"},
 			"Content-Type": []string{"multipart/form-data; boundary=xxx"},
 		},
-		Body:    multipartBody,
-		RawBody: "Ci0teHh4CkNvbnRlbnQtRGlzcG9zaXRpb246IGZvcm0tZGF0YTsgbmFtZT0iZmllbGQxIgoKdmFsdWUxCi0teHh4CkNvbnRlbnQtRGlzcG9zaXRpb246IGZvcm0tZGF0YTsgbmFtZT0iZmllbGQyIgoKdmFsdWUyCi0teHh4CkNvbnRlbnQtRGlzcG9zaXRpb246IGZvcm0tZGF0YTsgbmFtZT0iZmlsZSI7IGZpbGVuYW1lPSJmaWxlIgpDb250ZW50LVR5cGU6IGFwcGxpY2F0aW9uL29jdGV0LXN0cmVhbQpDb250ZW50LVRyYW5zZmVyLUVuY29kaW5nOiBiaW5hcnkKCmJpbmFyeSBkYXRhCi0teHh4LS0K",
+		Body:       multipartBody,
+		BodySize:   282,
+		BodySHA256: "509da0a11dc9db5f8a17124649689800fd83eea4cc1f5a22b79f290d519e7906",
 		Form: map[string][]string{
 			"x":      []string{"123"},
 			"y":      []string{"456"},
@@ -119,11 +136,214 @@ func (s *ConversionSuite) TestConvertMultipartForm(t sweet.T) {
 			"field1": []string{"value1"},
 			"field2": []string{"value2"},
 		},
-		Files: map[string]string{
-			"file": "binary data",
-		},
-		RawFiles: map[string]string{
-			"file": "YmluYXJ5IGRhdGE=",
+		Parts: []*request.Part{
+			{
+				Name:    "field1",
+				Headers: map[string][]string{"Content-Disposition": []string{`form-data; name="field1"`}},
+				Content: "value1",
+				Size:    6,
+				SHA256:  "3c9683017f9e4bf33d0fbedd26bf143fd72de9b9dd145441b75f0604047ea28e",
+			},
+			{
+				Name:    "field2",
+				Headers: map[string][]string{"Content-Disposition": []string{`form-data; name="field2"`}},
+				Content: "value2",
+				Size:    6,
+				SHA256:  "0537d481f73a757334328052da3af9626ced97028e20b849f6115c22cd765197",
+			},
+			{
+				Name:        "file",
+				Filename:    "file",
+				ContentType: "application/octet-stream",
+				Headers: map[string][]string{
+					"Content-Disposition":       []string{`form-data; name="file"; filename="file"`},
+					"Content-Type":              []string{"application/octet-stream"},
+					"Content-Transfer-Encoding": []string{"binary"},
+				},
+				Content: "binary data",
+				Size:    11,
+				SHA256:  "9cb63cb779e8c571db3199b783a36cc43cd9e7c076beeb496c39e9cc06196dc5",
+			},
 		},
 	}))
 }
+
+func (s *ConversionSuite) TestConvertMultipartSameFilename(t sweet.T) {
+	body := strings.Join([]string{
+		"--xxx",
+		`Content-Disposition: form-data; name="avatar"; filename="image.png"`,
+		"Content-Type: image/png",
+		"",
+		"first",
+		"--xxx",
+		`Content-Disposition: form-data; name="banner"; filename="image.png"`,
+		"Content-Type: image/jpeg",
+		"",
+		"second",
+		"--xxx--",
+		"",
+	}, "\r\n")
+
+	r, err := http.NewRequest("POST", "http://test.io/path", strings.NewReader(body))
+	Expect(err).To(BeNil())
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=xxx")
+
+	converted, err := convertRequest(r, testLimits)
+	Expect(err).To(BeNil())
+	Expect(converted.Parts).To(HaveLen(2))
+	Expect(converted.Parts[0].Name).To(Equal("avatar"))
+	Expect(converted.Parts[0].ContentType).To(Equal("image/png"))
+	Expect(converted.Parts[0].Content).To(Equal("first"))
+	Expect(converted.Parts[1].Name).To(Equal("banner"))
+	Expect(converted.Parts[1].ContentType).To(Equal("image/jpeg"))
+	Expect(converted.Parts[1].Content).To(Equal("second"))
+}
+
+func (s *ConversionSuite) TestConvertTruncatedParts(t sweet.T) {
+	body := strings.Join([]string{
+		"--xxx",
+		`Content-Disposition: form-data; name="short"`,
+		"",
+		"abc",
+		"--xxx",
+		`Content-Disposition: form-data; name="long"; filename="long.txt"`,
+		"",
+		"abcdefgh",
+		"--xxx",
+		`Content-Disposition: form-data; name="value"`,
+		"",
+		"12345678",
+		"--xxx--",
+		"",
+	}, "\r\n")
+
+	r, err := http.NewRequest("POST", "http://test.io/path", strings.NewReader(body))
+	Expect(err).To(BeNil())
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=xxx")
+
+	converted, err := convertRequest(r, bodyLimits{maxPartSize: 4})
+	Expect(err).To(BeNil())
+	Expect(converted.Parts).To(HaveLen(3))
+	Expect(converted.Parts[0].Content).To(Equal("abc"))
+	Expect(converted.Parts[0].Truncated).To(BeFalse())
+	Expect(converted.Parts[1].Content).To(Equal("abcd"))
+	Expect(converted.Parts[1].Size).To(Equal(8))
+	Expect(converted.Parts[1].SHA256).To(Equal(digest([]byte("abcdefgh"))))
+	Expect(converted.Parts[1].Truncated).To(BeTrue())
+
+	// Form values are read from the same (truncated) parts
+	Expect(converted.Form).To(Equal(map[string][]string{
+		"short": []string{"abc"},
+		"value": []string{"1234"},
+	}))
+}
+
+func (s *ConversionSuite) TestConvertMultipartErrors(t sweet.T) {
+	r, err := http.NewRequest("POST", "http://test.io/path", strings.NewReader(multipartBody))
+	Expect(err).To(BeNil())
+	r.Header.Set("Content-Type", "multipart/form-data")
+
+	_, err = convertRequest(r, testLimits)
+	Expect(err).To(MatchError("failed to parse form (no multipart boundary param in Content-Type)"))
+
+	r, err = http.NewRequest("POST", "http://test.io/path", strings.NewReader("--xxx\r\nbroken"))
+	Expect(err).To(BeNil())
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=xxx")
+
+	_, err = convertRequest(r, testLimits)
+	Expect(err).To(HaveOccurred())
+}
+
+func (s *ConversionSuite) TestConvertCompressed(t sweet.T) {
+	compressors := map[string]func(io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+	}
+
+	for encoding, compressor := range compressors {
+		buffer := &bytes.Buffer{}
+		writer := compressor(buffer)
+		writer.Write([]byte("z=post&both=y"))
+		writer.Close()
+
+		r, err := http.NewRequest("POST", "http://test.io/path", buffer)
+		Expect(err).To(BeNil())
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Content-Encoding", encoding)
+
+		converted, err := convertRequest(r, testLimits)
+		Expect(err).To(BeNil())
+		Expect(converted.Body).To(Equal("z=post&both=y"))
+		Expect(converted.Encoding).To(Equal(encoding))
+		Expect(converted.BodySize).To(Equal(13))
+		Expect(converted.BodySHA256).To(Equal("f063c396ec9103ff1eb738fb611ffca3e6f45ec7ee9ba33ca57c242d37577146"))
+		Expect(converted.Form).To(Equal(map[string][]string{
+			"z":    []string{"post"},
+			"both": []string{"y"},
+		}))
+	}
+}
+
+func (s *ConversionSuite) TestConvertRawDeflate(t sweet.T) {
+	buffer := &bytes.Buffer{}
+	writer, _ := flate.NewWriter(buffer, flate.DefaultCompression)
+	writer.Write([]byte("foo"))
+	writer.Close()
+
+	r, err := http.NewRequest("POST", "http://test.io/path", buffer)
+	Expect(err).To(BeNil())
+	r.Header.Set("Content-Encoding", "deflate")
+
+	converted, err := convertRequest(r, testLimits)
+	Expect(err).To(BeNil())
+	Expect(converted.Body).To(Equal("foo"))
+	Expect(converted.Encoding).To(Equal("deflate"))
+}
+
+func (s *ConversionSuite) TestConvertUndecodable(t sweet.T) {
+	r, err := http.NewRequest("POST", "http://test.io/path", bytes.NewReader([]byte("foo")))
+	Expect(err).To(BeNil())
+	r.Header.Set("Content-Encoding", "gzip")
+
+	converted, err := convertRequest(r, testLimits)
+	Expect(err).To(BeNil())
+	Expect(converted.Body).To(Equal("foo"))
+	Expect(converted.Encoding).To(BeEmpty())
+}
+
+func (s *ConversionSuite) TestConvertBinary(t sweet.T) {
+	r, err := http.NewRequest("POST", "http://test.io/path", bytes.NewReader([]byte{0x0a, 0xff, 0x00}))
+	Expect(err).To(BeNil())
+
+	converted, err := convertRequest(r, testLimits)
+	Expect(err).To(BeNil())
+	Expect(converted.Binary).To(BeTrue())
+	Expect(converted.Body).To(Equal("\x0a\xff\x00"))
+	Expect(converted.BodySize).To(Equal(3))
+}
+
+func (s *ConversionSuite) TestConvertTooLarge(t sweet.T) {
+	limits := bodyLimits{maxBodySize: 4}
+
+	r, err := http.NewRequest("POST", "http://test.io/path", bytes.NewReader([]byte("foo")))
+	Expect(err).To(BeNil())
+	_, err = convertRequest(r, limits)
+	Expect(err).To(BeNil())
+
+	r, err = http.NewRequest("POST", "http://test.io/path", bytes.NewReader([]byte("foobar")))
+	Expect(err).To(BeNil())
+	_, err = convertRequest(r, limits)
+	Expect(err).To(Equal(errBodyTooLarge))
+
+	// The decoded body is also limited
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	writer.Write(bytes.Repeat([]byte("a"), 1000))
+	writer.Close()
+
+	r, err = http.NewRequest("POST", "http://test.io/path", bytes.NewReader(buffer.Bytes()))
+	Expect(err).To(BeNil())
+	r.Header.Set("Content-Encoding", "gzip")
+	_, err = convertRequest(r, bodyLimits{maxBodySize: int64(buffer.Len())})
+	Expect(err).To(Equal(errBodyTooLarge))
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/efritz/derision/internal/expectation"
+	"github.com/efritz/derision/internal/payload"
+	"github.com/efritz/derision/internal/template"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+type (
+	// ConfigError describes a problem with a handler payload. The file, index
+	// and line are set only when known: the file for static configuration, the
+	// index for an entry of a list of payloads, and the line for a YAML file.
+	// The pointer is the JSON pointer of the offending value within the file
+	// or request body.
+	ConfigError struct {
+		File    string `json:"file,omitempty"`
+		Index   *int   `json:"index,omitempty"`
+		Line    int    `json:"line,omitempty"`
+		Pointer string `json:"pointer"`
+		Message string `json:"message"`
+	}
+
+	// ConfigErrors is the list of all problems found in a configuration.
+	ConfigErrors []*ConfigError
+)
+
+var yamlLinePattern = regexp.MustCompile(`^yaml: line (\d+): `)
+
+func (e *ConfigError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, e.Line)
+	}
+
+	message := e.Message
+	if e.Pointer != "" {
+		message = fmt.Sprintf("%s: %s", e.Pointer, message)
+	}
+
+	if location == "" {
+		return message
+	}
+
+	return fmt.Sprintf("%s: %s", location, message)
+}
+
+func (e ConfigErrors) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// field returns the pointer of the error relative to its list entry in the
+// dotted notation used by the schema validator (e.g. request.path).
+func (e *ConfigError) field() string {
+	pointer := e.Pointer
+	if e.Index != nil {
+		pointer = strings.TrimPrefix(pointer, fmt.Sprintf("/%d", *e.Index))
+	}
+
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return strings.Join(tokens, ".")
+}
+
+// byIndex groups the errors by the index of the list entry at fault and
+// prefixes each message with the offending field. Errors that do not belong
+// to a list entry are grouped under (root).
+func (e ConfigErrors) byIndex() map[string][]string {
+	errors := map[string][]string{}
+	for _, err := range e {
+		index := "(root)"
+		if err.Index != nil {
+			index = strconv.Itoa(*err.Index)
+		}
+
+		message := err.Message
+		if field := err.field(); field != "" {
+			message = fmt.Sprintf("%s: %s", field, message)
+		}
+
+		errors[index] = append(errors[index], message)
+	}
+
+	return errors
+}
+
+// byField maps the offending field of each error to its message. This is
+// the format of the errors of a single payload, as reported by /register.
+func (e ConfigErrors) byField() map[string]string {
+	errors := map[string]string{}
+	for _, err := range e {
+		field := err.field()
+		if field == "" {
+			field = "(root)"
+		}
+
+		errors[field] = err.Message
+	}
+
+	return errors
+}
+
+// newSchemaErrors converts the result of validating a payload (or a list of
+// payloads, if list is true) against the handler schema.
+func newSchemaErrors(resultErrors []gojsonschema.ResultError, list bool) ConfigErrors {
+	errors := ConfigErrors{}
+	for _, err := range resultErrors {
+		configError := &ConfigError{Message: err.Description()}
+
+		if field := err.Field(); field != "(root)" {
+			tokens := strings.Split(field, ".")
+			configError.Pointer = payload.Pointer(tokens...)
+
+			if index, convErr := strconv.Atoi(tokens[0]); convErr == nil && list {
+				configError.Index = &index
+			}
+		}
+
+		errors = append(errors, configError)
+	}
+
+	return errors
+}
+
+// newPayloadError describes an error that occurred while compiling the given
+// section (/request or /response) of a handler payload. The pointer of the
+// error refers to the offending pattern or template if it is known.
+func newPayloadError(section, message string, err error) *ConfigError {
+	pointer := section
+	switch compileErr := err.(type) {
+	case *expectation.CompileError:
+		pointer += compileErr.Pointer
+	case *template.CompileError:
+		pointer += compileErr.Pointer
+	}
+
+	return &ConfigError{
+		Pointer: pointer,
+		Message: fmt.Sprintf("%s (%s)", message, err.Error()),
+	}
+}
+
+// newYAMLError describes an error that occurred while converting a YAML file
+// into JSON, moving the line number reported by the parser into the error.
+func newYAMLError(file string, err error) *ConfigError {
+	configError := &ConfigError{File: file, Message: err.Error()}
+
+	if match := yamlLinePattern.FindStringSubmatch(configError.Message); match != nil {
+		configError.Line, _ = strconv.Atoi(match[1])
+		configError.Message = "yaml: " + configError.Message[len(match[0]):]
+	}
+
+	return configError
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type yamlDocument struct {
+	content []byte
+	offset  int
+}
+
+var (
+	configExtensions = map[string]struct{}{
+		".json": struct{}{},
+		".yaml": struct{}{},
+		".yml":  struct{}{},
+	}
+
+	documentMarkerPattern = regexp.MustCompile(`^---(\s|$)`)
+)
+
+// configFiles returns the paths (relative to the given config directory) of
+// the files that are loaded from the directory, in the order in which they
+// are loaded. Fragments (files only loaded by an include) are not returned.
+func configFiles(path string) ([]string, error) {
+	names, err := listFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, name := range names {
+		if !isFragment(name) {
+			files = append(files, name)
+		}
+	}
+
+	return files, nil
+}
+
+// listFiles returns the paths (relative to the given config directory) of
+// all YAML and JSON files of the directory and its subdirectories in lexical
+// order. Hidden files and directories are skipped.
+func listFiles(path string) ([]string, error) {
+	return walkFiles(path, func(name string) bool {
+		_, ok := configExtensions[strings.ToLower(filepath.Ext(name))]
+		return ok
+	})
+}
+
+// walkFiles returns the paths (relative to the given config directory) of
+// the files of the directory and its subdirectories accepted by the given
+// filter in lexical order. Hidden files and directories are skipped.
+func walkFiles(path string, filter func(name string) bool) ([]string, error) {
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("failed to read config directory")
+	}
+
+	names := []string{}
+	walkErr := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if filePath != path && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if info.IsDir() || !filter(filePath) {
+			return nil
+		}
+
+		name, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err
+		}
+
+		names = append(names, filepath.ToSlash(name))
+		return nil
+	})
+
+	if walkErr != nil {
+		return nil, fmt.Errorf("failed to read config directory (%s)", walkErr.Error())
+	}
+
+	return names, nil
+}
+
+// isFragment determines if the file at the given relative path, or one of
+// its directories, begins with an underscore.
+func isFragment(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, "_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitDocuments splits the content of a YAML file at each document marker
+// (---). The offset of each document is the number of lines that precede it
+// in the file. A marker line is replaced by any content that follows the
+// marker on the same line.
+func splitDocuments(content []byte) []yamlDocument {
+	documents := []yamlDocument{}
+	current := []string{}
+	offset := 0
+
+	for i, line := range strings.Split(string(content), "\n") {
+		if documentMarkerPattern.MatchString(line) {
+			documents = append(documents, yamlDocument{content: []byte(strings.Join(current, "\n")), offset: offset})
+			current, offset = []string{strings.TrimSpace(line[3:])}, i
+			continue
+		}
+
+		current = append(current, line)
+	}
+
+	return append(documents, yamlDocument{content: []byte(strings.Join(current, "\n")), offset: offset})
+}
+
+// parseInclude returns the path of an include directive, which is a list
+// entry of the form {"include": "path"}.
+func parseInclude(entry json.RawMessage) (string, bool) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(entry, &fields); err != nil || len(fields) != 1 {
+		return "", false
+	}
+
+	path := ""
+	if err := json.Unmarshal(fields["include"], &path); err != nil {
+		return "", false
+	}
+
+	return path, true
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/efritz/chevron"
+	"github.com/efritz/derision/internal/handler"
+	"github.com/efritz/derision/internal/request"
+	"github.com/efritz/derision/internal/template"
+	"github.com/efritz/nacelle"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Handler serves the mock API and its control endpoints in-process, without
+// a nacelle process.
+type Handler struct {
+	http.Handler
+	HandlerSet handler.HandlerSet
+	RequestLog request.Log
+	schema     *gojsonschema.Schema
+	configDir  string
+}
+
+// NewHandler creates a handler from the given config. The config is used as
+// given (its PostLoad method is not invoked).
+func NewHandler(serverConfig *Config) (*Handler, error) {
+	services, err := nacelle.NewServiceContainer()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := services.Set("logger", nacelle.NewNilLogger()); err != nil {
+		return nil, err
+	}
+
+	if err := setupDataStructures(serverConfig, services); err != nil {
+		return nil, err
+	}
+
+	catchAllHandler := &CatchAllHandler{}
+	if err := services.Inject(catchAllHandler); err != nil {
+		return nil, err
+	}
+
+	handlerSchema, err := services.Get("handler-schema")
+	if err != nil {
+		return nil, err
+	}
+
+	router := chevron.NewRouter(services, chevron.WithNotFoundHandler(catchAllHandler.Handle))
+	setupRoutes(router, catchAllHandler.Handle)
+
+	return &Handler{
+		Handler:    router,
+		HandlerSet: catchAllHandler.HandlerSet,
+		RequestLog: catchAllHandler.RequestLog,
+		schema:     handlerSchema.(*gojsonschema.Schema),
+		configDir:  serverConfig.ConfigDir,
+	}, nil
+}
+
+// Add registers an expectation from a payload of the same structure as the
+// body of the /register endpoint. The payload is validated in the same way.
+func (h *Handler) Add(payload []byte) error {
+	result, err := h.schema.Validate(gojsonschema.NewBytesLoader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to validate expectation (%s)", err.Error())
+	}
+
+	if !result.Valid() {
+		return fmt.Errorf("failed to register expectation (%s)", newSchemaErrors(result.Errors(), false).Error())
+	}
+
+	handler, configs, err := makeHandler(payload, template.WithFileRoot(h.configDir))
+	if err != nil {
+		return fmt.Errorf("failed to register expectation (%s)", err.Error())
+	}
+
+	h.HandlerSet.Add(handler, configs...)
+	return nil
+}
+
+// Close stops publishing requests to the request stream. The handler should
+// not serve requests once it is closed.
+func (h *Handler) Close() {
+	h.RequestLog.Close()
+}
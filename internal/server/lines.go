@@ -0,0 +1,176 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/efritz/derision/internal/payload"
+)
+
+type (
+	// lineIndex maps JSON pointers of the values of a YAML document to the
+	// line on which each value is defined.
+	lineIndex map[string]int
+
+	// yamlFrame is a block collection that is open at the current line of
+	// the document. The indentation and kind of a collection's entries are
+	// not known until its first entry is seen.
+	yamlFrame struct {
+		pointer     string
+		keyIndent   int
+		entryIndent int
+		sequence    bool
+		next        int
+	}
+)
+
+// indexLines determines the line on which each value of the given YAML
+// document is defined. Only block collections are indexed: the values of
+// a flow collection (e.g. a JSON document) are attributed to the line of
+// the nearest enclosing block collection entry, if any.
+func indexLines(content []byte) lineIndex {
+	index := lineIndex{}
+	stack := []*yamlFrame{{keyIndent: -1, entryIndent: -1}}
+	blockIndent := -1
+
+	for i, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "---") {
+			continue
+		}
+
+		// Skip the content of a literal or folded block scalar
+		if blockIndent >= 0 {
+			if indent > blockIndent {
+				continue
+			}
+
+			blockIndent = -1
+		}
+
+		for trimmed != "" {
+			isEntry := trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+			stack = closeFrames(stack, indent, isEntry)
+			top := stack[len(stack)-1]
+
+			if isEntry {
+				if top.entryIndent < 0 || !top.sequence {
+					// A sequence entry at a position that cannot open a new
+					// sequence (e.g. a stray dash in a scalar) is ignored.
+					break
+				}
+
+				pointer := top.pointer + payload.Pointer(strconv.Itoa(top.next))
+				top.next++
+				index[pointer] = i + 1
+
+				rest := strings.TrimLeft(strings.TrimPrefix(trimmed, "-"), " ")
+				offset := len(trimmed) - len(rest)
+
+				stack = append(stack, &yamlFrame{pointer: pointer, keyIndent: indent, entryIndent: -1})
+				indent, trimmed = indent+offset, rest
+				continue
+			}
+
+			key, value, ok := splitKey(trimmed)
+			if !ok || top.entryIndent < 0 || top.sequence {
+				break
+			}
+
+			pointer := top.pointer + payload.Pointer(key)
+			index[pointer] = i + 1
+
+			switch {
+			case value == "":
+				stack = append(stack, &yamlFrame{pointer: pointer, keyIndent: indent, entryIndent: -1})
+			case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+				blockIndent = indent
+			}
+
+			break
+		}
+	}
+
+	return index
+}
+
+// closeFrames pops the collections that cannot contain a line with the given
+// indentation. A collection whose entries are not yet known is opened by the
+// line if it is indented further than the collection's key, or if the line
+// is a sequence entry at the same indentation as the key.
+func closeFrames(stack []*yamlFrame, indent int, isEntry bool) []*yamlFrame {
+	for len(stack) > 1 {
+		top := stack[len(stack)-1]
+
+		if top.entryIndent < 0 {
+			if indent > top.keyIndent || (isEntry && indent == top.keyIndent) {
+				top.entryIndent = indent
+				top.sequence = isEntry
+				return stack
+			}
+
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if top.entryIndent > indent || (top.entryIndent == indent && top.sequence != isEntry) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		break
+	}
+
+	if root := stack[0]; len(stack) == 1 && root.entryIndent < 0 {
+		root.entryIndent = indent
+		root.sequence = isEntry
+	}
+
+	return stack
+}
+
+// splitKey splits a block mapping entry into its (unquoted) key and value.
+func splitKey(text string) (string, string, bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		end := strings.Index(text[1:], text[:1])
+		if end < 0 || !strings.HasPrefix(text[end+2:], ":") {
+			return "", "", false
+		}
+
+		return text[1 : end+1], strings.TrimSpace(text[end+3:]), true
+	}
+
+	if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		return "", "", false
+	}
+
+	if strings.HasSuffix(text, ":") {
+		return text[:len(text)-1], "", true
+	}
+
+	if index := strings.Index(text, ": "); index >= 0 {
+		return text[:index], strings.TrimSpace(text[index+2:]), true
+	}
+
+	return "", "", false
+}
+
+// lookup returns the line of the value at the given pointer, or of its
+// nearest ancestor if the value was not indexed. Zero is returned if no
+// line is known.
+func (i lineIndex) lookup(pointer string) int {
+	for {
+		if line, ok := i[pointer]; ok {
+			return line
+		}
+
+		index := strings.LastIndex(pointer, "/")
+		if index < 0 {
+			return 0
+		}
+
+		pointer = pointer[:index]
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type LinesSuite struct{}
+
+func (s *LinesSuite) TestIndexLines(t sweet.T) {
+	index := indexLines([]byte(`# handlers
+- request:
+    method: GET
+    headers:
+      "X-A/B": foo
+  response:
+    body: |
+      - not: an entry
+    headers:
+      X-Foo:
+      - a
+      - b
+-   request: {path: /b}
+    response: {}
+`))
+
+	Expect(index).To(Equal(lineIndex{
+		"/0":                          2,
+		"/0/request":                  2,
+		"/0/request/method":           3,
+		"/0/request/headers":          4,
+		"/0/request/headers/X-A~1B":   5,
+		"/0/response":                 6,
+		"/0/response/body":            7,
+		"/0/response/headers":         9,
+		"/0/response/headers/X-Foo":   10,
+		"/0/response/headers/X-Foo/0": 11,
+		"/0/response/headers/X-Foo/1": 12,
+		"/1":                          13,
+		"/1/request":                  13,
+		"/1/response":                 14,
+	}))
+}
+
+func (s *LinesSuite) TestLookup(t sweet.T) {
+	index := lineIndex{"/0": 2, "/0/request": 3}
+	Expect(index.lookup("/0/request/path")).To(Equal(3))
+	Expect(index.lookup("/0/response")).To(Equal(2))
+	Expect(index.lookup("/1")).To(Equal(0))
+	Expect(index.lookup("")).To(Equal(0))
+}
+
+func (s *LinesSuite) TestIndexLinesFlow(t sweet.T) {
+	Expect(indexLines([]byte(`[{"request": {}, "response": {}}]`))).To(BeEmpty())
+}
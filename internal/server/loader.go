@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/efritz/derision/internal/handler"
+	"github.com/efritz/derision/internal/template"
+	"github.com/ghodss/yaml"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// configLoader compiles the handlers defined in the files of a config
+// directory.
+type configLoader struct {
+	schema *gojsonschema.Schema
+	root   string
+	vars   variables
+}
+
+// loadHandlers replaces the handlers of the given handler set that were
+// loaded from the config directory with the handlers defined in the files
+// of the given directory. If any file is invalid, the handler set is not
+// modified and every problem found in every file is returned as ConfigErrors.
+func loadHandlers(handlerSet handler.HandlerSet, schema *gojsonschema.Schema, path string) error {
+	registrations, errors, err := loadDir(schema, path)
+	if err != nil {
+		return err
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+
+	handlerSet.Replace(configSource, registrations)
+	return nil
+}
+
+// loadDir compiles the handlers defined in each file of the given directory.
+// An error is returned only if the directory cannot be read.
+func loadDir(schema *gojsonschema.Schema, path string) ([]*handler.Registration, ConfigErrors, error) {
+	vars, errors, err := loadVariables(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(errors) > 0 {
+		return nil, errors, nil
+	}
+
+	names, err := configFiles(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loader := &configLoader{
+		schema: schema,
+		root:   path,
+		vars:   vars,
+	}
+
+	registrations := []*handler.Registration{}
+	for _, name := range names {
+		fileRegistrations, fileErrors := loader.loadFile(name, nil)
+		registrations = append(registrations, fileRegistrations...)
+		errors = append(errors, fileErrors...)
+	}
+
+	return registrations, errors, nil
+}
+
+// loadFile compiles the handlers defined in each document of a single
+// configuration file. The name of the file is relative to the config
+// directory, and the including argument lists the files that (transitively)
+// include this one. Vars blocks are skipped. Each error is attributed to the
+// file and, for a YAML list of handler payloads, to the line of the offending
+// value. Converted third-party documents do not correspond line-by-line with
+// the generated payloads and have no lines.
+func (l *configLoader) loadFile(name string, including []string) ([]*handler.Registration, ConfigErrors) {
+	content, err := ioutil.ReadFile(filepath.Join(l.root, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, ConfigErrors{{File: name, Message: err.Error()}}
+	}
+
+	registrations := []*handler.Registration{}
+	errors := ConfigErrors{}
+
+	for _, document := range splitDocuments(content) {
+		data, err := yaml.YAMLToJSON(document.content)
+		if err != nil {
+			yamlError := newYAMLError(name, err)
+			if yamlError.Line > 0 {
+				yamlError.Line += document.offset
+			}
+
+			errors = append(errors, yamlError)
+			continue
+		}
+
+		if bytes.Equal(data, []byte("null")) {
+			continue
+		}
+
+		if _, ok := parseVarsBlock(data); ok {
+			continue
+		}
+
+		lines := indexLines(document.content)
+		attribute := func(documentErrors ConfigErrors) {
+			for _, err := range documentErrors {
+				// Errors of included files are already attributed
+				if err.File == "" {
+					err.File = name
+
+					if line := lines.lookup(err.Pointer); line > 0 {
+						err.Line = line + document.offset
+					}
+				}
+			}
+
+			errors = append(errors, documentErrors...)
+		}
+
+		interpolated, interpolationErrors := l.vars.interpolate(data)
+		if len(interpolationErrors) > 0 {
+			attribute(interpolationErrors)
+			continue
+		}
+
+		converted, err := convertDocument(interpolated)
+		if err != nil {
+			errors = append(errors, &ConfigError{File: name, Message: err.Error()})
+			continue
+		}
+
+		if !bytes.Equal(interpolated, converted) {
+			lines = lineIndex{}
+		}
+
+		documentRegistrations, documentErrors := l.loadDocument(name, converted, including)
+		registrations = append(registrations, documentRegistrations...)
+		attribute(documentErrors)
+	}
+
+	if len(errors) > 0 {
+		return nil, errors
+	}
+
+	return registrations, nil
+}
+
+// loadDocument compiles a list of handler payloads in which any entry may
+// be an include directive. An include directive is replaced by the handlers
+// of the file at the given path, relative to the including file.
+func (l *configLoader) loadDocument(name string, data []byte, including []string) ([]*handler.Registration, ConfigErrors) {
+	entries := []json.RawMessage{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return compileDocument(l.schema, l.root, data)
+	}
+
+	payloads := []json.RawMessage{}
+	indexes := []int{}
+	includes := []int{}
+	paths := map[int]string{}
+
+	for i, entry := range entries {
+		if path, ok := parseInclude(entry); ok {
+			includes = append(includes, i)
+			paths[i] = path
+			continue
+		}
+
+		payloads = append(payloads, entry)
+		indexes = append(indexes, i)
+	}
+
+	if len(includes) == 0 {
+		return compileDocument(l.schema, l.root, data)
+	}
+
+	serialized, err := json.Marshal(payloads)
+	if err != nil {
+		return nil, ConfigErrors{{Message: err.Error()}}
+	}
+
+	registrations, errors := compileDocument(l.schema, l.root, serialized)
+	for _, err := range errors {
+		if err.Index != nil {
+			index := indexes[*err.Index]
+			err.Pointer = fmt.Sprintf("/%d%s", index, strings.TrimPrefix(err.Pointer, fmt.Sprintf("/%d", *err.Index)))
+			err.Index = &index
+		}
+	}
+
+	chain := append(append([]string{}, including...), name)
+	included := map[int][]*handler.Registration{}
+
+	for _, i := range includes {
+		index := i
+		target := filepath.ToSlash(filepath.Join(filepath.Dir(name), filepath.FromSlash(paths[i])))
+		pointer := fmt.Sprintf("/%d/include", i)
+
+		if filepath.IsAbs(paths[i]) {
+			errors = append(errors, &ConfigError{Index: &index, Pointer: pointer, Message: "include path must be relative"})
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Join(l.root, filepath.FromSlash(target))); err != nil {
+			errors = append(errors, &ConfigError{Index: &index, Pointer: pointer, Message: fmt.Sprintf("failed to read include (%s)", err.Error())})
+			continue
+		}
+
+		if contains(chain, target) {
+			errors = append(errors, &ConfigError{Index: &index, Pointer: pointer, Message: fmt.Sprintf("include cycle (%s -> %s)", strings.Join(chain, " -> "), target)})
+			continue
+		}
+
+		includedRegistrations, includedErrors := l.loadFile(target, chain)
+		included[i] = includedRegistrations
+		errors = append(errors, includedErrors...)
+	}
+
+	if len(errors) > 0 {
+		return nil, errors
+	}
+
+	combined := []*handler.Registration{}
+	for i := range entries {
+		if _, ok := paths[i]; ok {
+			combined = append(combined, included[i]...)
+			continue
+		}
+
+		combined = append(combined, registrations[0])
+		registrations = registrations[1:]
+	}
+
+	return combined, nil
+}
+
+// compileDocument compiles a list of handler payloads, converting a failure
+// to apply the schema into a configuration error. Body files are resolved
+// against the given config directory.
+func compileDocument(schema *gojsonschema.Schema, root string, data []byte) ([]*handler.Registration, ConfigErrors) {
+	registrations, errors, err := compilePayloads(schema, data, template.WithFileRoot(root))
+	if err != nil {
+		return nil, ConfigErrors{{Message: err.Error()}}
+	}
+
+	return registrations, errors
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -15,7 +15,11 @@ func TestMain(m *testing.M) {
 		s.RegisterPlugin(junit.NewPlugin())
 
 		s.AddSuite(&ConversionSuite{})
+		s.AddSuite(&LinesSuite{})
 		s.AddSuite(&MiddlewareSuite{})
 		s.AddSuite(&SerializationSuite{})
+		s.AddSuite(&ValidateSuite{})
+		s.AddSuite(&VarsSuite{})
+		s.AddSuite(&WatcherSuite{})
 	})
 }
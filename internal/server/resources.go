@@ -2,15 +2,24 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/efritz/chevron"
-	"github.com/efritz/chevron/middleware"
 	"github.com/efritz/derision/internal/handler"
+	"github.com/efritz/derision/internal/har"
+	"github.com/efritz/derision/internal/pact"
 	"github.com/efritz/derision/internal/request"
+	"github.com/efritz/derision/internal/template"
 	"github.com/efritz/nacelle"
 	"github.com/efritz/response"
 	"github.com/efritz/sse"
+	"github.com/ghodss/yaml"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 type (
@@ -18,27 +27,98 @@ type (
 		*chevron.EmptySpec
 		HandlerSet handler.HandlerSet `service:"handler-set"`
 		RequestLog request.Log        `service:"request-log"`
+		Config     *Config            `service:"server-config"`
+	}
+
+	CatchAllHandler struct {
+		*BaseResource
+		Contract *Contract `service:"contract" optional:"true"`
 	}
 
-	CatchAllHandler  struct{ *BaseResource }
-	RegisterResource struct{ *BaseResource }
 	ClearResource    struct{ *BaseResource }
 	RequestsResource struct{ *BaseResource }
+	PactResource     struct{ *BaseResource }
+
+	RegisterResource struct {
+		*BaseResource
+		Schema *gojsonschema.Schema `service:"handler-schema"`
+	}
+
+	ExpectationsResource struct {
+		*BaseResource
+		Schema *gojsonschema.Schema `service:"schema"`
+	}
+
+	ImportResource struct {
+		*BaseResource
+		Schema *gojsonschema.Schema `service:"schema"`
+	}
+
+	ReloadResource struct {
+		*BaseResource
+		Watcher *configWatcher `service:"config-watcher" optional:"true"`
+	}
 
 	SSEResource struct {
 		*BaseResource
-		sseServer *sse.Server
+		subscribers map[chan interface{}]struct{}
+		mutex       sync.Mutex
 	}
 )
 
+// subscriberBufferSize is the number of events that may be queued for a
+// request stream subscriber before events are dropped.
+const subscriberBufferSize = 100
+
 func (r *CatchAllHandler) Handle(ctx context.Context, req *http.Request, logger nacelle.Logger) response.Response {
-	reqModel, err := convertRequest(req)
+	started := time.Now()
+
+	reqModel, err := convertRequest(req, r.Config.bodyLimits())
 	if err != nil {
+		if err == errBodyTooLarge {
+			return response.Empty(http.StatusRequestEntityTooLarge)
+		}
+
 		logger.Error(err.Error())
 		return response.Empty(http.StatusInternalServerError)
 	}
 
+	reqModel.Timestamp = started
+
+	if r.Contract != nil {
+		reqModel.Violations = r.Contract.Validator.Validate(reqModel)
+	}
+
+	resp := r.respond(reqModel, logger)
+
+	// Drain the response so that it can be recorded in the request log
+	// alongside the request, then rebuild it to send to the client.
+	headers, body, err := response.Serialize(resp)
+	if err != nil {
+		logger.Error("failed to serialize response (%s)", err.Error())
+		resp, headers, body = response.Empty(http.StatusInternalServerError), http.Header{}, nil
+	}
+
+	reqModel.Response = &request.Response{
+		StatusCode: resp.StatusCode(),
+		Headers:    headers,
+		Body:       string(body),
+		ElapsedMs:  float64(time.Since(started)) / float64(time.Millisecond),
+	}
+
 	r.RequestLog.Add(reqModel)
+	return response.Reconstruct(resp.StatusCode(), headers, body)
+}
+
+func (r *CatchAllHandler) respond(reqModel *request.Request, logger nacelle.Logger) response.Response {
+	if r.Contract != nil && r.Contract.RejectsInvalid && len(reqModel.Violations) > 0 {
+		resp := response.JSON(map[string]interface{}{
+			"violations": reqModel.Violations,
+		})
+
+		resp.SetStatusCode(http.StatusBadRequest)
+		return resp
+	}
 
 	resp, err := r.HandlerSet.Handle(reqModel)
 	if err != nil {
@@ -54,39 +134,273 @@ func (r *CatchAllHandler) Handle(ctx context.Context, req *http.Request, logger
 }
 
 func (r *RegisterResource) Post(ctx context.Context, req *http.Request, logger nacelle.Logger) response.Response {
-	handler, err := makeHandler(middleware.GetJSONData(ctx))
+	defer req.Body.Close()
+
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		logger.Error("failed to read request body (%s)", err.Error())
+		return response.Empty(http.StatusInternalServerError)
+	}
+
+	if !json.Valid(data) {
+		return response.Empty(http.StatusBadRequest)
+	}
+
+	result, err := r.Schema.Validate(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		logger.Error(err.Error())
+		return response.Empty(http.StatusInternalServerError)
+	}
+
+	if !result.Valid() {
+		errors := newSchemaErrors(result.Errors(), false)
+		return unprocessableEntity(errors.byField(), errors)
+	}
+
+	handler, configs, err := makeHandler(data, template.WithFileRoot(r.Config.ConfigDir))
+	if err != nil {
+		errors := ConfigErrors{err.(*ConfigError)}
+		return unprocessableEntity(errors.byField(), errors)
+	}
+
+	r.HandlerSet.Add(handler, configs...)
+	return response.Empty(http.StatusNoContent)
+}
+
+func (r *ExpectationsResource) Put(ctx context.Context, req *http.Request, logger nacelle.Logger) response.Response {
+	defer req.Body.Close()
+
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		logger.Error("failed to read request body (%s)", err.Error())
+		return response.Empty(http.StatusInternalServerError)
+	}
+
+	if !json.Valid(data) {
+		return response.Empty(http.StatusBadRequest)
+	}
+
+	registrations, errors, err := compilePayloads(r.Schema, data, template.WithFileRoot(r.Config.ConfigDir))
+	if err != nil {
+		logger.Error(err.Error())
+		return response.Empty(http.StatusInternalServerError)
+	}
+
+	if len(errors) > 0 {
+		return unprocessableEntity(errors.byIndex(), errors)
+	}
+
+	r.HandlerSet.Set(registrations)
+	return response.Empty(http.StatusNoContent)
+}
+
+func (r *ImportResource) Post(ctx context.Context, req *http.Request, logger nacelle.Logger) response.Response {
+	defer req.Body.Close()
+
+	content, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		logger.Error("failed to read request body (%s)", err.Error())
+		return response.Empty(http.StatusInternalServerError)
+	}
+
+	data, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return response.Empty(http.StatusBadRequest)
+	}
+
+	data, err = convertDocument(data)
+	if err != nil {
+		errors := ConfigErrors{{Message: err.Error()}}
+		return unprocessableEntity(errors.byIndex(), errors)
+	}
+
+	registrations, errors, err := compilePayloads(r.Schema, data, template.WithFileRoot(r.Config.ConfigDir))
 	if err != nil {
 		logger.Error(err.Error())
 		return response.Empty(http.StatusInternalServerError)
 	}
 
-	r.HandlerSet.Add(handler)
+	if len(errors) > 0 {
+		return unprocessableEntity(errors.byIndex(), errors)
+	}
+
+	r.HandlerSet.Append(registrations)
 	return response.Empty(http.StatusNoContent)
 }
 
+func (r *ReloadResource) Get(ctx context.Context, req *http.Request, logger nacelle.Logger) response.Response {
+	if r.Watcher == nil {
+		return response.Empty(http.StatusNotFound)
+	}
+
+	return response.JSON(r.Watcher.Status())
+}
+
+func (r *ReloadResource) Post(ctx context.Context, req *http.Request, logger nacelle.Logger) response.Response {
+	if r.Watcher == nil {
+		return response.Empty(http.StatusNotFound)
+	}
+
+	if err := r.Watcher.reload(true); err != nil {
+		resp := response.JSON(r.Watcher.Status())
+		resp.SetStatusCode(http.StatusUnprocessableEntity)
+		return resp
+	}
+
+	return response.JSON(r.Watcher.Status())
+}
+
 func (r *ClearResource) Post(ctx context.Context, req *http.Request, logger nacelle.Logger) response.Response {
 	r.HandlerSet.Clear()
 	return response.Empty(http.StatusNoContent)
 }
 
 func (r *RequestsResource) Get(ctx context.Context, req *http.Request, logger nacelle.Logger) response.Response {
-	return response.JSON(r.RequestLog.Copy(req.URL.Query().Get("clear") != ""))
+	requests := r.RequestLog.Copy(req.URL.Query().Get("clear") != "")
+
+	switch req.URL.Query().Get("format") {
+	case "", "json":
+		return response.JSON(requests)
+
+	case "har":
+		return response.JSON(har.Export(requests))
+	}
+
+	return response.Empty(http.StatusBadRequest)
+}
+
+func (r *PactResource) Get(ctx context.Context, req *http.Request, logger nacelle.Logger) response.Response {
+	query := req.URL.Query()
+
+	consumer, provider := query.Get("consumer"), query.Get("provider")
+	if consumer == "" || provider == "" {
+		return response.Empty(http.StatusBadRequest)
+	}
+
+	requests := r.RequestLog.Copy(false)
+	if tag := query.Get("tag"); tag != "" {
+		requests = filterByTag(requests, tag)
+	}
+
+	return response.JSON(pact.Generate(consumer, provider, requests))
 }
 
 func (r *SSEResource) PostInject() error {
-	ch := make(chan interface{})
+	r.subscribers = map[chan interface{}]struct{}{}
 
 	go func() {
 		for request := range r.RequestLog.Chan() {
-			ch <- request
+			r.publish(request)
 		}
 	}()
 
-	r.sseServer = sse.NewServer(ch)
-	go r.sseServer.Start()
 	return nil
 }
 
 func (r *SSEResource) Get(ctx context.Context, req *http.Request, logger nacelle.Logger) response.Response {
-	return r.sseServer.Handler(req)
+	// Each subscriber gets its own event server so that its stream can be
+	// ended as soon as the client goes away. Otherwise the stream is held
+	// open until the next event fails to write.
+	ch := r.subscribe()
+	sseServer := sse.NewServer(ch)
+	go sseServer.Start()
+
+	resp := sseServer.Handler(req)
+
+	go func() {
+		<-req.Context().Done()
+		r.unsubscribe(ch)
+	}()
+
+	// Flush the headers as soon as the stream is opened so that a client
+	// knows it is subscribed before the first event arrives.
+	return resp.DecorateWriter(newFlushWriter)
+}
+
+func (r *SSEResource) subscribe() chan interface{} {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ch := make(chan interface{}, subscriberBufferSize)
+	r.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (r *SSEResource) unsubscribe(ch chan interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.subscribers, ch)
+	close(ch)
+}
+
+// publish sends the request to each subscriber. A subscriber that is not
+// keeping up misses the event rather than blocking the request log.
+func (r *SSEResource) publish(request *request.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for ch := range r.subscribers {
+		select {
+		case ch <- request:
+		default:
+		}
+	}
+}
+
+// flushWriter flushes the underlying writer after each write. The stream
+// writer cannot detect the Flusher of a decorated writer, so it is done
+// here instead.
+type flushWriter struct {
+	io.Writer
+	flusher http.Flusher
+}
+
+func newFlushWriter(w io.Writer) io.Writer {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return w
+	}
+
+	flusher.Flush()
+	return &flushWriter{Writer: w, flusher: flusher}
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.flusher.Flush()
+	return n, err
+}
+
+// filterByTag returns the requests that were matched by an expectation
+// with the given tag.
+func filterByTag(requests []*request.Request, tag string) []*request.Request {
+	filtered := []*request.Request{}
+	for _, r := range requests {
+		if r.Expectation == nil {
+			continue
+		}
+
+		for _, t := range r.Expectation.Tags {
+			if t == tag {
+				filtered = append(filtered, r)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
+
+// unprocessableEntity describes invalid handler payloads. The error field
+// summarizes the errors by index or field, and the errors field lists each
+// error with its JSON pointer.
+func unprocessableEntity(summary interface{}, errors ConfigErrors) response.Response {
+	resp := response.JSON(map[string]interface{}{
+		"error":  summary,
+		"errors": errors,
+	})
+
+	resp.SetStatusCode(http.StatusUnprocessableEntity)
+	return resp
 }
@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
-	"strings"
+	"sort"
 
 	"github.com/efritz/derision/internal/expectation"
 	"github.com/efritz/derision/internal/handler"
+	"github.com/efritz/derision/internal/har"
+	"github.com/efritz/derision/internal/openapi"
+	"github.com/efritz/derision/internal/pact"
+	"github.com/efritz/derision/internal/postman"
 	"github.com/efritz/derision/internal/request"
 	"github.com/efritz/derision/internal/template"
 	"github.com/efritz/response"
@@ -19,123 +23,174 @@ import (
 type jsonHandler struct {
 	Expectation json.RawMessage `json:"request"`
 	Template    json.RawMessage `json:"response"`
+	Priority    int             `json:"priority"`
+	Position    string          `json:"position"`
+	Fallback    bool            `json:"fallback"`
+	Tags        []string        `json:"tags"`
 }
 
-var schemaPath = "/schemas"
-
-func makeHandler(input []byte) (handler.Handler, error) {
+// makeHandler compiles a single handler payload. The returned error, if any,
+// is a *ConfigError whose pointer refers to the offending value.
+func makeHandler(input []byte, templateConfigs ...template.ConfigFunc) (handler.Handler, []handler.RegistrationConfigFunc, error) {
 	payload := &jsonHandler{}
 	if err := json.Unmarshal(input, &payload); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal payload (%s)", err.Error())
+		return nil, nil, &ConfigError{Message: fmt.Sprintf("failed to unmarshal payload (%s)", err.Error())}
 	}
 
 	expectation, err := expectation.Unmarshal(payload.Expectation)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal expectation (%s)", err.Error())
+		return nil, nil, newPayloadError("/request", "failed to unmarshal expectation", err)
 	}
 
-	template, err := template.Unmarshal(payload.Template)
+	template, err := template.Unmarshal(payload.Template, templateConfigs...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal template (%s)", err.Error())
+		return nil, nil, newPayloadError("/response", "failed to unmarshal template", err)
+	}
+
+	matched := &request.Expectation{}
+	if err := json.Unmarshal(payload.Expectation, matched); err != nil {
+		return nil, nil, newPayloadError("/request", "failed to unmarshal expectation", err)
 	}
 
+	matched.Tags = payload.Tags
+	matched.Fallback = payload.Fallback
+
 	handler := func(r *request.Request) (response.Response, error) {
 		if match := expectation.Matches(r); match != nil {
-			return template.Respond(r, match)
+			resp, err := template.Respond(r, match)
+			if err != nil {
+				return nil, err
+			}
+
+			// Only attribute the request once the expectation has answered it
+			r.Expectation = matched
+			return resp, nil
 		}
 
 		return nil, nil
 	}
 
-	return handler, nil
+	return handler, makeRegistrationConfigs(payload, expectation), nil
 }
 
-func loadHandlers(handlerSet handler.HandlerSet, path string) error {
-	schema, err := getSchema()
-	if err != nil {
-		return err
+func makeRegistrationConfigs(payload *jsonHandler, expectation expectation.Expectation) []handler.RegistrationConfigFunc {
+	configs := []handler.RegistrationConfigFunc{
+		handler.WithPriority(payload.Priority),
+		handler.WithMethodFilter(expectation.MatchesMethod),
+		handler.WithPathPrefix(expectation.PathPrefix()),
 	}
 
-	infos, err := ioutil.ReadDir(path)
-	if err != nil {
-		return fmt.Errorf("failed to read config directory")
+	if payload.Position == "first" {
+		configs = append(configs, handler.WithPosition(handler.PositionFirst))
 	}
 
-	for _, info := range infos {
-		if !info.IsDir() {
-			handlers, err := makeHandlersFromPath(schema, path, info.Name())
-			if err != nil {
-				return fmt.Errorf("failed to load handlers from %s (%s)", info.Name(), err.Error())
-			}
-
-			for _, handler := range handlers {
-				handlerSet.Add(handler)
-			}
-		}
+	if payload.Fallback {
+		configs = append(configs, handler.WithFallback())
 	}
 
-	return nil
+	return configs
 }
 
-func getSchema() (*gojsonschema.Schema, error) {
-	data, err := loadYAML(filepath.Join(schemaPath, "handlers.yaml"))
+// compilePayloads validates a list of handler payloads against the given
+// schema and compiles each valid payload. Every problem is returned, ordered
+// by list index, with a pointer relative to the list. An error is returned
+// only if the schema could not be applied.
+func compilePayloads(schema *gojsonschema.Schema, data []byte, templateConfigs ...template.ConfigFunc) ([]*handler.Registration, ConfigErrors, error) {
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(data))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(data))
-	if err != nil {
-		return nil, err
+	errors := newSchemaErrors(result.Errors(), true)
+
+	payloads := []json.RawMessage{}
+	if err := json.Unmarshal(data, &payloads); err != nil {
+		return nil, errors, nil
 	}
 
-	return schema, nil
-}
+	invalid := map[int]struct{}{}
+	for _, err := range errors {
+		if err.Index != nil {
+			invalid[*err.Index] = struct{}{}
+		}
+	}
 
-func makeHandlersFromPath(schema *gojsonschema.Schema, segments ...string) ([]handler.Handler, error) {
-	data, err := loadYAML(segments...)
-	if err != nil {
-		return nil, err
+	registrations := []*handler.Registration{}
+	for i, payload := range payloads {
+		if _, ok := invalid[i]; ok {
+			continue
+		}
+
+		h, configs, err := makeHandler(payload, templateConfigs...)
+		if err != nil {
+			index := i
+			configError := err.(*ConfigError)
+			configError.Index = &index
+			configError.Pointer = fmt.Sprintf("/%d%s", i, configError.Pointer)
+			errors = append(errors, configError)
+			continue
+		}
+
+		registrations = append(registrations, handler.NewRegistration(h, configs...))
 	}
 
-	result, err := schema.Validate(gojsonschema.NewStringLoader(string(data)))
-	if err != nil {
-		return nil, err
+	if len(errors) > 0 {
+		sort.SliceStable(errors, func(i, j int) bool {
+			if errors[i].Index == nil || errors[j].Index == nil {
+				return errors[i].Index == nil && errors[j].Index != nil
+			}
+
+			return *errors[i].Index < *errors[j].Index
+		})
+
+		return nil, errors, nil
 	}
 
-	if !result.Valid() {
-		details := []string{}
-		for _, err := range result.Errors() {
-			details = append(details, fmt.Sprintf("%s: %s", err.Field(), err.Description()))
+	return registrations, nil, nil
+}
+
+// convertDocument translates a document in a supported third-party format
+// (OpenAPI 3, HAR, Pact, or Postman Collection v2.1) into a list of handler
+// payloads. Any other document is assumed to be a list of handler payloads
+// and is returned unchanged.
+func convertDocument(data []byte) ([]byte, error) {
+	if openapi.IsDocument(data) {
+		handlers, err := openapi.Convert(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert OpenAPI document (%s)", err.Error())
 		}
 
-		return nil, fmt.Errorf("invalid config: %s", strings.Join(details, ", "))
+		return json.Marshal(handlers)
 	}
 
-	handlers, err := makeHandlers(data)
-	if err != nil {
-		return nil, err
+	if har.IsDocument(data) {
+		handlers, err := har.Convert(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert HAR document (%s)", err.Error())
+		}
+
+		return json.Marshal(handlers)
 	}
 
-	return handlers, nil
-}
+	if pact.IsDocument(data) {
+		handlers, err := pact.Convert(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert Pact contract (%s)", err.Error())
+		}
 
-func makeHandlers(input []byte) ([]handler.Handler, error) {
-	payloads := []json.RawMessage{}
-	if err := json.Unmarshal(input, &payloads); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal payload (%s)", err.Error())
+		return json.Marshal(handlers)
 	}
 
-	handlers := []handler.Handler{}
-	for _, payload := range payloads {
-		handler, err := makeHandler(payload)
+	if postman.IsDocument(data) {
+		handlers, err := postman.Convert(data)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to convert Postman collection (%s)", err.Error())
 		}
 
-		handlers = append(handlers, handler)
+		return json.Marshal(handlers)
 	}
 
-	return handlers, nil
+	return data, nil
 }
 
 func loadYAML(segments ...string) ([]byte, error) {
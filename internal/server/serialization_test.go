@@ -1,22 +1,38 @@
 package server
 
 import (
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"github.com/aphistic/sweet"
 	"github.com/efritz/derision/internal/handler"
 	"github.com/efritz/derision/internal/request"
+	"github.com/efritz/derision/internal/schema"
+	"github.com/efritz/response"
 	. "github.com/onsi/gomega"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 type SerializationSuite struct{}
 
-func init() {
-	schemaPath = "../../schemas"
+func getTestSchema() (*gojsonschema.Schema, error) {
+	_, handlers, err := schema.Load("")
+	return handlers, err
+}
+
+func loadTestHandlers(handlerSet handler.HandlerSet, path string) error {
+	schema, err := getTestSchema()
+	if err != nil {
+		return err
+	}
+
+	return loadHandlers(handlerSet, schema, path)
 }
 
 func (s *SerializationSuite) TestMakeHandler(t sweet.T) {
-	handler, err := makeHandler([]byte(`{
+	handler, _, err := makeHandler([]byte(`{
 		"request": {
 			"method": "POST",
 			"path": "/test"
@@ -39,30 +55,123 @@ func (s *SerializationSuite) TestMakeHandler(t sweet.T) {
 	Expect(resp).To(BeNil())
 }
 
+func (s *SerializationSuite) TestMakeHandlerPriority(t sweet.T) {
+	handlers := handler.NewHandlerSet()
+
+	for _, payload := range []string{
+		`{"request": {}, "response": {"status_code": "200"}}`,
+		`{"request": {}, "response": {"status_code": "201"}, "priority": 5}`,
+		`{"request": {}, "response": {"status_code": "202"}, "priority": 5}`,
+		`{"request": {}, "response": {"status_code": "203"}, "priority": 5, "position": "first"}`,
+	} {
+		h, configs, err := makeHandler([]byte(payload))
+		Expect(err).To(BeNil())
+		handlers.Add(h, configs...)
+	}
+
+	resp, err := handlers.Handle(&request.Request{Method: "GET", Path: "/test"})
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode()).To(Equal(http.StatusNonAuthoritativeInfo))
+}
+
+func (s *SerializationSuite) TestMakeHandlerFallback(t sweet.T) {
+	handlers := handler.NewHandlerSet()
+
+	for _, payload := range []string{
+		`{"request": {}, "response": {"status_code": "501", "body": "no match for {{.Method}} {{.Path}}"}, "fallback": true}`,
+		`{"request": {"path": "/test"}, "response": {"status_code": "200"}}`,
+	} {
+		h, configs, err := makeHandler([]byte(payload))
+		Expect(err).To(BeNil())
+		handlers.Add(h, configs...)
+	}
+
+	resp, err := handlers.Handle(&request.Request{Method: "GET", Path: "/test"})
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+
+	resp, err = handlers.Handle(&request.Request{Method: "GET", Path: "/other"})
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode()).To(Equal(http.StatusNotImplemented))
+
+	_, body, err := response.Serialize(resp)
+	Expect(err).To(BeNil())
+	Expect(string(body)).To(Equal("no match for GET /other"))
+}
+
+func (s *SerializationSuite) TestMakeHandlerRecordsExpectation(t sweet.T) {
+	h, _, err := makeHandler([]byte(`{"request": {"path": "^/test$", "headers": {"X-Foo": "bar"}}, "response": {}, "tags": ["a", "b"]}`))
+	Expect(err).To(BeNil())
+
+	r := &request.Request{Method: "GET", Path: "/other"}
+	_, err = h(r)
+	Expect(err).To(BeNil())
+	Expect(r.Expectation).To(BeNil())
+
+	r = &request.Request{Method: "GET", Path: "/test", Headers: map[string][]string{"X-Foo": {"bar"}}}
+	_, err = h(r)
+	Expect(err).To(BeNil())
+	Expect(r.Expectation).To(Equal(&request.Expectation{
+		Path:    "^/test$",
+		Headers: map[string]request.HeaderPattern{"X-Foo": {Pattern: "bar"}},
+		Tags:    []string{"a", "b"},
+	}))
+}
+
+func (s *SerializationSuite) TestMakeHandlerRecordsFallbackExpectation(t sweet.T) {
+	h, _, err := makeHandler([]byte(`{"request": {}, "response": {}, "fallback": true}`))
+	Expect(err).To(BeNil())
+
+	r := &request.Request{Method: "GET", Path: "/test"}
+	_, err = h(r)
+	Expect(err).To(BeNil())
+	Expect(r.Expectation).To(Equal(&request.Expectation{Fallback: true}))
+}
+
+func (s *SerializationSuite) TestMakeHandlerFailedTemplate(t sweet.T) {
+	h, _, err := makeHandler([]byte(`{"request": {"path": "^/test$"}, "response": {"body": "{{index .PathGroups 5}}"}}`))
+	Expect(err).To(BeNil())
+
+	r := &request.Request{Method: "GET", Path: "/test"}
+	_, err = h(r)
+	Expect(err).NotTo(BeNil())
+	Expect(r.Expectation).To(BeNil())
+}
+
+func (s *SerializationSuite) TestFilterByTag(t sweet.T) {
+	r1 := &request.Request{Path: "/a", Expectation: &request.Expectation{Tags: []string{"x", "y"}}}
+	r2 := &request.Request{Path: "/b", Expectation: &request.Expectation{Tags: []string{"y"}}}
+	r3 := &request.Request{Path: "/c"}
+
+	Expect(filterByTag([]*request.Request{r1, r2, r3}, "x")).To(Equal([]*request.Request{r1}))
+	Expect(filterByTag([]*request.Request{r1, r2, r3}, "y")).To(Equal([]*request.Request{r1, r2}))
+	Expect(filterByTag([]*request.Request{r1, r2, r3}, "z")).To(BeEmpty())
+}
+
 func (s *SerializationSuite) TestMakeHandlerBadRequest(t sweet.T) {
-	_, err := makeHandler([]byte(`{
+	_, _, err := makeHandler([]byte(`{
 		"request": {
 			"method": "("
 		},
 		"response": {}
 	}`))
 
-	Expect(err).To(MatchError("failed to unmarshal expectation (illegal method regex)"))
+	Expect(err).To(MatchError("/request/method: failed to unmarshal expectation (illegal method regex (error parsing regexp: missing closing ): `(`))"))
 }
 
 func (s *SerializationSuite) TestMakeHandlerBadResponse(t sweet.T) {
-	_, err := makeHandler([]byte(`{
+	_, _, err := makeHandler([]byte(`{
 		"request": {},
 		"response": {
 			"status_code": "{{"
 		}
 	}`))
 
-	Expect(err).To(MatchError("failed to unmarshal template (illegal status code template)"))
+	Expect(err).To(MatchError("/response/status_code: failed to unmarshal template (illegal status code template (template: :1: unclosed action))"))
 }
 
 func (s *SerializationSuite) TestMakeHandlerError(t sweet.T) {
-	handler, err := makeHandler([]byte(`{
+	handler, _, err := makeHandler([]byte(`{
 		"request": {},
 		"response": {
 			"body": "{{index .BodyGroups 3}}"
@@ -74,9 +183,66 @@ func (s *SerializationSuite) TestMakeHandlerError(t sweet.T) {
 	Expect(err).NotTo(BeNil())
 }
 
+func (s *SerializationSuite) TestCompilePayloads(t sweet.T) {
+	schema, err := getTestSchema()
+	Expect(err).To(BeNil())
+
+	registrations, errors, err := compilePayloads(schema, []byte(`[
+		{"request": {"path": "/a"}, "response": {"status_code": "201"}},
+		{"request": {"path": "/b"}, "response": {"status_code": "202"}}
+	]`))
+
+	Expect(err).To(BeNil())
+	Expect(errors).To(BeEmpty())
+	Expect(registrations).To(HaveLen(2))
+
+	handlers := handler.NewHandlerSet()
+	handlers.Set(registrations)
+
+	resp, err := handlers.Handle(&request.Request{Method: "GET", Path: "/b"})
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode()).To(Equal(http.StatusAccepted))
+}
+
+func (s *SerializationSuite) TestCompilePayloadsInvalid(t sweet.T) {
+	schema, err := getTestSchema()
+	Expect(err).To(BeNil())
+
+	registrations, errors, err := compilePayloads(schema, []byte(`[
+		{"request": {"path": "/a"}, "response": {}},
+		{"request": {"path": "/b"}},
+		{"request": {"method": "("}, "response": {}},
+		{"request": {}, "response": {"status_code": "abc"}}
+	]`))
+
+	Expect(err).To(BeNil())
+	Expect(registrations).To(BeNil())
+	Expect(errors).To(HaveLen(3))
+	Expect(errors[0].Pointer).To(Equal("/1"))
+	Expect(errors[1].Pointer).To(Equal("/2/request/method"))
+	Expect(errors[2].Pointer).To(Equal("/3/response/status_code"))
+
+	Expect(errors.byIndex()).To(Equal(map[string][]string{
+		"1": []string{"response is required"},
+		"2": []string{"request.method: failed to unmarshal expectation (illegal method regex (error parsing regexp: missing closing ): `(`))"},
+		"3": []string{"response.status_code: Does not match pattern '^\\d{3}$'"},
+	}))
+}
+
+func (s *SerializationSuite) TestCompilePayloadsNotList(t sweet.T) {
+	schema, err := getTestSchema()
+	Expect(err).To(BeNil())
+
+	_, errors, err := compilePayloads(schema, []byte(`{}`))
+	Expect(err).To(BeNil())
+	Expect(errors.byIndex()).To(Equal(map[string][]string{
+		"(root)": []string{"Invalid type. Expected: array, given: object"},
+	}))
+}
+
 func (s *SerializationSuite) TestMakeHandlersFromPath(t sweet.T) {
 	handlers := handler.NewHandlerSet()
-	err := loadHandlers(handlers, "./tests/valid")
+	err := loadTestHandlers(handlers, "./tests/valid")
 	Expect(err).To(BeNil())
 
 	resp, err := handlers.Handle(&request.Request{Method: "GET", Path: "/a1"})
@@ -92,26 +258,181 @@ func (s *SerializationSuite) TestMakeHandlersFromPath(t sweet.T) {
 	Expect(resp).To(BeNil())
 }
 
+func (s *SerializationSuite) TestMakeHandlersFromPathOpenAPI(t sweet.T) {
+	handlers := handler.NewHandlerSet()
+	err := loadTestHandlers(handlers, "./tests/openapi")
+	Expect(err).To(BeNil())
+
+	resp, err := handlers.Handle(&request.Request{Method: "GET", Path: "/v1/pets"})
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+
+	_, body, err := response.Serialize(resp)
+	Expect(err).To(BeNil())
+	Expect(body).To(MatchJSON(`[{"id": 0, "name": "Fido"}]`))
+
+	resp, err = handlers.Handle(&request.Request{
+		Method:  "GET",
+		Path:    "/v1/pets/123",
+		Headers: map[string][]string{"X-Api-Key": []string{"secret"}},
+	})
+
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+
+	headers, body, err := response.Serialize(resp)
+	Expect(err).To(BeNil())
+	Expect(headers.Get("Content-Type")).To(Equal("application/json"))
+	Expect(body).To(MatchJSON(`{"id": 1, "name": "Rex"}`))
+
+	resp, err = handlers.Handle(&request.Request{Method: "GET", Path: "/v1/pets/123"})
+	Expect(err).To(BeNil())
+	Expect(resp).To(BeNil())
+}
+
+func (s *SerializationSuite) TestConvertDocument(t sweet.T) {
+	data := []byte(`[{"request": {}, "response": {}}]`)
+	converted, err := convertDocument(data)
+	Expect(err).To(BeNil())
+	Expect(converted).To(Equal(data))
+
+	converted, err = convertDocument([]byte(`{"openapi": "3.0.0", "paths": {"/a": {"get": {}}}}`))
+	Expect(err).To(BeNil())
+	Expect(converted).To(MatchJSON(`[{"request": {"method": "^GET$", "path": "^/a$"}, "response": {"status_code": "200"}}]`))
+
+	_, err = convertDocument([]byte(`{"openapi": "3.0.0", "paths": {"/a": {"get": {"parameters": [{"$ref": "#/x"}]}}}}`))
+	Expect(err).To(MatchError("failed to convert OpenAPI document (failed to convert GET /a (unsupported reference #/x))"))
+
+	converted, err = convertDocument([]byte(`{"log": {"entries": [{"request": {"method": "GET", "url": "http://x/a"}, "response": {"status": 204}}]}}`))
+	Expect(err).To(BeNil())
+	Expect(converted).To(MatchJSON(`[{"request": {"method": "^GET$", "path": "^/a$"}, "response": {"status_code": "204"}}]`))
+
+	converted, err = convertDocument([]byte(`{
+		"info": {"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+		"item": [{"request": {"method": "GET", "url": "{{baseUrl}}/a/:id"}, "response": [{"code": 200, "body": "ok"}]}]
+	}`))
+	Expect(err).To(BeNil())
+	Expect(converted).To(MatchJSON(`[{"request": {"method": "^GET$", "path": "^/a/([^/]+)$"}, "response": {"status_code": "200", "body": "ok"}}]`))
+}
+
 func (s *SerializationSuite) TestMakeHandlersFromPathInvalidSchema(t sweet.T) {
 	handlers := handler.NewHandlerSet()
-	err := loadHandlers(handlers, "./tests/invalid-schema")
-	Expect(err).To(MatchError("failed to load handlers from bad.yaml (invalid config: 0: response is required)"))
+	err := loadTestHandlers(handlers, "./tests/invalid-schema")
+	Expect(err).To(MatchError("bad.yaml:1: /0: response is required"))
 }
 
 func (s *SerializationSuite) TestMakeHandlersFromPathInvalidTemplate(t sweet.T) {
 	handlers := handler.NewHandlerSet()
-	err := loadHandlers(handlers, "./tests/invalid-template")
-	Expect(err).To(MatchError("failed to load handlers from bad.yaml (failed to unmarshal template (illegal body template))"))
+	err := loadTestHandlers(handlers, "./tests/invalid-template")
+	Expect(err).To(MatchError("bad.yaml:3: /0/response/body: failed to unmarshal template (illegal body template (template: :1: unclosed action))"))
 }
 
 func (s *SerializationSuite) TestMakeHandlersFromPathInvalidYAML(t sweet.T) {
 	handlers := handler.NewHandlerSet()
-	err := loadHandlers(handlers, "./tests/invalid-yaml")
-	Expect(err).To(MatchError("failed to load handlers from bad.yaml (yaml: line 1: did not find expected node content)"))
+	err := loadTestHandlers(handlers, "./tests/invalid-yaml")
+	Expect(err).To(MatchError("bad.yaml:1: yaml: did not find expected node content"))
+}
+
+func (s *SerializationSuite) TestMakeHandlersFromPathCollectsErrors(t sweet.T) {
+	handlers := handler.NewHandlerSet()
+	handlers.Add(func(r *request.Request) (response.Response, error) {
+		return response.Empty(http.StatusTeapot), nil
+	})
+
+	err := loadTestHandlers(handlers, "./tests/invalid-mixed")
+	Expect(err).To(Equal(ConfigErrors{
+		{File: "a.yaml", Index: intPtr(1), Line: 6, Pointer: "/1/request/path", Message: "failed to unmarshal expectation (illegal path regex (error parsing regexp: missing closing ): `(`))"},
+		{File: "a.yaml", Index: intPtr(2), Line: 8, Pointer: "/2", Message: "request is required"},
+		{File: "b.yaml", Line: 1, Message: "yaml: did not find expected node content"},
+	}))
+
+	// The handler set is not modified
+	resp, err := handlers.Handle(&request.Request{Method: "GET", Path: "/a"})
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode()).To(Equal(http.StatusTeapot))
+}
+
+func (s *SerializationSuite) TestMakeHandlersFromPathNested(t sweet.T) {
+	files, err := configFiles("./tests/nested")
+	Expect(err).To(BeNil())
+	Expect(files).To(Equal([]string{"a.yaml", "users/b.yml"}))
+
+	handlers := handler.NewHandlerSet()
+	Expect(loadTestHandlers(handlers, "./tests/nested")).To(BeNil())
+
+	for path, status := range map[string]int{
+		"/a1":     http.StatusCreated,
+		"/a2":     http.StatusAccepted,
+		"/health": http.StatusNoContent,
+		"/users":  http.StatusOK,
+	} {
+		Expect(handleStatus(handlers, path)).To(Equal(status))
+	}
+}
+
+func (s *SerializationSuite) TestMakeHandlersFromPathDocumentLines(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	writeConfig(dir, "a.yaml", "---\n- request: {}\n  response: {}\n--- # second\n- request:\n    path: (\n  response: {}\n---\n[")
+
+	err = loadTestHandlers(handler.NewHandlerSet(), dir)
+	Expect(err).To(HaveLen(2))
+	Expect(err.(ConfigErrors)[0].Line).To(Equal(6))
+	Expect(err.(ConfigErrors)[0].Pointer).To(Equal("/0/request/path"))
+	Expect(err.(ConfigErrors)[1].Line).To(Equal(9))
+}
+
+func (s *SerializationSuite) TestMakeHandlersFromPathIncludeErrors(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	writeConfig(dir, "a.yaml", "- request: {}\n  response: {}\n- include: _b.yaml\n- include: missing.yaml\n")
+	writeConfig(dir, "_b.yaml", "- include: _c.yaml\n")
+	writeConfig(dir, "_c.yaml", "- include: _b.yaml\n- request: {path: '('}\n  response: {}\n")
+
+	err = loadTestHandlers(handler.NewHandlerSet(), dir)
+	Expect(err).To(Equal(ConfigErrors{
+		{File: "_c.yaml", Index: intPtr(1), Line: 2, Pointer: "/1/request/path", Message: "failed to unmarshal expectation (illegal path regex (error parsing regexp: missing closing ): `(`))"},
+		{File: "_c.yaml", Index: intPtr(0), Line: 1, Pointer: "/0/include", Message: "include cycle (a.yaml -> _b.yaml -> _c.yaml -> _b.yaml)"},
+		{File: "a.yaml", Index: intPtr(2), Line: 4, Pointer: "/2/include", Message: "failed to read include (stat " + filepath.Join(dir, "missing.yaml") + ": no such file or directory)"},
+	}))
+}
+
+func (s *SerializationSuite) TestMakeHandlersFromPathBodyFile(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	writeConfig(dir, "_fixtures/user.json", `{"id": 42}`)
+	writeConfig(dir, "users/a.yaml", "- request: {path: ^/users/42$}\n  response: {body_file: _fixtures/user.json}\n")
+
+	handlers := handler.NewHandlerSet()
+	Expect(loadTestHandlers(handlers, dir)).To(BeNil())
+
+	resp, err := handlers.Handle(&request.Request{Method: "GET", Path: "/users/42"})
+	Expect(err).To(BeNil())
+
+	headers, body, err := response.Serialize(resp)
+	Expect(err).To(BeNil())
+	Expect(headers.Get("Content-Type")).To(Equal("application/json"))
+	Expect(body).To(MatchJSON(`{"id": 42}`))
+
+	writeConfig(dir, "users/a.yaml", "- request: {path: ^/users/42$}\n  response: {body_file: _fixtures/missing.json}\n")
+
+	err = loadTestHandlers(handlers, dir)
+	Expect(err).To(HaveLen(1))
+	Expect(err.(ConfigErrors)[0].Pointer).To(Equal("/0/response/body_file"))
 }
 
 func (s *SerializationSuite) TestMakeHandlersFromPathInvalidPath(t sweet.T) {
 	handlers := handler.NewHandlerSet()
-	err := loadHandlers(handlers, "./tests/missing")
+	err := loadTestHandlers(handlers, "./tests/missing")
 	Expect(err).To(MatchError("failed to read config directory"))
 }
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -1,32 +1,47 @@
 package server
 
 import (
-	"net/http"
+	"time"
 
 	"github.com/efritz/chevron"
 	"github.com/efritz/chevron/middleware"
 	"github.com/efritz/derision/internal/handler"
 	"github.com/efritz/derision/internal/request"
+	"github.com/efritz/derision/internal/schema"
 	"github.com/efritz/nacelle"
 	basehttp "github.com/efritz/nacelle/base/http"
-	"github.com/efritz/response"
-	"github.com/xeipuuv/gojsonschema"
 )
 
 type Server struct {
 	Services      nacelle.ServiceContainer `service:"container"`
+	Logger        nacelle.Logger           `service:"logger"`
 	wrappedServer nacelle.Process
+	watcher       *configWatcher
+	pollInterval  time.Duration
+	done          chan struct{}
 }
 
 func NewServer() *Server {
-	return &Server{}
+	return &Server{
+		done: make(chan struct{}),
+	}
 }
 
 func (s *Server) Init(config nacelle.Config) error {
-	if err := setupDataStructures(config, s.Services); err != nil {
+	serverConfig := &Config{}
+	if err := config.Load(serverConfig); err != nil {
+		return err
+	}
+
+	if err := setupDataStructures(serverConfig, s.Services); err != nil {
 		return err
 	}
 
+	if watcher, err := s.Services.Get("config-watcher"); err == nil {
+		s.watcher = watcher.(*configWatcher)
+		s.pollInterval = serverConfig.ConfigPollInterval
+	}
+
 	catchAllHandler := &CatchAllHandler{}
 	if err := s.Services.Inject(catchAllHandler); err != nil {
 		return err
@@ -42,28 +57,49 @@ func (s *Server) Init(config nacelle.Config) error {
 }
 
 func (s *Server) Start() error {
+	if s.watcher != nil && s.pollInterval > 0 {
+		go s.watcher.watch(s.pollInterval, s.Logger, s.done)
+	}
+
 	return s.wrappedServer.Start()
 }
 
 func (s *Server) Stop() error {
+	close(s.done)
 	return s.wrappedServer.Stop()
 }
 
-func setupDataStructures(config nacelle.Config, services nacelle.ServiceContainer) error {
-	serverConfig := &Config{}
-	if err := config.Load(serverConfig); err != nil {
+// setupDataStructures registers the handler set, request log, payload
+// schemas, config watcher, and contract into the service container. The
+// handlers of the config directory are loaded before returning.
+func setupDataStructures(serverConfig *Config, services nacelle.ServiceContainer) error {
+	handlerSchema, handlersSchema, err := schema.Load(serverConfig.SchemaPath)
+	if err != nil {
 		return err
 	}
 
-	handlerSet := handler.NewHandlerSet()
-	requestLog := request.NewLog(serverConfig.RequestLogCapacity)
+	handlerSet := handler.NewHandlerSet(handler.WithTieBreak(serverConfig.TieBreak))
+	requestLog := request.NewLog(
+		serverConfig.RequestLogCapacity,
+		request.WithMaxBytes(serverConfig.RequestLogMaxBytes),
+		request.WithMaxBodySize(serverConfig.MaxLoggedBodySize),
+	)
 
 	if serverConfig.ConfigDir != "" {
-		if err := loadHandlers(handlerSet, serverConfig.ConfigDir); err != nil {
+		watcher := newConfigWatcher(handlerSet, handlersSchema, serverConfig.ConfigDir)
+		if err := watcher.reload(true); err != nil {
+			return err
+		}
+
+		if err := services.Set("config-watcher", watcher); err != nil {
 			return err
 		}
 	}
 
+	if err := services.Set("server-config", serverConfig); err != nil {
+		return err
+	}
+
 	if err := services.Set("request-log", requestLog); err != nil {
 		return err
 	}
@@ -72,6 +108,30 @@ func setupDataStructures(config nacelle.Config, services nacelle.ServiceContaine
 		return err
 	}
 
+	if err := services.Set("handler-schema", handlerSchema); err != nil {
+		return err
+	}
+
+	if err := services.Set("schema", handlersSchema); err != nil {
+		return err
+	}
+
+	if serverConfig.ContractPath != "" {
+		validator, err := loadValidator(serverConfig.ContractPath)
+		if err != nil {
+			return err
+		}
+
+		contract := &Contract{
+			Validator:      validator,
+			RejectsInvalid: serverConfig.RejectsInvalid,
+		}
+
+		if err := services.Set("contract", contract); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -80,19 +140,13 @@ func newServer(
 	services nacelle.ServiceContainer,
 	catchAllHandler chevron.Handler,
 ) (nacelle.Process, error) {
-	setupRoutes := func(config nacelle.Config, router chevron.Router) error {
-		router.AddMiddleware(middleware.NewLogging())
-		router.AddMiddleware(NewControlMiddleware(catchAllHandler))
-
-		router.MustRegister("/clear", &ClearResource{})
-		router.MustRegister("/register", &RegisterResource{}, makeSchemaMiddleware())
-		router.MustRegister("/requests", &RequestsResource{})
-		router.MustRegister("/sse", &SSEResource{})
+	routeInitializer := func(config nacelle.Config, router chevron.Router) error {
+		setupRoutes(router, catchAllHandler)
 		return nil
 	}
 
 	server := basehttp.NewServer(chevron.NewInitializer(
-		chevron.RouteInitializerFunc(setupRoutes),
+		chevron.RouteInitializerFunc(routeInitializer),
 		chevron.WithNotFoundHandler(catchAllHandler),
 	))
 
@@ -107,27 +161,16 @@ func newServer(
 	return server, nil
 }
 
-func makeSchemaMiddleware() chevron.MiddlewareConfigFunc {
-	schemaOptions := []middleware.SchemaConfigFunc{
-		middleware.WithSchemaUnprocessableEntityFactory(unprocessableEntityFactory),
-	}
-
-	return chevron.WithMiddlewareFor(
-		middleware.NewSchemaMiddleware("./schemas/handler.yaml", schemaOptions...),
-		chevron.MethodPost,
-	)
-}
-
-func unprocessableEntityFactory(errors []gojsonschema.ResultError) response.Response {
-	details := map[string]string{}
-	for _, err := range errors {
-		details[err.Field()] = err.Description()
-	}
-
-	resp := response.JSON(map[string]interface{}{
-		"error": details,
-	})
-
-	resp.SetStatusCode(http.StatusUnprocessableEntity)
-	return resp
+func setupRoutes(router chevron.Router, catchAllHandler chevron.Handler) {
+	router.AddMiddleware(middleware.NewLogging())
+	router.AddMiddleware(NewControlMiddleware(catchAllHandler))
+
+	router.MustRegister("/clear", &ClearResource{})
+	router.MustRegister("/expectations", &ExpectationsResource{})
+	router.MustRegister("/import", &ImportResource{})
+	router.MustRegister("/pact", &PactResource{})
+	router.MustRegister("/register", &RegisterResource{})
+	router.MustRegister("/reload", &ReloadResource{})
+	router.MustRegister("/requests", &RequestsResource{})
+	router.MustRegister("/sse", &SSEResource{})
 }
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"github.com/efritz/derision/internal/schema"
+)
+
+// ValidateDir checks each file of the given configuration directory against
+// the handler schema and compiles each expectation and template. A problem
+// is described for each invalid value, prefixed by the file name and (for
+// YAML files) the line number, followed by the JSON pointer of the value. If
+// schemaPath is non-empty, the handler schema is read from that file instead
+// of the schema compiled into the binary. An error is returned only if the
+// directory or the schema cannot be read.
+func ValidateDir(schemaPath, path string) ([]string, error) {
+	_, schema, err := schema.Load(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	_, errors, err := loadDir(schema, path)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []string{}
+	for _, err := range errors {
+		problems = append(problems, err.Error())
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type ValidateSuite struct{}
+
+func (s *ValidateSuite) TestValidateDir(t sweet.T) {
+	problems, err := ValidateDir("", "./tests/valid")
+	Expect(err).To(BeNil())
+	Expect(problems).To(BeEmpty())
+}
+
+func (s *ValidateSuite) TestValidateDirInvalid(t sweet.T) {
+	problems, err := ValidateDir("", "./tests/invalid-mixed")
+	Expect(err).To(BeNil())
+	Expect(problems).To(Equal([]string{
+		"a.yaml:6: /1/request/path: failed to unmarshal expectation (illegal path regex (error parsing regexp: missing closing ): `(`))",
+		"a.yaml:8: /2: request is required",
+		"b.yaml:1: yaml: did not find expected node content",
+	}))
+}
+
+func (s *ValidateSuite) TestValidateDirSchemaOverride(t sweet.T) {
+	problems, err := ValidateDir("", "./tests/invalid-schema")
+	Expect(err).To(BeNil())
+	Expect(problems).To(Equal([]string{"bad.yaml:1: /0: response is required"}))
+
+	// The override schema does not require a response, so the entry
+	// is rejected only once the missing template fails to compile
+	problems, err = ValidateDir("../schema/tests/override.yaml", "./tests/invalid-schema")
+	Expect(err).To(BeNil())
+	Expect(problems).To(Equal([]string{
+		"bad.yaml:1: /0/response: failed to unmarshal template (failed to unmarshal payload (unexpected end of JSON input))",
+	}))
+}
+
+func (s *ValidateSuite) TestValidateDirMissing(t sweet.T) {
+	_, err := ValidateDir("", "./tests/missing")
+	Expect(err).To(MatchError("failed to read config directory"))
+}
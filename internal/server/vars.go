@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/efritz/derision/internal/payload"
+	"github.com/ghodss/yaml"
+)
+
+// variables maps the names of the variables defined in the vars blocks of
+// the config directory to their values.
+type variables map[string]string
+
+var variablePattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_.]*)(:-[^}]*)?\}`)
+
+// loadVariables collects the variables defined in the vars blocks of every
+// file of the given config directory, including fragments. A vars block is a
+// document of the form {"vars": {"name": "value"}}. The values of a vars block
+// may reference environment variables, but not other variables.
+func loadVariables(root string) (variables, ConfigErrors, error) {
+	names, err := listFiles(root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vars := variables{}
+	definitions := map[string]string{}
+	errors := ConfigErrors{}
+
+	for _, name := range names {
+		content, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			errors = append(errors, &ConfigError{File: name, Message: err.Error()})
+			continue
+		}
+
+		for _, document := range splitDocuments(content) {
+			// Unparseable documents are reported when they are loaded
+			data, err := yaml.YAMLToJSON(document.content)
+			if err != nil {
+				continue
+			}
+
+			block, ok := parseVarsBlock(data)
+			if !ok {
+				continue
+			}
+
+			lines := indexLines(document.content)
+			errorAt := func(pointer, message string) {
+				configError := &ConfigError{File: name, Pointer: pointer, Message: message}
+				if line := lines.lookup(pointer); line > 0 {
+					configError.Line = line + document.offset
+				}
+
+				errors = append(errors, configError)
+			}
+
+			values := map[string]interface{}{}
+			if err := json.Unmarshal(block, &values); err != nil {
+				errorAt("/vars", "vars must be a map from names to values")
+				continue
+			}
+
+			keys := []string{}
+			for key := range values {
+				keys = append(keys, key)
+			}
+
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				pointer := payload.Pointer("vars", key)
+
+				value, ok := formatScalar(values[key])
+				if !ok {
+					errorAt(pointer, fmt.Sprintf("value of variable %s is not a scalar", key))
+					continue
+				}
+
+				value, err := variables(nil).expand(value)
+				if err != nil {
+					errorAt(pointer, err.Error())
+					continue
+				}
+
+				if file, ok := definitions[key]; ok && vars[key] != value {
+					errorAt(pointer, fmt.Sprintf("variable %s is already defined in %s", key, file))
+					continue
+				}
+
+				vars[key] = value
+				definitions[key] = name
+			}
+		}
+	}
+
+	return vars, errors, nil
+}
+
+// parseVarsBlock returns the content of a vars block if the given document
+// is one.
+func parseVarsBlock(data []byte) (json.RawMessage, bool) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil || len(fields) != 1 {
+		return nil, false
+	}
+
+	block, ok := fields["vars"]
+	return block, ok
+}
+
+func formatScalar(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+
+	return "", false
+}
+
+// interpolate expands the references in each string value of the given
+// JSON document. Each error refers to the string that could not be expanded.
+func (v variables) interpolate(data []byte) ([]byte, ConfigErrors) {
+	if !bytes.Contains(data, []byte("${")) {
+		return data, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, ConfigErrors{{Message: err.Error()}}
+	}
+
+	errors := ConfigErrors{}
+	value = v.interpolateValue(value, "", &errors)
+
+	if len(errors) > 0 {
+		if _, ok := value.([]interface{}); ok {
+			for _, err := range errors {
+				token := strings.SplitN(strings.TrimPrefix(err.Pointer, "/"), "/", 2)[0]
+				if index, convErr := strconv.Atoi(token); convErr == nil {
+					err.Index = &index
+				}
+			}
+		}
+
+		return nil, errors
+	}
+
+	interpolated, err := json.Marshal(value)
+	if err != nil {
+		return nil, ConfigErrors{{Message: err.Error()}}
+	}
+
+	return interpolated, nil
+}
+
+func (v variables) interpolateValue(value interface{}, pointer string, errors *ConfigErrors) interface{} {
+	switch typed := value.(type) {
+	case string:
+		expanded, err := v.expand(typed)
+		if err != nil {
+			*errors = append(*errors, &ConfigError{Pointer: pointer, Message: err.Error()})
+			return typed
+		}
+
+		return expanded
+
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = v.interpolateValue(item, pointer+payload.Pointer(strconv.Itoa(i)), errors)
+		}
+
+	case map[string]interface{}:
+		keys := []string{}
+		for key := range typed {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			typed[key] = v.interpolateValue(typed[key], pointer+payload.Pointer(key), errors)
+		}
+	}
+
+	return value
+}
+
+// expand replaces each reference in the given text. A reference is either
+// ${NAME}, the value of an environment variable, or ${vars.name}, the value
+// of a variable. A reference may give a default (${NAME:-default}) that is
+// used if the value is unset or empty. The sequence $${ is replaced by ${.
+//
+// A reference to an undefined variable is an error, but a reference to an
+// unset environment variable without a default is left as it is. Configs
+// written before interpolation existed may contain text such as a template
+// literal (`${name}`) that was never meant as a reference.
+func (v variables) expand(text string) (string, error) {
+	undefined := []string{}
+
+	expanded := variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+
+		groups := variablePattern.FindStringSubmatch(match)
+		value, ok := v.lookup(groups[1])
+
+		if value != "" || (ok && groups[2] == "") {
+			return value
+		}
+
+		if groups[2] != "" {
+			return groups[2][2:]
+		}
+
+		if strings.HasPrefix(groups[1], "vars.") {
+			undefined = append(undefined, groups[1])
+		}
+
+		return match
+	})
+
+	if len(undefined) > 0 {
+		return "", fmt.Errorf("undefined variable %s", strings.Join(undefined, ", "))
+	}
+
+	return expanded, nil
+}
+
+func (v variables) lookup(name string) (string, bool) {
+	if strings.HasPrefix(name, "vars.") {
+		value, ok := v[name[len("vars."):]]
+		return value, ok
+	}
+
+	return os.LookupEnv(name)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/internal/handler"
+	. "github.com/onsi/gomega"
+)
+
+type VarsSuite struct{}
+
+func (s *VarsSuite) TestExpand(t sweet.T) {
+	os.Setenv("DERISION_TEST_HOST", "api.test")
+	os.Setenv("DERISION_TEST_EMPTY", "")
+	defer os.Unsetenv("DERISION_TEST_HOST")
+	defer os.Unsetenv("DERISION_TEST_EMPTY")
+
+	vars := variables{"tenant": "acme"}
+
+	for text, expected := range map[string]string{
+		"https://${DERISION_TEST_HOST}/a":       "https://api.test/a",
+		"${DERISION_TEST_MISSING:-fallback}":    "fallback",
+		"${DERISION_TEST_EMPTY:-fallback}":      "fallback",
+		"[${DERISION_TEST_EMPTY}]":              "[]",
+		"${DERISION_TEST_MISSING:-}":            "",
+		"/tenants/${vars.tenant}":               "/tenants/acme",
+		"${vars.missing:-default}":              "default",
+		"$${DERISION_TEST_HOST} ^/a$ {{.Path}}": "${DERISION_TEST_HOST} ^/a$ {{.Path}}",
+		"${DERISION_TEST_MISSING}":              "${DERISION_TEST_MISSING}",
+		"`Hello, ${user.name}!`":                "`Hello, ${user.name}!`",
+	} {
+		expanded, err := vars.expand(text)
+		Expect(err).To(BeNil())
+		Expect(expanded).To(Equal(expected))
+	}
+
+	_, err := vars.expand("${DERISION_TEST_MISSING} ${vars.missing} ${vars.other}")
+	Expect(err).To(MatchError("undefined variable vars.missing, vars.other"))
+}
+
+func (s *VarsSuite) TestInterpolate(t sweet.T) {
+	vars := variables{"host": "api.test"}
+
+	data, errors := vars.interpolate([]byte(`[{"request": {"headers": {"Host": "^${vars.host}$"}}, "priority": 10000000000000000001}]`))
+	Expect(errors).To(BeEmpty())
+	Expect(data).To(MatchJSON(`[{"request": {"headers": {"Host": "^api.test$"}}, "priority": 10000000000000000001}]`))
+
+	_, errors = vars.interpolate([]byte(`[{}, {"response": {"body": "${vars.missing}"}}]`))
+	Expect(errors).To(Equal(ConfigErrors{
+		{Index: intPtr(1), Pointer: "/1/response/body", Message: "undefined variable vars.missing"},
+	}))
+}
+
+func (s *VarsSuite) TestLoadDir(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	os.Setenv("DERISION_TEST_TENANT", "acme")
+	defer os.Unsetenv("DERISION_TEST_TENANT")
+
+	writeConfig(dir, "_vars.yaml", "vars:\n  tenant: ${DERISION_TEST_TENANT:-default}\n  status: 201\n")
+	writeConfig(dir, "a.yaml", "- request:\n    path: ^/tenants/${vars.tenant}$\n  response:\n    status_code: '${vars.status}'\n")
+
+	handlers := handler.NewHandlerSet()
+	Expect(loadTestHandlers(handlers, dir)).To(BeNil())
+	Expect(handleStatus(handlers, "/tenants/acme")).To(Equal(http.StatusCreated))
+}
+
+func (s *VarsSuite) TestLoadDirLiteralReferences(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	// A config written before interpolation existed still loads
+	writeConfig(dir, "a.yaml", "- request:\n    path: ^/a$\n  response:\n    body: 'const x = `${y}`'\n")
+
+	handlers := handler.NewHandlerSet()
+	Expect(loadTestHandlers(handlers, dir)).To(BeNil())
+	Expect(handleStatus(handlers, "/a")).To(Equal(http.StatusOK))
+}
+
+func (s *VarsSuite) TestLoadDirInvalid(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	writeConfig(dir, "a.yaml", "vars:\n  tenant: a\n---\n- request:\n    path: ${vars.other}\n  response: {}\n")
+	writeConfig(dir, "b.yaml", "vars:\n  tenant: b\n  list: [1]\n")
+
+	err = loadTestHandlers(handler.NewHandlerSet(), dir)
+	Expect(err).To(Equal(ConfigErrors{
+		{File: "b.yaml", Line: 3, Pointer: "/vars/list", Message: "value of variable list is not a scalar"},
+		{File: "b.yaml", Line: 2, Pointer: "/vars/tenant", Message: "variable tenant is already defined in a.yaml"},
+	}))
+
+	writeConfig(dir, "b.yaml", "vars:\n  tenant: a\n")
+
+	err = loadTestHandlers(handler.NewHandlerSet(), dir)
+	Expect(err).To(Equal(ConfigErrors{
+		{File: "a.yaml", Index: intPtr(0), Line: 5, Pointer: "/0/request/path", Message: "undefined variable vars.other"},
+	}))
+}
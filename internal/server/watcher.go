@@ -0,0 +1,140 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/efritz/derision/internal/handler"
+	"github.com/efritz/nacelle"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+type (
+	// configWatcher loads the handlers defined in the config directory and
+	// replaces them whenever the content of the directory changes. Handlers
+	// registered through the control endpoints are not affected.
+	configWatcher struct {
+		handlerSet  handler.HandlerSet
+		schema      *gojsonschema.Schema
+		path        string
+		fingerprint string
+		status      *ReloadStatus
+		mutex       sync.Mutex
+	}
+
+	// ReloadStatus describes the most recent load of the config directory.
+	// If the last attempt was invalid, the errors are listed and the handlers
+	// of the last successful load remain in place.
+	ReloadStatus struct {
+		LoadedAt  time.Time    `json:"loaded_at"`
+		CheckedAt time.Time    `json:"checked_at"`
+		Errors    ConfigErrors `json:"errors,omitempty"`
+	}
+)
+
+// configSource is the source of the registrations loaded from the config
+// directory within the handler set.
+const configSource = "config"
+
+func newConfigWatcher(handlerSet handler.HandlerSet, schema *gojsonschema.Schema, path string) *configWatcher {
+	return &configWatcher{
+		handlerSet: handlerSet,
+		schema:     schema,
+		path:       path,
+		status:     &ReloadStatus{},
+	}
+}
+
+// reload loads the config directory if its content has changed since the
+// last attempt (or unconditionally if force is true). If the directory is
+// invalid, the current handlers are kept and the error is returned.
+func (w *configWatcher) reload(force bool) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	now := time.Now()
+	w.status.CheckedAt = now
+
+	fingerprint, err := fingerprintDir(w.path)
+	if err != nil {
+		w.status.Errors = ConfigErrors{{Message: err.Error()}}
+		return err
+	}
+
+	if fingerprint == w.fingerprint && !force {
+		return nil
+	}
+
+	w.fingerprint = fingerprint
+
+	if err := loadHandlers(w.handlerSet, w.schema, w.path); err != nil {
+		if errors, ok := err.(ConfigErrors); ok {
+			w.status.Errors = errors
+		} else {
+			w.status.Errors = ConfigErrors{{Message: err.Error()}}
+		}
+
+		return err
+	}
+
+	w.status.LoadedAt = now
+	w.status.Errors = nil
+	return nil
+}
+
+// watch polls the config directory at the given interval until the done
+// channel is closed.
+func (w *configWatcher) watch(interval time.Duration, logger nacelle.Logger, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.reload(false); err != nil {
+				logger.Error("failed to reload config directory, keeping previous expectations (%s)", err.Error())
+			}
+
+		case <-done:
+			return
+		}
+	}
+}
+
+// Status returns a copy of the status of the most recent load.
+func (w *configWatcher) Status() *ReloadStatus {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	status := *w.status
+	return &status
+}
+
+// fingerprintDir hashes the names and content of the files of the given
+// config directory, including fragments and body files (which may have any
+// extension). Included files outside of the directory are not watched.
+func fingerprintDir(path string) (string, error) {
+	names, err := walkFiles(path, func(string) bool { return true })
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	for _, name := range names {
+		content, err := ioutil.ReadFile(filepath.Join(path, filepath.FromSlash(name)))
+		if err != nil {
+			return "", err
+		}
+
+		hash.Write([]byte(name))
+		hash.Write([]byte{0})
+		hash.Write(content)
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
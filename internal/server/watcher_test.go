@@ -0,0 +1,169 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/internal/handler"
+	"github.com/efritz/derision/internal/request"
+	"github.com/efritz/nacelle"
+	"github.com/efritz/response"
+	. "github.com/onsi/gomega"
+)
+
+type WatcherSuite struct{}
+
+func (s *WatcherSuite) TestReload(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	writeConfig(dir, "a.yaml", `[{"request": {"path": "^/a$"}, "response": {"status_code": "200"}}]`)
+
+	handlers, watcher := makeTestWatcher(dir)
+	Expect(watcher.reload(true)).To(BeNil())
+	loadedAt := watcher.Status().LoadedAt
+	Expect(loadedAt.IsZero()).To(BeFalse())
+
+	handlers.Add(func(r *request.Request) (response.Response, error) {
+		if r.Path == "/runtime" {
+			return response.Empty(http.StatusTeapot), nil
+		}
+
+		return nil, nil
+	})
+
+	// Unchanged directory
+	Expect(watcher.reload(false)).To(BeNil())
+	Expect(watcher.Status().LoadedAt).To(Equal(loadedAt))
+
+	writeConfig(dir, "a.yaml", `[{"request": {"path": "^/b$"}, "response": {"status_code": "201"}}]`)
+	Expect(watcher.reload(false)).To(BeNil())
+
+	Expect(handleStatus(handlers, "/a")).To(Equal(0))
+	Expect(handleStatus(handlers, "/b")).To(Equal(http.StatusCreated))
+	Expect(handleStatus(handlers, "/runtime")).To(Equal(http.StatusTeapot))
+}
+
+func (s *WatcherSuite) TestReloadKeepsOrder(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	writeConfig(dir, "a.yaml", `[{"request": {"path": "^/a$"}, "response": {"status_code": "200"}}]`)
+
+	handlers, watcher := makeTestWatcher(dir)
+	Expect(watcher.reload(true)).To(BeNil())
+
+	// A runtime catch-all registered after the config was loaded
+	handlers.Add(func(r *request.Request) (response.Response, error) {
+		return response.Empty(http.StatusTeapot), nil
+	})
+
+	Expect(handleStatus(handlers, "/a")).To(Equal(http.StatusOK))
+	Expect(handleStatus(handlers, "/other")).To(Equal(http.StatusTeapot))
+
+	writeConfig(dir, "a.yaml", `[{"request": {"path": "^/a$"}, "response": {"status_code": "201"}}]`)
+	Expect(watcher.reload(false)).To(BeNil())
+
+	Expect(handleStatus(handlers, "/a")).To(Equal(http.StatusCreated))
+	Expect(handleStatus(handlers, "/other")).To(Equal(http.StatusTeapot))
+}
+
+func (s *WatcherSuite) TestReloadBodyFile(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	writeConfig(dir, "_fixtures/a.txt", "foo")
+	writeConfig(dir, "a.yaml", `[{"request": {"path": "^/a$"}, "response": {"body_file": "_fixtures/a.txt"}}]`)
+
+	handlers, watcher := makeTestWatcher(dir)
+	Expect(watcher.reload(true)).To(BeNil())
+
+	resp, err := handlers.Handle(&request.Request{Method: "GET", Path: "/a"})
+	Expect(err).To(BeNil())
+	_, body, err := response.Serialize(resp)
+	Expect(err).To(BeNil())
+	Expect(string(body)).To(Equal("foo"))
+
+	// Only the body file changes
+	writeConfig(dir, "_fixtures/a.txt", "bar")
+	Expect(watcher.reload(false)).To(BeNil())
+
+	resp, err = handlers.Handle(&request.Request{Method: "GET", Path: "/a"})
+	Expect(err).To(BeNil())
+	_, body, err = response.Serialize(resp)
+	Expect(err).To(BeNil())
+	Expect(string(body)).To(Equal("bar"))
+}
+
+func (s *WatcherSuite) TestReloadInvalid(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	writeConfig(dir, "a.yaml", `[{"request": {"path": "^/a$"}, "response": {"status_code": "200"}}]`)
+
+	handlers, watcher := makeTestWatcher(dir)
+	Expect(watcher.reload(true)).To(BeNil())
+
+	writeConfig(dir, "a.yaml", `[{"request": {"path": "("}, "response": {}}]`)
+	Expect(watcher.reload(false)).NotTo(BeNil())
+	Expect(handleStatus(handlers, "/a")).To(Equal(http.StatusOK))
+
+	status := watcher.Status()
+	Expect(status.Errors).To(HaveLen(1))
+	Expect(status.Errors[0].File).To(Equal("a.yaml"))
+	Expect(status.Errors[0].Pointer).To(Equal("/0/request/path"))
+
+	writeConfig(dir, "a.yaml", `[]`)
+	Expect(watcher.reload(false)).To(BeNil())
+	Expect(watcher.Status().Errors).To(BeEmpty())
+	Expect(handleStatus(handlers, "/a")).To(Equal(0))
+}
+
+func (s *WatcherSuite) TestWatch(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	handlers, watcher := makeTestWatcher(dir)
+	Expect(watcher.reload(true)).To(BeNil())
+
+	done := make(chan struct{})
+	defer close(done)
+	go watcher.watch(time.Millisecond*10, nacelle.NewNilLogger(), done)
+
+	writeConfig(dir, "a.yaml", `[{"request": {"path": "^/a$"}, "response": {"status_code": "200"}}]`)
+	Eventually(func() int { return handleStatus(handlers, "/a") }).Should(Equal(http.StatusOK))
+}
+
+func makeTestWatcher(dir string) (handler.HandlerSet, *configWatcher) {
+	schema, err := getTestSchema()
+	Expect(err).To(BeNil())
+
+	handlers := handler.NewHandlerSet()
+	return handlers, newConfigWatcher(handlers, schema, dir)
+}
+
+func writeConfig(dir, name, content string) {
+	path := filepath.Join(dir, name)
+	Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(BeNil())
+	Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(BeNil())
+}
+
+func handleStatus(handlers handler.HandlerSet, path string) int {
+	resp, err := handlers.Handle(&request.Request{Method: "GET", Path: path})
+	Expect(err).To(BeNil())
+
+	if resp == nil {
+		return 0
+	}
+
+	return resp.StatusCode()
+}
@@ -1,35 +1,99 @@
 package template
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	tmpl "text/template"
+
+	"github.com/efritz/derision/internal/payload"
+)
+
+type (
+	jsonTemplate struct {
+		StatusCode       string              `json:"status_code"`
+		Headers          map[string][]string `json:"headers"`
+		Body             string              `json:"body"`
+		BodyFile         string              `json:"body_file"`
+		BodyFileTemplate bool                `json:"body_file_template"`
+		BodyBase64       string              `json:"body_base64"`
+	}
+
+	unmarshalConfig struct {
+		fileRoot string
+	}
+
+	// ConfigFunc configures how a template is unmarshalled.
+	ConfigFunc func(*unmarshalConfig)
+)
+
+var (
+	errConflictingBody = fmt.Errorf("only one of body, body_file, and body_base64 may be set")
+	errNoFileRoot      = fmt.Errorf("body files require a config directory")
+	errAbsoluteFile    = fmt.Errorf("path must be relative to the config directory")
+	errEscapingFile    = fmt.Errorf("path must not leave the config directory")
 )
 
-type jsonTemplate struct {
-	StatusCode string              `json:"status_code"`
-	Headers    map[string][]string `json:"headers"`
-	Body       string              `json:"body"`
+// CompileError describes a field of a template that does not compile.
+type CompileError struct {
+	// Pointer is the JSON pointer of the field within the template.
+	Pointer string
+	Message string
+	Err     error
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Message, e.Err.Error())
+}
+
+// WithFileRoot sets the directory against which a body_file path is
+// resolved. A body file may not be outside of this directory, and a template
+// with a body file is rejected if no directory is set.
+func WithFileRoot(path string) ConfigFunc {
+	return func(c *unmarshalConfig) { c.fileRoot = path }
 }
 
-func Unmarshal(payload []byte) (Template, error) {
+func Unmarshal(data []byte, configs ...ConfigFunc) (Template, error) {
+	config := &unmarshalConfig{}
+	for _, f := range configs {
+		f(config)
+	}
+
 	t := &jsonTemplate{}
-	if err := json.Unmarshal(payload, &t); err != nil {
+	if err := json.Unmarshal(data, &t); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal payload (%s)", err.Error())
 	}
 
 	statusCode, err := compile(t.StatusCode)
 	if err != nil {
-		return nil, fmt.Errorf("illegal status code template")
+		return nil, &CompileError{Pointer: "/status_code", Message: "illegal status code template", Err: err}
+	}
+
+	names := []string{}
+	for name := range t.Headers {
+		names = append(names, name)
 	}
 
+	sort.Strings(names)
+
 	headers := map[string][]*tmpl.Template{}
-	for name, values := range t.Headers {
+	for _, name := range names {
 		templates := []*tmpl.Template{}
-		for _, value := range values {
+		for i, value := range t.Headers[name] {
 			template, err := compile(value)
 			if err != nil {
-				return nil, fmt.Errorf("illegal header template")
+				return nil, &CompileError{
+					Pointer: payload.Pointer("headers", name, strconv.Itoa(i)),
+					Message: "illegal header template",
+					Err:     err,
+				}
 			}
 
 			templates = append(templates, template)
@@ -38,16 +102,115 @@ func Unmarshal(payload []byte) (Template, error) {
 		headers[name] = templates
 	}
 
-	body, err := compile(t.Body)
-	if err != nil {
-		return nil, fmt.Errorf("illegal body template")
-	}
-
-	return &template{
+	template := &template{
 		statusCode: statusCode,
 		headers:    headers,
-		body:       body,
-	}, nil
+	}
+
+	if err := unmarshalBody(t, config, template); err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// unmarshalBody sets the body of the template from exactly one of the body
+// template, the body file, or the base64-encoded body. A file or base64 body
+// is served verbatim (unless the file is marked as a template), and its
+// content type is inferred if the template does not set one.
+func unmarshalBody(t *jsonTemplate, config *unmarshalConfig, template *template) error {
+	set := 0
+	for _, value := range []string{t.Body, t.BodyFile, t.BodyBase64} {
+		if value != "" {
+			set++
+		}
+	}
+
+	if set > 1 {
+		return &CompileError{Pointer: "", Message: "illegal body", Err: errConflictingBody}
+	}
+
+	switch {
+	case t.BodyFile != "":
+		path, err := resolveFile(config.fileRoot, t.BodyFile)
+		if err != nil {
+			return &CompileError{Pointer: "/body_file", Message: "illegal body file", Err: err}
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return &CompileError{Pointer: "/body_file", Message: "illegal body file", Err: err}
+		}
+
+		if t.BodyFileTemplate {
+			body, err := compile(string(content))
+			if err != nil {
+				return &CompileError{Pointer: "/body_file", Message: "illegal body file template", Err: err}
+			}
+
+			template.body = body
+		} else {
+			template.rawBody = content
+		}
+
+		template.contentType = inferContentType(t.Headers, path, content)
+
+	case t.BodyBase64 != "":
+		content, err := base64.StdEncoding.DecodeString(t.BodyBase64)
+		if err != nil {
+			return &CompileError{Pointer: "/body_base64", Message: "illegal base64 body", Err: err}
+		}
+
+		template.rawBody = content
+		template.contentType = inferContentType(t.Headers, "", content)
+
+	default:
+		body, err := compile(t.Body)
+		if err != nil {
+			return &CompileError{Pointer: "/body", Message: "illegal body template", Err: err}
+		}
+
+		template.body = body
+	}
+
+	return nil
+}
+
+// resolveFile returns the path of the given body file within the root
+// directory. Paths that are absolute or that leave the root are rejected so
+// that a payload registered at runtime cannot read arbitrary files.
+func resolveFile(root, name string) (string, error) {
+	if root == "" {
+		return "", errNoFileRoot
+	}
+
+	if filepath.IsAbs(name) {
+		return "", errAbsoluteFile
+	}
+
+	path := filepath.Join(root, name)
+	if rel, err := filepath.Rel(filepath.Clean(root), path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errEscapingFile
+	}
+
+	return path, nil
+}
+
+// inferContentType returns the content type of a body read from the given
+// path (by its extension) or, failing that, sniffed from its content. An
+// empty string is returned if the headers already set a content type.
+func inferContentType(headers map[string][]string, path string, content []byte) string {
+	for name := range headers {
+		if http.CanonicalHeaderKey(name) == "Content-Type" {
+			return ""
+		}
+	}
+
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		return contentType
+	}
+
+	return http.DetectContentType(content)
 }
 
 func compile(template string) (*tmpl.Template, error) {
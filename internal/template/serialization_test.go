@@ -1,6 +1,7 @@
 package template
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/aphistic/sweet"
@@ -62,15 +63,93 @@ func (s *SerializationSuite) TestBadJSON(t sweet.T) {
 
 func (s *SerializationSuite) TestBadStatusCodeTemplate(t sweet.T) {
 	_, err := Unmarshal([]byte(`{"status_code": "{{"}`))
-	Expect(err).To(MatchError("illegal status code template"))
+	Expect(err).To(MatchError("illegal status code template (template: :1: unclosed action)"))
+	Expect(err.(*CompileError).Pointer).To(Equal("/status_code"))
 }
 
 func (s *SerializationSuite) TestHeaderPathTemplate(t sweet.T) {
-	_, err := Unmarshal([]byte(`{"headers": {"Authorization": ["{{"]}}`))
-	Expect(err).To(MatchError("illegal header template"))
+	_, err := Unmarshal([]byte(`{"headers": {"Authorization": ["ok", "{{"]}}`))
+	Expect(err).To(MatchError("illegal header template (template: :1: unclosed action)"))
+	Expect(err.(*CompileError).Pointer).To(Equal("/headers/Authorization/1"))
 }
 
 func (s *SerializationSuite) TestBadBodyTemplate(t sweet.T) {
 	_, err := Unmarshal([]byte(`{"body": "{{"}`))
-	Expect(err).To(MatchError("illegal body template"))
+	Expect(err).To(MatchError("illegal body template (template: :1: unclosed action)"))
+	Expect(err.(*CompileError).Pointer).To(Equal("/body"))
+}
+
+func (s *SerializationSuite) TestBodyFile(t sweet.T) {
+	tmpl, err := Unmarshal([]byte(`{"body_file": "user.json"}`), WithFileRoot("./tests"))
+	Expect(err).To(BeNil())
+
+	headers, body := respond(tmpl)
+	Expect(headers.Get("Content-Type")).To(Equal("application/json"))
+	Expect(string(body)).To(Equal("{\"id\": 1, \"name\": \"{{.Path}}\"}\n"))
+
+	tmpl, err = Unmarshal([]byte(`{"body_file": "greeting.tmpl", "body_file_template": true}`), WithFileRoot("./tests"))
+	Expect(err).To(BeNil())
+
+	headers, body = respond(tmpl)
+	Expect(headers.Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
+	Expect(string(body)).To(Equal("hello /test"))
+}
+
+func (s *SerializationSuite) TestBodyFilePaths(t sweet.T) {
+	tmpl, err := Unmarshal([]byte(`{"body_file": "./sub/../user.json"}`), WithFileRoot("./tests"))
+	Expect(err).To(BeNil())
+	Expect(tmpl).NotTo(BeNil())
+
+	for name, message := range map[string]string{
+		"/etc/passwd":         "illegal body file (path must be relative to the config directory)",
+		"../serialization.go": "illegal body file (path must not leave the config directory)",
+		"sub/../../main.go":   "illegal body file (path must not leave the config directory)",
+		"..":                  "illegal body file (path must not leave the config directory)",
+	} {
+		_, err := Unmarshal([]byte(fmt.Sprintf(`{"body_file": %q}`, name)), WithFileRoot("./tests"))
+		Expect(err).To(MatchError(message))
+		Expect(err.(*CompileError).Pointer).To(Equal("/body_file"))
+	}
+
+	_, err = Unmarshal([]byte(`{"body_file": "tests/user.json"}`))
+	Expect(err).To(MatchError("illegal body file (body files require a config directory)"))
+}
+
+func (s *SerializationSuite) TestBodyBase64(t sweet.T) {
+	tmpl, err := Unmarshal([]byte(`{"body_base64": "iVBORw0KGgoAAAANSUhEUg=="}`))
+	Expect(err).To(BeNil())
+
+	headers, body := respond(tmpl)
+	Expect(headers.Get("Content-Type")).To(Equal("image/png"))
+	Expect(body).To(Equal([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")))
+
+	tmpl, err = Unmarshal([]byte(`{"body_base64": "AAEC", "headers": {"content-type": ["application/x-protobuf"]}}`))
+	Expect(err).To(BeNil())
+
+	headers, body = respond(tmpl)
+	Expect(headers["Content-Type"]).To(Equal([]string{"application/x-protobuf"}))
+	Expect(body).To(Equal([]byte{0, 1, 2}))
+}
+
+func (s *SerializationSuite) TestBadBody(t sweet.T) {
+	_, err := Unmarshal([]byte(`{"body": "a", "body_base64": "AAEC"}`))
+	Expect(err).To(MatchError("illegal body (only one of body, body_file, and body_base64 may be set)"))
+	Expect(err.(*CompileError).Pointer).To(Equal(""))
+
+	_, err = Unmarshal([]byte(`{"body_base64": "!"}`))
+	Expect(err).To(MatchError("illegal base64 body (illegal base64 data at input byte 0)"))
+	Expect(err.(*CompileError).Pointer).To(Equal("/body_base64"))
+
+	_, err = Unmarshal([]byte(`{"body_file": "missing.json"}`), WithFileRoot("./tests"))
+	Expect(err).To(MatchError("illegal body file (open tests/missing.json: no such file or directory)"))
+	Expect(err.(*CompileError).Pointer).To(Equal("/body_file"))
+}
+
+func respond(tmpl Template) (http.Header, []byte) {
+	resp, err := tmpl.Respond(&request.Request{Method: "GET", Path: "/test"}, &expectation.Match{})
+	Expect(err).To(BeNil())
+
+	headers, body, err := response.Serialize(resp)
+	Expect(err).To(BeNil())
+	return headers, body
 }
@@ -17,9 +17,11 @@ type (
 	}
 
 	template struct {
-		statusCode *tmpl.Template
-		headers    map[string][]*tmpl.Template
-		body       *tmpl.Template
+		statusCode  *tmpl.Template
+		headers     map[string][]*tmpl.Template
+		body        *tmpl.Template
+		rawBody     []byte
+		contentType string
 	}
 )
 
@@ -31,18 +33,30 @@ func (t *template) Respond(r *request.Request, m *expectation.Match) (response.R
 		"Path":         r.Path,
 		"Headers":      r.Headers,
 		"Body":         r.Body,
+		"Parts":        r.Parts,
 		"MethodGroups": m.MethodGroups,
 		"PathGroups":   m.PathGroups,
+		"QueryGroups":  m.QueryGroups,
 		"HeaderGroups": m.HeaderGroups,
 		"BodyGroups":   m.BodyGroups,
+
+		"HeaderValueGroups": m.HeaderValueGroups,
 	}
 
-	body, err := applyTemplate(t.body, args)
-	if err != nil {
-		return nil, err
+	body := t.rawBody
+	if t.body != nil {
+		rendered, err := applyTemplate(t.body, args)
+		if err != nil {
+			return nil, err
+		}
+
+		body = []byte(rendered)
 	}
 
-	resp := response.Respond([]byte(body))
+	resp := response.Respond(body)
+	if t.contentType != "" {
+		resp.SetHeader("Content-Type", t.contentType)
+	}
 
 	statusCode, err := applyTemplate(t.statusCode, args)
 	if err != nil {
@@ -53,6 +53,27 @@ func (s *TemplateSuite) TestRespond(t sweet.T) {
 	Expect(body).To(Equal([]byte("GET /status/202 :: foobar")))
 }
 
+func (s *TemplateSuite) TestRespondParts(t sweet.T) {
+	tmpl := &template{
+		statusCode: testCompile(``),
+		body:       testCompile(`{{range .Parts}}{{.Name}}={{.Filename}}({{.Size}}) {{end}}`),
+	}
+
+	r := &request.Request{
+		Parts: []*request.Part{
+			{Name: "files", Filename: "a.txt", Size: 3},
+			{Name: "files", Filename: "b.txt", Size: 5},
+		},
+	}
+
+	resp, err := tmpl.Respond(r, &expectation.Match{})
+	Expect(err).To(BeNil())
+
+	_, body, err := response.Serialize(resp)
+	Expect(err).To(BeNil())
+	Expect(body).To(Equal([]byte("files=a.txt(3) files=b.txt(5) ")))
+}
+
 func (s *TemplateSuite) TestRespondEmptyStatusCode(t sweet.T) {
 	tmpl := &template{
 		statusCode: testCompile(``),
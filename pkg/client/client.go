@@ -0,0 +1,210 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type (
+	// Client registers expectations with and inspects the requests made to
+	// a derision server through its control endpoints.
+	Client struct {
+		url        string
+		httpClient *http.Client
+	}
+
+	// ConfigFunc is a function used to configure a client.
+	ConfigFunc func(*Client)
+
+	// StatusError is returned when the server responds to a control request
+	// with an unexpected status code.
+	StatusError struct {
+		Method     string
+		Path       string
+		StatusCode int
+		Body       string
+	}
+)
+
+// NewClient creates a client for the derision server at the given base URL
+// (e.g. http://localhost:5000).
+func NewClient(url string, configs ...ConfigFunc) *Client {
+	c := &Client{
+		url:        strings.TrimSuffix(url, "/"),
+		httpClient: http.DefaultClient,
+	}
+
+	for _, f := range configs {
+		f(c)
+	}
+
+	return c
+}
+
+// WithHTTPClient sets the HTTP client used to make control requests.
+func WithHTTPClient(httpClient *http.Client) ConfigFunc {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d from %s %s (%s)", e.StatusCode, e.Method, e.Path, e.Body)
+}
+
+// Register adds an expectation to the server.
+func (c *Client) Register(ctx context.Context, expectation *Expectation) error {
+	return c.send(ctx, "POST", "/register", expectation, nil)
+}
+
+// SetExpectations atomically replaces all expectations of the server.
+func (c *Client) SetExpectations(ctx context.Context, expectations []*Expectation) error {
+	if expectations == nil {
+		expectations = []*Expectation{}
+	}
+
+	return c.send(ctx, "PUT", "/expectations", expectations, nil)
+}
+
+// Clear removes all expectations from the server.
+func (c *Client) Clear(ctx context.Context) error {
+	return c.send(ctx, "POST", "/clear", nil, nil)
+}
+
+// Requests returns the requests recorded by the server in the order they
+// were received. If clear is true, the request log is truncated.
+func (c *Client) Requests(ctx context.Context, clear bool) ([]*Request, error) {
+	path := "/requests"
+	if clear {
+		path += "?clear=true"
+	}
+
+	requests := []*Request{}
+	if err := c.send(ctx, "GET", path, nil, &requests); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// Import adds the expectations described by the given document. The document
+// may be a list of expectations or any format accepted by the /import endpoint
+// (e.g. an OpenAPI document or a HAR file), in either YAML or JSON.
+func (c *Client) Import(ctx context.Context, document []byte) error {
+	resp, err := c.do(ctx, "POST", "/import", bytes.NewReader(document))
+	if err != nil {
+		return err
+	}
+
+	_, err = c.read(ctx, resp, "POST", "/import")
+	return err
+}
+
+// Export returns the request log serialized in the given format (json or
+// har). If clear is true, the request log is truncated.
+func (c *Client) Export(ctx context.Context, format string, clear bool) ([]byte, error) {
+	query := url.Values{"format": []string{format}}
+	if clear {
+		query.Set("clear", "true")
+	}
+
+	path := "/requests?" + query.Encode()
+
+	resp, err := c.do(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.read(ctx, resp, "GET", path)
+}
+
+// send makes a control request with the JSON encoding of the given payload
+// (if non-nil) and decodes the response into the given target (if non-nil).
+func (c *Client) send(ctx context.Context, method, path string, payload, target interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		serialized, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to serialize payload (%s)", err.Error())
+		}
+
+		body = bytes.NewReader(serialized)
+	}
+
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	content, err := c.read(ctx, resp, method, path)
+	if err != nil {
+		return err
+	}
+
+	if target == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(content, target); err != nil {
+		return fmt.Errorf("failed to deserialize response (%s)", err.Error())
+	}
+
+	return nil
+}
+
+// read consumes the body of the given response. A non-2xx response is
+// returned as a StatusError.
+func (c *Client) read(ctx context.Context, resp *http.Response, method, path string) ([]byte, error) {
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, c.wrapError(ctx, fmt.Errorf("failed to read response (%s)", err.Error()))
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &StatusError{
+			Method:     method,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Body:       string(content),
+		}
+	}
+
+	return content, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request (%s)", err.Error())
+	}
+
+	req.Header.Set("X-Derision-Control", "true")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, c.wrapError(ctx, fmt.Errorf("failed to perform request (%s)", err.Error()))
+	}
+
+	return resp, nil
+}
+
+// wrapError returns the context's error if the context has been canceled
+// or has expired, so that callers can compare it to context.Canceled or
+// context.DeadlineExceeded. Otherwise, the given error is returned.
+func (c *Client) wrapError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
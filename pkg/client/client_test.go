@@ -0,0 +1,313 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type ClientSuite struct{}
+
+type (
+	// controlServer is a stand-in for the control endpoints of a derision
+	// server. It records each control request and serves canned responses.
+	controlServer struct {
+		*httptest.Server
+		mutex    sync.Mutex
+		calls    []*controlCall
+		status   int
+		response string
+		events   chan string
+	}
+
+	controlCall struct {
+		method  string
+		path    string
+		query   string
+		control string
+		body    string
+	}
+)
+
+func newControlServer() *controlServer {
+	s := &controlServer{
+		status: http.StatusNoContent,
+		events: make(chan string),
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *controlServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.mutex.Lock()
+	s.calls = append(s.calls, &controlCall{
+		method:  r.Method,
+		path:    r.URL.Path,
+		query:   r.URL.RawQuery,
+		control: r.Header.Get("X-Derision-Control"),
+		body:    string(body),
+	})
+	status, response := s.status, s.response
+	s.mutex.Unlock()
+
+	if r.URL.Path == "/sse" {
+		s.stream(w, r)
+		return
+	}
+
+	w.WriteHeader(status)
+	w.Write([]byte(response))
+}
+
+func (s *controlServer) stream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	for {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				return
+			}
+
+			fmt.Fprintf(w, "%s\n\n", event)
+			w.(http.Flusher).Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *controlServer) respond(status int, response string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.status, s.response = status, response
+}
+
+func (s *controlServer) getCalls() []*controlCall {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]*controlCall{}, s.calls...)
+}
+
+func (s *ClientSuite) TestRegister(t sweet.T) {
+	server := newControlServer()
+	defer server.Close()
+
+	err := NewClient(server.URL+"/").Register(context.Background(), NewExpectation().
+		Method("GET").
+		Path(`^/users/(\d+)$`).
+		Respond(NewTemplate().StatusCode(http.StatusCreated)))
+
+	Expect(err).To(BeNil())
+
+	calls := server.getCalls()
+	Expect(calls).To(HaveLen(1))
+	Expect(calls[0].method).To(Equal("POST"))
+	Expect(calls[0].path).To(Equal("/register"))
+	Expect(calls[0].control).To(Equal("true"))
+	Expect(calls[0].body).To(MatchJSON(`{
+		"request": {"method": "GET", "path": "^/users/(\\d+)$"},
+		"response": {"status_code": "201"}
+	}`))
+}
+
+func (s *ClientSuite) TestSetExpectations(t sweet.T) {
+	server := newControlServer()
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	Expect(client.SetExpectations(context.Background(), []*Expectation{NewExpectation().Path("^/a$")})).To(BeNil())
+	Expect(client.SetExpectations(context.Background(), nil)).To(BeNil())
+
+	calls := server.getCalls()
+	Expect(calls).To(HaveLen(2))
+	Expect(calls[0].method).To(Equal("PUT"))
+	Expect(calls[0].path).To(Equal("/expectations"))
+	Expect(calls[0].body).To(MatchJSON(`[{"request": {"path": "^/a$"}, "response": {}}]`))
+	Expect(calls[1].body).To(MatchJSON(`[]`))
+}
+
+func (s *ClientSuite) TestClear(t sweet.T) {
+	server := newControlServer()
+	defer server.Close()
+
+	Expect(NewClient(server.URL).Clear(context.Background())).To(BeNil())
+
+	calls := server.getCalls()
+	Expect(calls).To(HaveLen(1))
+	Expect(calls[0].method).To(Equal("POST"))
+	Expect(calls[0].path).To(Equal("/clear"))
+	Expect(calls[0].body).To(BeEmpty())
+}
+
+func (s *ClientSuite) TestRequests(t sweet.T) {
+	server := newControlServer()
+	defer server.Close()
+
+	server.respond(http.StatusOK, `[
+		{
+			"method": "GET",
+			"path": "/users/42",
+			"query": {"q": ["1"]},
+			"expectation": {"path": "^/users/(\\d+)$", "tags": ["users"]},
+			"response": {"status_code": 201, "body": "user 42"}
+		},
+		{
+			"method": "POST",
+			"path": "/other",
+			"body": "fo",
+			"body_truncated": true,
+			"response": {"status_code": 200, "body": "ba", "body_truncated": true}
+		},
+		{"method": "GET", "path": "/none"}
+	]`)
+
+	requests, err := NewClient(server.URL).Requests(context.Background(), true)
+	Expect(err).To(BeNil())
+	Expect(requests).To(HaveLen(3))
+	Expect(requests[0].Path).To(Equal("/users/42"))
+	Expect(requests[0].Query).To(Equal(map[string][]string{"q": {"1"}}))
+	Expect(requests[0].Expectation.Tags).To(Equal([]string{"users"}))
+	Expect(requests[0].Response.StatusCode).To(Equal(http.StatusCreated))
+	Expect(requests[0].Response.Body).To(Equal("user 42"))
+	Expect(requests[0].BodyTruncated).To(BeFalse())
+	Expect(requests[0].Response.BodyTruncated).To(BeFalse())
+	Expect(requests[1].Expectation).To(BeNil())
+	Expect(requests[1].BodyTruncated).To(BeTrue())
+	Expect(requests[1].Response.BodyTruncated).To(BeTrue())
+	Expect(requests[2].Expectation).To(BeNil())
+	Expect(requests[2].Response).To(BeNil())
+
+	_, err = NewClient(server.URL).Requests(context.Background(), false)
+	Expect(err).To(BeNil())
+
+	calls := server.getCalls()
+	Expect(calls).To(HaveLen(2))
+	Expect(calls[0].path).To(Equal("/requests"))
+	Expect(calls[0].query).To(Equal("clear=true"))
+	Expect(calls[1].query).To(BeEmpty())
+}
+
+func (s *ClientSuite) TestImport(t sweet.T) {
+	server := newControlServer()
+	defer server.Close()
+
+	Expect(NewClient(server.URL).Import(context.Background(), []byte("- request: {}\n  response: {}\n"))).To(BeNil())
+
+	calls := server.getCalls()
+	Expect(calls).To(HaveLen(1))
+	Expect(calls[0].method).To(Equal("POST"))
+	Expect(calls[0].path).To(Equal("/import"))
+	Expect(calls[0].body).To(Equal("- request: {}\n  response: {}\n"))
+}
+
+func (s *ClientSuite) TestExport(t sweet.T) {
+	server := newControlServer()
+	defer server.Close()
+
+	server.respond(http.StatusOK, `{"log": {"entries": []}}`)
+
+	content, err := NewClient(server.URL).Export(context.Background(), "har", true)
+	Expect(err).To(BeNil())
+	Expect(content).To(MatchJSON(`{"log": {"entries": []}}`))
+
+	calls := server.getCalls()
+	Expect(calls).To(HaveLen(1))
+	Expect(calls[0].path).To(Equal("/requests"))
+	Expect(calls[0].query).To(Equal("clear=true&format=har"))
+
+	server.respond(http.StatusBadRequest, "")
+
+	_, err = NewClient(server.URL).Export(context.Background(), "xml", false)
+	Expect(err).To(Equal(&StatusError{
+		Method:     "GET",
+		Path:       "/requests?format=xml",
+		StatusCode: http.StatusBadRequest,
+	}))
+}
+
+func (s *ClientSuite) TestStatusError(t sweet.T) {
+	server := newControlServer()
+	defer server.Close()
+
+	server.respond(http.StatusUnprocessableEntity, `{"error": {"0": ["illegal method regex"]}}`)
+
+	err := NewClient(server.URL).SetExpectations(context.Background(), []*Expectation{
+		NewExpectation().Method("("),
+	})
+
+	Expect(err).To(Equal(&StatusError{
+		Method:     "PUT",
+		Path:       "/expectations",
+		StatusCode: http.StatusUnprocessableEntity,
+		Body:       `{"error": {"0": ["illegal method regex"]}}`,
+	}))
+}
+
+func (s *ClientSuite) TestSubscribe(t sweet.T) {
+	server := newControlServer()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := NewClient(server.URL).Subscribe(ctx)
+	Expect(err).To(BeNil())
+
+	go func() {
+		server.events <- `data:not json`
+		server.events <- `data:{"method": "GET", "path": "/streamed"}`
+	}()
+
+	var request *Request
+	Eventually(ch, time.Second).Should(Receive(&request))
+	Expect(request.Method).To(Equal("GET"))
+	Expect(request.Path).To(Equal("/streamed"))
+
+	cancel()
+	Eventually(ch, time.Second).Should(BeClosed())
+}
+
+func (s *ClientSuite) TestSubscribeStreamEnds(t sweet.T) {
+	server := newControlServer()
+	defer server.Close()
+
+	ch, err := NewClient(server.URL).Subscribe(context.Background())
+	Expect(err).To(BeNil())
+
+	close(server.events)
+	Eventually(ch, time.Second).Should(BeClosed())
+}
+
+func (s *ClientSuite) TestContextCanceled(t sweet.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewClient("http://127.0.0.1:1").Requests(ctx, false)
+	Expect(err).To(Equal(context.Canceled))
+
+	_, err = NewClient("http://127.0.0.1:1").Subscribe(ctx)
+	Expect(err).To(Equal(context.Canceled))
+}
+
+func (s *ClientSuite) TestUnreachable(t sweet.T) {
+	err := NewClient("http://127.0.0.1:1").Clear(context.Background())
+	Expect(err).NotTo(BeNil())
+	Expect(strings.HasPrefix(err.Error(), "failed to perform request")).To(BeTrue())
+}
@@ -0,0 +1,304 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/efritz/derision/internal/payload"
+)
+
+type (
+	// Expectation builds the payload of an expectation. The method, path,
+	// query, header, and body values are regular expressions that a request must
+	// match. Omitted values match any request.
+	Expectation struct {
+		method   string
+		path     string
+		query    map[string]string
+		headers  map[string]HeaderPattern
+		body     string
+		encoding string
+		template *Template
+		priority int
+		first    bool
+		fallback bool
+		tags     []string
+	}
+
+	// Template builds the payload of a response template. The header values
+	// and body are Go templates rendered against the matched request.
+	Template struct {
+		statusCode       int
+		headers          map[string][]string
+		body             string
+		bodyFile         string
+		bodyFileTemplate bool
+		bodyBase64       string
+		err              error
+	}
+
+	jsonExpectation struct {
+		Request  jsonRequest   `json:"request"`
+		Response *jsonTemplate `json:"response"`
+		Priority int           `json:"priority,omitempty"`
+		Position string        `json:"position,omitempty"`
+		Fallback bool          `json:"fallback,omitempty"`
+		Tags     []string      `json:"tags,omitempty"`
+	}
+
+	jsonRequest struct {
+		Method  string                   `json:"method,omitempty"`
+		Path    string                   `json:"path,omitempty"`
+		Query   map[string]string        `json:"query,omitempty"`
+		Headers map[string]HeaderPattern `json:"headers,omitempty"`
+		Body    string                   `json:"body,omitempty"`
+
+		BodyEncoding string `json:"body_encoding,omitempty"`
+	}
+
+	jsonTemplate struct {
+		StatusCode       string              `json:"status_code,omitempty"`
+		Headers          map[string][]string `json:"headers,omitempty"`
+		Body             string              `json:"body,omitempty"`
+		BodyFile         string              `json:"body_file,omitempty"`
+		BodyFileTemplate bool                `json:"body_file_template,omitempty"`
+		BodyBase64       string              `json:"body_base64,omitempty"`
+	}
+)
+
+// NewExpectation creates an expectation that matches every request and
+// responds with an empty 200.
+func NewExpectation() *Expectation {
+	return &Expectation{
+		query:    map[string]string{},
+		headers:  map[string]HeaderPattern{},
+		template: NewTemplate(),
+	}
+}
+
+// Method sets the pattern that the request method must match.
+func (e *Expectation) Method(pattern string) *Expectation {
+	e.method = pattern
+	return e
+}
+
+// Path sets the pattern that the request path must match.
+func (e *Expectation) Path(pattern string) *Expectation {
+	e.path = pattern
+	return e
+}
+
+// Query sets the pattern that the first value of the given query parameter
+// must match.
+func (e *Expectation) Query(name, pattern string) *Expectation {
+	e.query[name] = pattern
+	return e
+}
+
+// Header sets the pattern that the first value of the given request header
+// must match.
+func (e *Expectation) Header(name, pattern string) *Expectation {
+	e.headers[http.CanonicalHeaderKey(name)] = HeaderPattern{Pattern: pattern}
+	return e
+}
+
+// HeaderAny sets the pattern that at least one value of the given request
+// header must match.
+func (e *Expectation) HeaderAny(name, pattern string) *Expectation {
+	e.headers[http.CanonicalHeaderKey(name)] = HeaderPattern{Pattern: pattern, Match: "any"}
+	return e
+}
+
+// HeaderAll sets the pattern that every value of the given request header
+// must match. The header must have at least one value.
+func (e *Expectation) HeaderAll(name, pattern string) *Expectation {
+	e.headers[http.CanonicalHeaderKey(name)] = HeaderPattern{Pattern: pattern, Match: "all"}
+	return e
+}
+
+// HeaderAt sets the pattern that the value at the given index of the given
+// request header must match.
+func (e *Expectation) HeaderAt(name string, index int, pattern string) *Expectation {
+	e.headers[http.CanonicalHeaderKey(name)] = HeaderPattern{Pattern: pattern, Index: &index}
+	return e
+}
+
+// HeaderAbsent requires that the request does not have the given header.
+func (e *Expectation) HeaderAbsent(name string) *Expectation {
+	e.headers[http.CanonicalHeaderKey(name)] = HeaderPattern{Absent: true}
+	return e
+}
+
+// Body sets the pattern that the request body must match.
+func (e *Expectation) Body(pattern string) *Expectation {
+	e.body = pattern
+	return e
+}
+
+// BodyEncoding sets the encoding (hex or base64) applied to the request body
+// before it is matched against the body pattern. This allows the pattern to
+// match the bytes of a binary body.
+func (e *Expectation) BodyEncoding(encoding string) *Expectation {
+	e.encoding = encoding
+	return e
+}
+
+// Respond sets the template used to respond to matching requests.
+func (e *Expectation) Respond(template *Template) *Expectation {
+	e.template = template
+	return e
+}
+
+// Priority sets the priority of the expectation. Expectations with a higher
+// priority are evaluated first.
+func (e *Expectation) Priority(priority int) *Expectation {
+	e.priority = priority
+	return e
+}
+
+// First places the expectation ahead of all other expectations with the
+// same priority.
+func (e *Expectation) First() *Expectation {
+	e.first = true
+	return e
+}
+
+// Fallback marks the expectation as a fallback, which is evaluated only
+// after all other expectations fail to match.
+func (e *Expectation) Fallback() *Expectation {
+	e.fallback = true
+	return e
+}
+
+// Tags labels the expectation. Tags are recorded on the requests that the
+// expectation matches.
+func (e *Expectation) Tags(tags ...string) *Expectation {
+	e.tags = append(e.tags, tags...)
+	return e
+}
+
+// MarshalJSON serializes the expectation as a registration payload.
+func (e *Expectation) MarshalJSON() ([]byte, error) {
+	template, err := e.template.payload()
+	if err != nil {
+		return nil, err
+	}
+
+	position := ""
+	if e.first {
+		position = "first"
+	}
+
+	return json.Marshal(jsonExpectation{
+		Request: jsonRequest{
+			Method:  e.method,
+			Path:    e.path,
+			Query:   e.query,
+			Headers: e.headers,
+			Body:    e.body,
+
+			BodyEncoding: e.encoding,
+		},
+		Response: template,
+		Priority: e.priority,
+		Position: position,
+		Fallback: e.fallback,
+		Tags:     e.tags,
+	})
+}
+
+// NewTemplate creates a template that responds with an empty 200.
+func NewTemplate() *Template {
+	return &Template{
+		headers: map[string][]string{},
+	}
+}
+
+// StatusCode sets the status code of the response.
+func (t *Template) StatusCode(statusCode int) *Template {
+	t.statusCode = statusCode
+	return t
+}
+
+// Header adds a value template for the given response header.
+func (t *Template) Header(name, value string) *Template {
+	name = http.CanonicalHeaderKey(name)
+	t.headers[name] = append(t.headers[name], value)
+	return t
+}
+
+// Body sets the body template of the response.
+func (t *Template) Body(body string) *Template {
+	t.setBody()
+	t.body = body
+	return t
+}
+
+// LiteralBody sets the body of the response. The body is sent verbatim,
+// even if it contains template actions.
+func (t *Template) LiteralBody(body string) *Template {
+	t.setBody()
+	t.body = payload.Literal(body)
+	return t
+}
+
+// BinaryBody sets the body of the response to the given bytes, which are
+// sent verbatim. The Content-Type header is sniffed from the body unless
+// it is set explicitly.
+func (t *Template) BinaryBody(body []byte) *Template {
+	t.setBody()
+	t.bodyBase64 = base64.StdEncoding.EncodeToString(body)
+	return t
+}
+
+// BodyFile sets the body of the response to the content of the file at the
+// given path on the server, relative to the server's config directory. The
+// content is sent verbatim unless render is true, in which case it is a body
+// template. The Content-Type header is inferred from the file extension
+// unless it is set explicitly.
+func (t *Template) BodyFile(path string, render bool) *Template {
+	t.setBody()
+	t.bodyFile = path
+	t.bodyFileTemplate = render
+	return t
+}
+
+func (t *Template) setBody() {
+	t.body, t.bodyFile, t.bodyFileTemplate, t.bodyBase64 = "", "", false, ""
+}
+
+// JSONBody sets the body of the response to the JSON encoding of the given
+// value and sets the Content-Type header. An encoding error is returned
+// when the expectation is registered.
+func (t *Template) JSONBody(v interface{}) *Template {
+	serialized, err := json.Marshal(v)
+	if err != nil {
+		t.err = err
+		return t
+	}
+
+	t.headers["Content-Type"] = []string{"application/json"}
+	return t.LiteralBody(string(serialized))
+}
+
+func (t *Template) payload() (*jsonTemplate, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	statusCode := ""
+	if t.statusCode != 0 {
+		statusCode = strconv.Itoa(t.statusCode)
+	}
+
+	return &jsonTemplate{
+		StatusCode:       statusCode,
+		Headers:          t.headers,
+		Body:             t.body,
+		BodyFile:         t.bodyFile,
+		BodyFileTemplate: t.bodyFileTemplate,
+		BodyBase64:       t.bodyBase64,
+	}, nil
+}
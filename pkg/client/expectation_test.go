@@ -0,0 +1,88 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/aphistic/sweet"
+	. "github.com/onsi/gomega"
+)
+
+type ExpectationSuite struct{}
+
+func (s *ExpectationSuite) TestMarshalDefault(t sweet.T) {
+	serialized, err := json.Marshal(NewExpectation())
+	Expect(err).To(BeNil())
+	Expect(serialized).To(MatchJSON(`{"request": {}, "response": {}}`))
+}
+
+func (s *ExpectationSuite) TestMarshal(t sweet.T) {
+	expectation := NewExpectation().
+		Method("^POST$").
+		Path(`^/users/(\d+)$`).
+		Query("dry_run", "^true$").
+		Header("x-api-key", ".").
+		HeaderAny("accept", "json").
+		HeaderAt("x-forwarded-for", 1, `^10\.`).
+		HeaderAbsent("x-debug").
+		Body("name").
+		Priority(3).
+		First().
+		Tags("users", "write").
+		Respond(NewTemplate().
+			StatusCode(201).
+			Header("x-id", "{{index .PathGroups 1}}").
+			Body("created"))
+
+	serialized, err := json.Marshal(expectation)
+	Expect(err).To(BeNil())
+	Expect(serialized).To(MatchJSON(`{
+		"request": {
+			"method": "^POST$",
+			"path": "^/users/(\\d+)$",
+			"query": {"dry_run": "^true$"},
+			"headers": {
+				"X-Api-Key": ".",
+				"Accept": {"pattern": "json", "match": "any"},
+				"X-Forwarded-For": {"pattern": "^10\\.", "index": 1},
+				"X-Debug": {"absent": true}
+			},
+			"body": "name"
+		},
+		"response": {
+			"status_code": "201",
+			"headers": {"X-Id": ["{{index .PathGroups 1}}"]},
+			"body": "created"
+		},
+		"priority": 3,
+		"position": "first",
+		"tags": ["users", "write"]
+	}`))
+}
+
+func (s *ExpectationSuite) TestMarshalJSONBody(t sweet.T) {
+	serialized, err := json.Marshal(NewExpectation().Fallback().Respond(NewTemplate().JSONBody(map[string]string{"a": "{{b}}"})))
+	Expect(err).To(BeNil())
+	Expect(serialized).To(MatchJSON(`{
+		"request": {},
+		"response": {
+			"headers": {"Content-Type": ["application/json"]},
+			"body": "{{\"{\\\"a\\\":\\\"{{b}}\\\"}\"}}"
+		},
+		"fallback": true
+	}`))
+}
+
+func (s *ExpectationSuite) TestMarshalBinaryBody(t sweet.T) {
+	serialized, err := json.Marshal(NewExpectation().Respond(NewTemplate().Body("ignored").BinaryBody([]byte{0, 1, 2})))
+	Expect(err).To(BeNil())
+	Expect(serialized).To(MatchJSON(`{"request": {}, "response": {"body_base64": "AAEC"}}`))
+
+	serialized, err = json.Marshal(NewExpectation().Respond(NewTemplate().BodyFile("users/42.json", true)))
+	Expect(err).To(BeNil())
+	Expect(serialized).To(MatchJSON(`{"request": {}, "response": {"body_file": "users/42.json", "body_file_template": true}}`))
+}
+
+func (s *ExpectationSuite) TestMarshalJSONBodyError(t sweet.T) {
+	_, err := json.Marshal(NewExpectation().Respond(NewTemplate().JSONBody(make(chan int))))
+	Expect(err).NotTo(BeNil())
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type (
+	// Request is a request received by the mock API, as recorded in the
+	// request log and published to the request stream.
+	Request struct {
+		Method        string              `json:"method"`
+		Host          string              `json:"host"`
+		Path          string              `json:"path"`
+		Query         map[string][]string `json:"query"`
+		Headers       map[string][]string `json:"headers"`
+		Body          string              `json:"body"`
+		RawBody       string              `json:"raw_body"`
+		Form          map[string][]string `json:"form"`
+		Files         map[string]string   `json:"files"`
+		RawFiles      map[string]string   `json:"raw_files"`
+		Parts         []*Part             `json:"parts,omitempty"`
+		BodyTruncated bool                `json:"body_truncated,omitempty"`
+		Encoding      string              `json:"encoding,omitempty"`
+		Binary        bool                `json:"binary"`
+		BodySize      int                 `json:"body_size"`
+		BodySHA256    string              `json:"body_sha256"`
+		Violations    []string            `json:"violations,omitempty"`
+		Timestamp     time.Time           `json:"timestamp"`
+		Expectation   *MatchedExpectation `json:"expectation,omitempty"`
+		Response      *Response           `json:"response,omitempty"`
+	}
+
+	// MatchedExpectation describes the expectation that matched a request.
+	MatchedExpectation struct {
+		Method  string                   `json:"method,omitempty"`
+		Path    string                   `json:"path,omitempty"`
+		Query   map[string]string        `json:"query,omitempty"`
+		Headers map[string]HeaderPattern `json:"headers,omitempty"`
+		Body    string                   `json:"body,omitempty"`
+		Tags    []string                 `json:"tags,omitempty"`
+
+		BodyEncoding string `json:"body_encoding,omitempty"`
+		Fallback     bool   `json:"fallback,omitempty"`
+	}
+
+	// HeaderPattern describes how the values of a request header are matched
+	// by an expectation. Pattern is matched against the first value unless
+	// Match is any or all, or Index selects another value. If Absent is true,
+	// the header must not be sent.
+	HeaderPattern struct {
+		Pattern string `json:"pattern,omitempty"`
+		Match   string `json:"match,omitempty"`
+		Index   *int   `json:"index,omitempty"`
+		Absent  bool   `json:"absent,omitempty"`
+	}
+
+	// Part is a part of a multipart/form-data request body. Filename is
+	// empty for parts that are not files.
+	Part struct {
+		Name        string              `json:"name"`
+		Filename    string              `json:"filename,omitempty"`
+		ContentType string              `json:"content_type,omitempty"`
+		Headers     map[string][]string `json:"headers"`
+		Content     string              `json:"content"`
+		RawContent  string              `json:"raw_content"`
+		Size        int                 `json:"size"`
+		SHA256      string              `json:"sha256"`
+		Truncated   bool                `json:"truncated,omitempty"`
+	}
+
+	// Response is the response that was sent for a request.
+	Response struct {
+		StatusCode    int                 `json:"status_code"`
+		Headers       map[string][]string `json:"headers"`
+		Body          string              `json:"body"`
+		RawBody       string              `json:"raw_body"`
+		BodyTruncated bool                `json:"body_truncated,omitempty"`
+		ElapsedMs     float64             `json:"elapsed_ms"`
+	}
+)
+
+// MarshalJSON serializes a pattern that matches the first value as a string.
+func (p HeaderPattern) MarshalJSON() ([]byte, error) {
+	if p.Match == "" && p.Index == nil && !p.Absent {
+		return json.Marshal(p.Pattern)
+	}
+
+	type plain HeaderPattern
+	return json.Marshal(plain(p))
+}
+
+// UnmarshalJSON accepts either a pattern or an object.
+func (p *HeaderPattern) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &p.Pattern); err == nil {
+		return nil
+	}
+
+	type plain HeaderPattern
+	return json.Unmarshal(data, (*plain)(p))
+}
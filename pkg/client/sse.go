@@ -0,0 +1,74 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+)
+
+// Subscribe opens the server's request stream. Each request made to the
+// server after this method returns is sent on the returned channel. The
+// channel is closed when the context is canceled or the stream ends.
+func (c *Client) Subscribe(ctx context.Context) (<-chan *Request, error) {
+	resp, err := c.do(ctx, "GET", "/sse", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		content, _ := ioutil.ReadAll(resp.Body)
+
+		return nil, &StatusError{
+			Method:     "GET",
+			Path:       "/sse",
+			StatusCode: resp.StatusCode,
+			Body:       string(content),
+		}
+	}
+
+	ch := make(chan *Request)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+		data := []string{}
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			if line != "" {
+				if strings.HasPrefix(line, "data:") {
+					data = append(data, strings.TrimPrefix(line, "data:"))
+				}
+
+				continue
+			}
+
+			if len(data) == 0 {
+				continue
+			}
+
+			request := &Request{}
+			err := json.Unmarshal([]byte(strings.Join(data, "\n")), request)
+			data = data[:0]
+
+			if err != nil {
+				continue
+			}
+
+			select {
+			case ch <- request:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
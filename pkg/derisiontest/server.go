@@ -0,0 +1,101 @@
+// Package derisiontest runs a derision mock API in-process for Go tests.
+package derisiontest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+
+	"github.com/efritz/derision/internal/server"
+	"github.com/efritz/derision/pkg/client"
+)
+
+type (
+	// Server is a derision mock API served by an httptest server. The mock
+	// API and the control endpoints are both served at the server's URL.
+	Server struct {
+		*httptest.Server
+		handler *server.Handler
+	}
+
+	// ConfigFunc is a function used to configure a server.
+	ConfigFunc func(*server.Config)
+)
+
+// NewServer creates and starts a server. The server should be closed once
+// the test completes.
+func NewServer(configs ...ConfigFunc) (*Server, error) {
+	serverConfig := &server.Config{
+		MaxBodySize: server.DefaultMaxBodySize,
+		MaxPartSize: server.DefaultMaxPartSize,
+	}
+	for _, f := range configs {
+		f(serverConfig)
+	}
+
+	handler, err := server.NewHandler(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		Server:  httptest.NewServer(handler),
+		handler: handler,
+	}, nil
+}
+
+// WithConfigDir sets the directory from which expectations are loaded
+// when the server starts.
+func WithConfigDir(path string) ConfigFunc {
+	return func(c *server.Config) { c.ConfigDir = path }
+}
+
+// WithRequestLogCapacity sets the maximum number of requests retained by
+// the request log. A capacity of zero retains all requests.
+func WithRequestLogCapacity(capacity int) ConfigFunc {
+	return func(c *server.Config) { c.RequestLogCapacity = capacity }
+}
+
+// Add registers an expectation.
+func (s *Server) Add(expectation *client.Expectation) error {
+	payload, err := json.Marshal(expectation)
+	if err != nil {
+		return fmt.Errorf("failed to serialize expectation (%s)", err.Error())
+	}
+
+	return s.handler.Add(payload)
+}
+
+// Clear removes all expectations.
+func (s *Server) Clear() {
+	s.handler.HandlerSet.Clear()
+}
+
+// Requests returns the requests received by the mock API in the order they
+// were received. If clear is true, the request log is truncated.
+func (s *Server) Requests(clear bool) ([]*client.Request, error) {
+	serialized, err := json.Marshal(s.handler.RequestLog.Copy(clear))
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize requests (%s)", err.Error())
+	}
+
+	requests := []*client.Request{}
+	if err := json.Unmarshal(serialized, &requests); err != nil {
+		return nil, fmt.Errorf("failed to deserialize requests (%s)", err.Error())
+	}
+
+	return requests, nil
+}
+
+// Client returns a client for the server's control endpoints.
+func (s *Server) Client() *client.Client {
+	return client.NewClient(s.URL, client.WithHTTPClient(s.Server.Client()))
+}
+
+// Close closes all open connections (including request stream subscribers)
+// and shuts down the server.
+func (s *Server) Close() {
+	s.CloseClientConnections()
+	s.Server.Close()
+	s.handler.Close()
+}
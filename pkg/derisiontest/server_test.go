@@ -0,0 +1,260 @@
+package derisiontest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/pkg/client"
+	. "github.com/onsi/gomega"
+)
+
+type ServerSuite struct{}
+
+func get(url string) (int, string) {
+	resp, err := http.Get(url)
+	Expect(err).To(BeNil())
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	Expect(err).To(BeNil())
+	return resp.StatusCode, string(body)
+}
+
+func (s *ServerSuite) TestAdd(t sweet.T) {
+	server, err := NewServer()
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	Expect(server.Add(client.NewExpectation().
+		Method("GET").
+		Path(`^/users/(\d+)$`).
+		Tags("users").
+		Respond(client.NewTemplate().
+			StatusCode(http.StatusCreated).
+			Body("user {{index .PathGroups 1}}")))).To(BeNil())
+
+	status, body := get(server.URL + "/users/42?q=1")
+	Expect(status).To(Equal(http.StatusCreated))
+	Expect(body).To(Equal("user 42"))
+
+	status, _ = get(server.URL + "/other")
+	Expect(status).To(Equal(http.StatusNotFound))
+
+	requests, err := server.Requests(true)
+	Expect(err).To(BeNil())
+	Expect(requests).To(HaveLen(2))
+	Expect(requests[0].Path).To(Equal("/users/42"))
+	Expect(requests[0].Query).To(Equal(map[string][]string{"q": {"1"}}))
+	Expect(requests[0].Expectation.Tags).To(Equal([]string{"users"}))
+	Expect(requests[0].Response.StatusCode).To(Equal(http.StatusCreated))
+	Expect(requests[0].Response.Body).To(Equal("user 42"))
+	Expect(requests[1].Expectation).To(BeNil())
+	Expect(requests[1].Response.StatusCode).To(Equal(http.StatusNotFound))
+
+	requests, err = server.Requests(false)
+	Expect(err).To(BeNil())
+	Expect(requests).To(BeEmpty())
+}
+
+func (s *ServerSuite) TestAddInvalid(t sweet.T) {
+	server, err := NewServer()
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	err = server.Add(client.NewExpectation().Path("("))
+	Expect(err).To(MatchError("failed to register expectation (/request/path: failed to unmarshal expectation (illegal path regex (error parsing regexp: missing closing ): `(`)))"))
+}
+
+func (s *ServerSuite) TestAddInvalidSchema(t sweet.T) {
+	server, err := NewServer()
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	// The same payload is rejected by the /register endpoint
+	err = server.Add(client.NewExpectation().Respond(client.NewTemplate().StatusCode(1)))
+	Expect(err).To(MatchError(ContainSubstring("failed to register expectation (/response/status_code: ")))
+}
+
+func (s *ServerSuite) TestCloseStopsStream(t sweet.T) {
+	server, err := NewServer()
+	Expect(err).To(BeNil())
+
+	server.Close()
+	Eventually(server.handler.RequestLog.Chan()).Should(BeClosed())
+}
+
+func (s *ServerSuite) TestClear(t sweet.T) {
+	server, err := NewServer()
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	Expect(server.Add(client.NewExpectation().Path("^/a$"))).To(BeNil())
+	server.Clear()
+
+	status, _ := get(server.URL + "/a")
+	Expect(status).To(Equal(http.StatusNotFound))
+}
+
+func (s *ServerSuite) TestControlEndpoints(t sweet.T) {
+	server, err := NewServer()
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	c := server.Client()
+	Expect(c.Register(context.Background(), client.NewExpectation().Path("^/a$"))).To(BeNil())
+	Expect(c.SetExpectations(context.Background(), []*client.Expectation{
+		client.NewExpectation().Path("^/b$").Respond(client.NewTemplate().StatusCode(http.StatusAccepted)),
+	})).To(BeNil())
+
+	status, _ := get(server.URL + "/a")
+	Expect(status).To(Equal(http.StatusNotFound))
+
+	status, _ = get(server.URL + "/b")
+	Expect(status).To(Equal(http.StatusAccepted))
+
+	requests, err := c.Requests(context.Background(), false)
+	Expect(err).To(BeNil())
+	Expect(requests).To(HaveLen(2))
+}
+
+func (s *ServerSuite) TestRegisterInvalid(t sweet.T) {
+	server, err := NewServer()
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	err = server.Client().Register(context.Background(), client.NewExpectation().Respond(client.NewTemplate().StatusCode(1)))
+	Expect(err).To(BeAssignableToTypeOf(&client.StatusError{}))
+	Expect(err.(*client.StatusError).StatusCode).To(Equal(http.StatusUnprocessableEntity))
+	Expect(err.(*client.StatusError).Body).To(ContainSubstring("response.status_code"))
+
+	err = server.Client().Register(context.Background(), client.NewExpectation().Path("("))
+	Expect(err).To(BeAssignableToTypeOf(&client.StatusError{}))
+	Expect(err.(*client.StatusError).StatusCode).To(Equal(http.StatusUnprocessableEntity))
+	Expect(err.(*client.StatusError).Body).To(MatchJSON(`{
+		"error": {"request.path": "failed to unmarshal expectation (illegal path regex (error parsing regexp: missing closing ): ` + "`(`" + `))"},
+		"errors": [{"pointer": "/request/path", "message": "failed to unmarshal expectation (illegal path regex (error parsing regexp: missing closing ): ` + "`(`" + `))"}]
+	}`))
+}
+
+func (s *ServerSuite) TestSubscribe(t sweet.T) {
+	server, err := NewServer()
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := server.Client().Subscribe(ctx)
+	Expect(err).To(BeNil())
+
+	go get(server.URL + "/streamed")
+
+	var request *client.Request
+	Eventually(ch, time.Second).Should(Receive(&request))
+	Expect(request.Path).To(Equal("/streamed"))
+	Expect(request.Response.StatusCode).To(Equal(http.StatusNotFound))
+}
+
+func (s *ServerSuite) TestWithConfigDir(t sweet.T) {
+	server, err := NewServer(WithConfigDir("../../internal/server/tests/valid"))
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	status, body := get(server.URL + "/a1")
+	Expect(status).To(Equal(http.StatusAccepted))
+	Expect(body).To(Equal("a1"))
+}
+
+func (s *ServerSuite) TestReload(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "a.yaml")
+	Expect(ioutil.WriteFile(path, []byte("- request: {path: ^/a$}\n  response: {status_code: '201'}\n"), 0644)).To(BeNil())
+
+	server, err := NewServer(WithConfigDir(dir))
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	Expect(ioutil.WriteFile(path, []byte("- request: {path: '('}\n  response: {}\n"), 0644)).To(BeNil())
+
+	req, err := http.NewRequest("POST", server.URL+"/reload", nil)
+	Expect(err).To(BeNil())
+	req.Header.Set("X-Derision-Control", "true")
+
+	resp, err := http.DefaultClient.Do(req)
+	Expect(err).To(BeNil())
+	resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+
+	// The previous expectations are kept
+	status, _ := get(server.URL + "/a")
+	Expect(status).To(Equal(http.StatusCreated))
+
+	req, err = http.NewRequest("GET", server.URL+"/reload", nil)
+	Expect(err).To(BeNil())
+	req.Header.Set("X-Derision-Control", "true")
+
+	resp, err = http.DefaultClient.Do(req)
+	Expect(err).To(BeNil())
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	Expect(err).To(BeNil())
+	Expect(string(body)).To(ContainSubstring(`"pointer":"/0/request/path"`))
+}
+
+func (s *ServerSuite) TestReloadKeepsOrder(t sweet.T) {
+	dir, err := ioutil.TempDir("", "derision")
+	Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "a.yaml")
+	Expect(ioutil.WriteFile(path, []byte("- request: {path: ^/a$}\n  response: {status_code: '200'}\n"), 0644)).To(BeNil())
+
+	server, err := NewServer(WithConfigDir(dir))
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	Expect(server.Add(client.NewExpectation().Respond(client.NewTemplate().StatusCode(http.StatusTeapot)))).To(BeNil())
+
+	status, _ := get(server.URL + "/a")
+	Expect(status).To(Equal(http.StatusOK))
+
+	Expect(ioutil.WriteFile(path, []byte("- request: {path: ^/a$}\n  response: {status_code: '201'}\n"), 0644)).To(BeNil())
+
+	req, err := http.NewRequest("POST", server.URL+"/reload", nil)
+	Expect(err).To(BeNil())
+	req.Header.Set("X-Derision-Control", "true")
+
+	resp, err := http.DefaultClient.Do(req)
+	Expect(err).To(BeNil())
+	resp.Body.Close()
+
+	// The file stub is still ahead of the runtime catch-all
+	status, _ = get(server.URL + "/a")
+	Expect(status).To(Equal(http.StatusCreated))
+
+	status, _ = get(server.URL + "/other")
+	Expect(status).To(Equal(http.StatusTeapot))
+}
+
+func (s *ServerSuite) TestWithRequestLogCapacity(t sweet.T) {
+	server, err := NewServer(WithRequestLogCapacity(1))
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	get(server.URL + "/a")
+	get(server.URL + "/b")
+
+	requests, err := server.Requests(false)
+	Expect(err).To(BeNil())
+	Expect(requests).To(HaveLen(1))
+	Expect(requests[0].Path).To(Equal("/b"))
+}
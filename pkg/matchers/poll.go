@@ -0,0 +1,27 @@
+package matchers
+
+import (
+	"context"
+
+	"github.com/efritz/derision/pkg/client"
+	"github.com/efritz/derision/pkg/derisiontest"
+)
+
+// Poll returns a function that fetches the request log of the server behind
+// the given client. The function can be passed to gomega's Eventually and
+// Consistently, which call it until the log satisfies a matcher.
+//
+//	Eventually(matchers.Poll(c)).Should(matchers.HaveReceivedRequest("GET", "^/users"))
+func Poll(c *client.Client) func() ([]*client.Request, error) {
+	return func() ([]*client.Request, error) {
+		return c.Requests(context.Background(), false)
+	}
+}
+
+// PollServer returns a function that fetches the request log of the given
+// embedded server. See Poll.
+func PollServer(s *derisiontest.Server) func() ([]*client.Request, error) {
+	return func() ([]*client.Request, error) {
+		return s.Requests(false)
+	}
+}
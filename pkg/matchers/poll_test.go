@@ -0,0 +1,30 @@
+package matchers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/pkg/derisiontest"
+	. "github.com/onsi/gomega"
+)
+
+type PollSuite struct{}
+
+func (s *PollSuite) TestPoll(t sweet.T) {
+	server, err := derisiontest.NewServer()
+	Expect(err).To(BeNil())
+	defer server.Close()
+
+	go func() {
+		<-time.After(time.Millisecond * 50)
+
+		resp, err := http.Get(server.URL + "/users/42")
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	Eventually(Poll(server.Client()), time.Second).Should(HaveReceivedRequest("GET", `^/users/\d+$`))
+	Eventually(PollServer(server), time.Second).Should(HaveReceivedTimes(1))
+}
@@ -0,0 +1,203 @@
+package matchers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/efritz/derision/pkg/client"
+	"github.com/onsi/gomega/types"
+)
+
+type (
+	receivedMatcher struct {
+		matchers []types.GomegaMatcher
+		times    int
+		results  []*result
+		count    int
+	}
+
+	// result records which matchers were satisfied by a logged request.
+	result struct {
+		request *client.Request
+		failed  []types.GomegaMatcher
+	}
+)
+
+// closestCount is the number of logged requests printed in a failure message.
+const closestCount = 3
+
+// HaveReceivedRequest succeeds if the actual request log contains at least
+// one request with the given method and path (see MatchRequest) that also
+// satisfies each of the given request matchers.
+func HaveReceivedRequest(method, pathPattern string, matchers ...types.GomegaMatcher) types.GomegaMatcher {
+	return &receivedMatcher{
+		matchers: append([]types.GomegaMatcher{MatchRequest(method, pathPattern)}, matchers...),
+		times:    -1,
+	}
+}
+
+// HaveReceivedTimes succeeds if exactly n requests of the actual request log
+// satisfy each of the given request matchers.
+func HaveReceivedTimes(n int, matchers ...types.GomegaMatcher) types.GomegaMatcher {
+	return &receivedMatcher{
+		matchers: matchers,
+		times:    n,
+	}
+}
+
+func (m *receivedMatcher) Match(actual interface{}) (bool, error) {
+	requests, ok := actual.([]*client.Request)
+	if !ok {
+		return false, fmt.Errorf("expected a []*client.Request, got %T", actual)
+	}
+
+	m.results = make([]*result, 0, len(requests))
+	m.count = 0
+
+	for _, r := range requests {
+		result := &result{request: r}
+		for _, matcher := range m.matchers {
+			ok, err := matcher.Match(r)
+			if err != nil {
+				return false, err
+			}
+
+			if !ok {
+				result.failed = append(result.failed, matcher)
+			}
+		}
+
+		if len(result.failed) == 0 {
+			m.count++
+		}
+
+		m.results = append(m.results, result)
+	}
+
+	if m.times < 0 {
+		return m.count > 0, nil
+	}
+
+	return m.count == m.times, nil
+}
+
+func (m *receivedMatcher) FailureMessage(actual interface{}) string {
+	if m.times < 0 {
+		return fmt.Sprintf(
+			"Expected to have received a request %s\n%s",
+			describeMatchers(m.matchers),
+			m.describeClosest(),
+		)
+	}
+
+	return fmt.Sprintf(
+		"Expected to have received %d request(s) %s, but received %d\n%s",
+		m.times,
+		describeMatchers(m.matchers),
+		m.count,
+		m.describeClosest(),
+	)
+}
+
+func (m *receivedMatcher) NegatedFailureMessage(actual interface{}) string {
+	if m.times < 0 {
+		return fmt.Sprintf(
+			"Expected not to have received a request %s, but received %d\n%s",
+			describeMatchers(m.matchers),
+			m.count,
+			m.describeMatching(),
+		)
+	}
+
+	return fmt.Sprintf(
+		"Expected not to have received %d request(s) %s\n%s",
+		m.times,
+		describeMatchers(m.matchers),
+		m.describeMatching(),
+	)
+}
+
+// describeClosest lists the logged requests that satisfy the most matchers
+// along with the matchers that each one does not satisfy.
+func (m *receivedMatcher) describeClosest() string {
+	if len(m.results) == 0 {
+		return "The request log is empty"
+	}
+
+	results := append([]*result{}, m.results...)
+	sort.SliceStable(results, func(i, j int) bool {
+		return len(results[i].failed) < len(results[j].failed)
+	})
+
+	if len(results) > closestCount {
+		results = results[:closestCount]
+	}
+
+	lines := []string{fmt.Sprintf("Closest of %d logged request(s):", len(m.results))}
+	for _, result := range results {
+		line := "    " + summarize(result.request)
+		if len(result.failed) > 0 {
+			line += fmt.Sprintf(" (not %s)", describeMatchers(result.failed))
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (m *receivedMatcher) describeMatching() string {
+	lines := []string{"Matching request(s):"}
+	for _, result := range m.results {
+		if len(result.failed) == 0 {
+			lines = append(lines, "    "+summarize(result.request))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func describeMatchers(matchers []types.GomegaMatcher) string {
+	if len(matchers) == 0 {
+		return "of any kind"
+	}
+
+	descriptions := []string{}
+	for _, matcher := range matchers {
+		if m, ok := matcher.(*requestMatcher); ok {
+			descriptions = append(descriptions, m.description)
+		} else {
+			descriptions = append(descriptions, fmt.Sprintf("satisfying %T", matcher))
+		}
+	}
+
+	return strings.Join(descriptions, " ")
+}
+
+// summarize returns a one-line description of a logged request.
+func summarize(actual interface{}) string {
+	r, ok := actual.(*client.Request)
+	if !ok || r == nil {
+		return fmt.Sprintf("%v", actual)
+	}
+
+	summary := fmt.Sprintf("%s %s", r.Method, r.Path)
+	if len(r.Query) > 0 {
+		parts := []string{}
+		for key, values := range r.Query {
+			for _, value := range values {
+				parts = append(parts, key+"="+value)
+			}
+		}
+
+		sort.Strings(parts)
+		summary += "?" + strings.Join(parts, "&")
+	}
+
+	if r.Response != nil {
+		summary += fmt.Sprintf(" -> %d", r.Response.StatusCode)
+	}
+
+	return summary
+}
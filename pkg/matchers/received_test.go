@@ -0,0 +1,68 @@
+package matchers
+
+import (
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/pkg/client"
+	. "github.com/onsi/gomega"
+)
+
+type ReceivedSuite struct{}
+
+var testRequests = []*client.Request{
+	{Method: "GET", Path: "/users/1", Response: &client.Response{StatusCode: 200}},
+	{Method: "POST", Path: "/users", Body: `{"name": "foo"}`, Response: &client.Response{StatusCode: 201}},
+	{Method: "GET", Path: "/users/2", Query: map[string][]string{"q": {"x"}}, Response: &client.Response{StatusCode: 404}},
+}
+
+func (s *ReceivedSuite) TestHaveReceivedRequest(t sweet.T) {
+	Expect(testRequests).To(HaveReceivedRequest("GET", `^/users/\d+$`))
+	Expect(testRequests).To(HaveReceivedRequest("POST", `^/users$`, HaveJSONBody(`{"name": "foo"}`)))
+	Expect(testRequests).NotTo(HaveReceivedRequest("POST", `^/users$`, HaveJSONBody(`{"name": "bar"}`)))
+	Expect(testRequests).NotTo(HaveReceivedRequest("DELETE", `^/users`))
+	Expect([]*client.Request{}).NotTo(HaveReceivedRequest("GET", "/"))
+}
+
+func (s *ReceivedSuite) TestHaveReceivedTimes(t sweet.T) {
+	Expect(testRequests).To(HaveReceivedTimes(3))
+	Expect(testRequests).To(HaveReceivedTimes(2, MatchRequest("GET", `^/users/\d+$`)))
+	Expect(testRequests).To(HaveReceivedTimes(0, MatchRequest("DELETE", "/")))
+	Expect(testRequests).NotTo(HaveReceivedTimes(1, MatchRequest("GET", `^/users/\d+$`)))
+}
+
+func (s *ReceivedSuite) TestInvalidActual(t sweet.T) {
+	_, err := HaveReceivedTimes(1).Match(testRequests[0])
+	Expect(err).To(MatchError("expected a []*client.Request, got *client.Request"))
+}
+
+func (s *ReceivedSuite) TestFailureMessageClosest(t sweet.T) {
+	matcher := HaveReceivedRequest("POST", `^/users$`, HaveJSONBody(`{"name": "bar"}`))
+	Expect(matcher.Match(testRequests)).To(BeFalse())
+	Expect(matcher.FailureMessage(testRequests)).To(Equal(`Expected to have received a request POST ^/users$ with JSON body containing {"name": "bar"}
+Closest of 3 logged request(s):
+    POST /users -> 201 (not with JSON body containing {"name": "bar"})
+    GET /users/1 -> 200 (not POST ^/users$ with JSON body containing {"name": "bar"})
+    GET /users/2?q=x -> 404 (not POST ^/users$ with JSON body containing {"name": "bar"})`))
+}
+
+func (s *ReceivedSuite) TestFailureMessageTimes(t sweet.T) {
+	matcher := HaveReceivedTimes(1, MatchRequest("GET", `^/users/\d+$`))
+	Expect(matcher.Match(testRequests)).To(BeFalse())
+	Expect(matcher.FailureMessage(testRequests)).To(HavePrefix(
+		"Expected to have received 1 request(s) GET ^/users/\\d+$, but received 2\nClosest of 3 logged request(s):\n    GET /users/1 -> 200\n",
+	))
+}
+
+func (s *ReceivedSuite) TestFailureMessageEmpty(t sweet.T) {
+	matcher := HaveReceivedRequest("GET", "/")
+	Expect(matcher.Match([]*client.Request{})).To(BeFalse())
+	Expect(matcher.FailureMessage([]*client.Request{})).To(Equal("Expected to have received a request GET /\nThe request log is empty"))
+}
+
+func (s *ReceivedSuite) TestNegatedFailureMessage(t sweet.T) {
+	matcher := HaveReceivedRequest("GET", `^/users/\d+$`)
+	Expect(matcher.Match(testRequests)).To(BeTrue())
+	Expect(matcher.NegatedFailureMessage(testRequests)).To(Equal(`Expected not to have received a request GET ^/users/\d+$, but received 2
+Matching request(s):
+    GET /users/1 -> 200
+    GET /users/2?q=x -> 404`))
+}
@@ -0,0 +1,217 @@
+package matchers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+
+	"github.com/efritz/derision/pkg/client"
+	"github.com/onsi/gomega/types"
+)
+
+type (
+	requestMatcher struct {
+		description string
+		match       func(r *client.Request) (bool, error)
+	}
+)
+
+// MatchRequest succeeds if the actual request has the given method and a
+// path that matches the given regular expression. An empty method matches
+// any method.
+func MatchRequest(method, pathPattern string) types.GomegaMatcher {
+	pattern, err := regexp.Compile(pathPattern)
+
+	return &requestMatcher{
+		description: describeRequest(method, pathPattern),
+		match: func(r *client.Request) (bool, error) {
+			if err != nil {
+				return false, fmt.Errorf("illegal path regex (%s)", err.Error())
+			}
+
+			return (method == "" || r.Method == method) && pattern.MatchString(r.Path), nil
+		},
+	}
+}
+
+// HaveHeader succeeds if any value of the given header of the actual request
+// matches the given regular expression. The header name is canonicalized.
+func HaveHeader(name, valuePattern string) types.GomegaMatcher {
+	pattern, err := regexp.Compile(valuePattern)
+
+	return &requestMatcher{
+		description: fmt.Sprintf("with header %s matching %q", http.CanonicalHeaderKey(name), valuePattern),
+		match: func(r *client.Request) (bool, error) {
+			if err != nil {
+				return false, fmt.Errorf("illegal header regex (%s)", err.Error())
+			}
+
+			for _, value := range r.Headers[http.CanonicalHeaderKey(name)] {
+				if pattern.MatchString(value) {
+					return true, nil
+				}
+			}
+
+			return false, nil
+		},
+	}
+}
+
+// HaveJSONBody succeeds if the body of the actual request is a JSON document
+// that contains the given subset. The subset may be a JSON string (or byte
+// slice) or any value that can be serialized as JSON. An object contains a
+// subset object if it contains each of its keys with a value that contains
+// the subset's value. Arrays and scalars must be equal.
+func HaveJSONBody(subset interface{}) types.GomegaMatcher {
+	expected, err := normalizeJSON(subset)
+
+	return &requestMatcher{
+		description: fmt.Sprintf("with JSON body containing %s", describeJSON(subset)),
+		match: func(r *client.Request) (bool, error) {
+			if err != nil {
+				return false, fmt.Errorf("illegal JSON subset (%s)", err.Error())
+			}
+
+			var actual interface{}
+			if err := json.Unmarshal([]byte(r.Body), &actual); err != nil {
+				return false, nil
+			}
+
+			return containsJSON(actual, expected), nil
+		},
+	}
+}
+
+// HavePart succeeds if the actual request has a multipart part with the given
+// field name whose filename and content match the given regular expressions.
+// An empty name or pattern matches any part.
+func HavePart(name, filenamePattern, contentPattern string) types.GomegaMatcher {
+	filenameRegex, filenameErr := regexp.Compile(filenamePattern)
+	contentRegex, contentErr := regexp.Compile(contentPattern)
+
+	return &requestMatcher{
+		description: fmt.Sprintf("with part %q with filename matching %q and content matching %q", name, filenamePattern, contentPattern),
+		match: func(r *client.Request) (bool, error) {
+			if filenameErr != nil {
+				return false, fmt.Errorf("illegal filename regex (%s)", filenameErr.Error())
+			}
+
+			if contentErr != nil {
+				return false, fmt.Errorf("illegal content regex (%s)", contentErr.Error())
+			}
+
+			for _, part := range r.Parts {
+				if (name == "" || part.Name == name) && filenameRegex.MatchString(part.Filename) && contentRegex.MatchString(part.Content) {
+					return true, nil
+				}
+			}
+
+			return false, nil
+		},
+	}
+}
+
+// HavePartCount succeeds if the actual request has exactly n multipart parts
+// with the given field name. An empty name counts every part.
+func HavePartCount(name string, n int) types.GomegaMatcher {
+	return &requestMatcher{
+		description: fmt.Sprintf("with %d parts named %q", n, name),
+		match: func(r *client.Request) (bool, error) {
+			count := 0
+			for _, part := range r.Parts {
+				if name == "" || part.Name == name {
+					count++
+				}
+			}
+
+			return count == n, nil
+		},
+	}
+}
+
+func (m *requestMatcher) Match(actual interface{}) (bool, error) {
+	r, ok := actual.(*client.Request)
+	if !ok {
+		return false, fmt.Errorf("expected a *client.Request, got %T", actual)
+	}
+
+	return m.match(r)
+}
+
+func (m *requestMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected\n    %s\nto be a request %s", summarize(actual), m.description)
+}
+
+func (m *requestMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected\n    %s\nnot to be a request %s", summarize(actual), m.description)
+}
+
+func normalizeJSON(value interface{}) (interface{}, error) {
+	var serialized []byte
+	switch v := value.(type) {
+	case string:
+		serialized = []byte(v)
+	case []byte:
+		serialized = v
+
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		serialized = data
+	}
+
+	var normalized interface{}
+	if err := json.Unmarshal(serialized, &normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+func containsJSON(actual, subset interface{}) bool {
+	subsetObject, ok := subset.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(actual, subset)
+	}
+
+	actualObject, ok := actual.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	for key, value := range subsetObject {
+		if actualValue, ok := actualObject[key]; !ok || !containsJSON(actualValue, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func describeJSON(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+
+	if serialized, err := json.Marshal(value); err == nil {
+		return string(serialized)
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+func describeRequest(method, pathPattern string) string {
+	if method == "" {
+		method = "*"
+	}
+
+	return fmt.Sprintf("%s %s", method, pathPattern)
+}
@@ -0,0 +1,84 @@
+package matchers
+
+import (
+	"github.com/aphistic/sweet"
+	"github.com/efritz/derision/pkg/client"
+	. "github.com/onsi/gomega"
+)
+
+type RequestSuite struct{}
+
+func (s *RequestSuite) TestMatchRequest(t sweet.T) {
+	r := &client.Request{Method: "GET", Path: "/users/42"}
+	Expect(r).To(MatchRequest("GET", `^/users/\d+$`))
+	Expect(r).To(MatchRequest("", `^/users/`))
+	Expect(r).NotTo(MatchRequest("POST", `^/users/\d+$`))
+	Expect(r).NotTo(MatchRequest("GET", `^/posts`))
+}
+
+func (s *RequestSuite) TestMatchRequestInvalid(t sweet.T) {
+	_, err := MatchRequest("GET", "(").Match(&client.Request{})
+	Expect(err).To(MatchError(ContainSubstring("illegal path regex")))
+
+	_, err = MatchRequest("GET", "/").Match("GET /")
+	Expect(err).To(MatchError("expected a *client.Request, got string"))
+}
+
+func (s *RequestSuite) TestHaveHeader(t sweet.T) {
+	r := &client.Request{Headers: map[string][]string{"X-Request-Id": {"a", "b"}}}
+	Expect(r).To(HaveHeader("x-request-id", "^b$"))
+	Expect(r).NotTo(HaveHeader("X-Request-Id", "^c$"))
+	Expect(r).NotTo(HaveHeader("X-Other", "."))
+}
+
+func (s *RequestSuite) TestHavePart(t sweet.T) {
+	r := &client.Request{Parts: []*client.Part{
+		{Name: "files", Filename: "a.png", Content: "\x89PNG"},
+		{Name: "files", Filename: "b.txt", Content: "hello"},
+		{Name: "title", Content: "upload"},
+	}}
+
+	Expect(r).To(HavePart("files", `\.txt$`, "^hello$"))
+	Expect(r).To(HavePart("title", "", "upload"))
+	Expect(r).To(HavePart("", `^a\.png$`, ""))
+	Expect(r).NotTo(HavePart("files", `\.txt$`, "^goodbye$"))
+	Expect(r).NotTo(HavePart("avatar", "", ""))
+
+	_, err := HavePart("files", "(", "").Match(r)
+	Expect(err).To(MatchError(ContainSubstring("illegal filename regex")))
+}
+
+func (s *RequestSuite) TestHavePartCount(t sweet.T) {
+	r := &client.Request{Parts: []*client.Part{
+		{Name: "files", Filename: "a.png"},
+		{Name: "files", Filename: "a.png"},
+		{Name: "title"},
+	}}
+
+	Expect(r).To(HavePartCount("files", 2))
+	Expect(r).To(HavePartCount("", 3))
+	Expect(r).NotTo(HavePartCount("title", 2))
+}
+
+func (s *RequestSuite) TestHaveJSONBody(t sweet.T) {
+	r := &client.Request{Body: `{"name": "foo", "tags": ["a", "b"], "owner": {"id": 3, "admin": false}}`}
+	Expect(r).To(HaveJSONBody(`{"name": "foo"}`))
+	Expect(r).To(HaveJSONBody(map[string]interface{}{"owner": map[string]interface{}{"id": 3}}))
+	Expect(r).To(HaveJSONBody([]byte(`{"tags": ["a", "b"]}`)))
+	Expect(r).NotTo(HaveJSONBody(`{"tags": ["a"]}`))
+	Expect(r).NotTo(HaveJSONBody(`{"owner": {"admin": true}}`))
+	Expect(r).NotTo(HaveJSONBody(`{"missing": null}`))
+	Expect(&client.Request{Body: "not json"}).NotTo(HaveJSONBody(`{}`))
+}
+
+func (s *RequestSuite) TestHaveJSONBodyInvalid(t sweet.T) {
+	_, err := HaveJSONBody(`{`).Match(&client.Request{Body: `{}`})
+	Expect(err).To(MatchError(ContainSubstring("illegal JSON subset")))
+}
+
+func (s *RequestSuite) TestFailureMessage(t sweet.T) {
+	r := &client.Request{Method: "GET", Path: "/users/42", Response: &client.Response{StatusCode: 404}}
+	Expect(HaveHeader("x-id", "1").FailureMessage(r)).To(Equal(
+		"Expected\n    GET /users/42 -> 404\nto be a request with header X-Id matching \"1\"",
+	))
+}